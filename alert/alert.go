@@ -0,0 +1,286 @@
+// Package alert evaluates declarative rules against collected file events.
+// An Engine reads new events from a monitor, hands each to every Rule and
+// keeps the alerts they raise in a Store, together with how far it got, so
+// a restarted daemon neither misses events nor raises an alert twice.
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/metrics"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+const (
+	// MaxAlertEvents is the most matched events an alert carries. Count
+	// still says how many there were.
+	MaxAlertEvents = 100
+
+	// DefaultListLimit and MaxListLimit bound how many alerts List returns.
+	DefaultListLimit = 100
+	MaxListLimit     = 10000
+
+	defaultInterval = 5 * time.Second
+)
+
+type (
+	// Alert is raised by a rule. Time is that of the event that raised it,
+	// and Events the events that matched, the last MaxAlertEvents of Count.
+	// Alerts with the same DedupKey are about the same thing.
+	Alert struct {
+		ID          string                 `json:"id"`
+		Time        time.Time              `json:"time"`
+		RuleID      string                 `json:"rule_id"`
+		Severity    string                 `json:"severity"`
+		Description string                 `json:"description,omitempty"`
+		DedupKey    string                 `json:"dedup_key"`
+		Count       int                    `json:"count"`
+		Events      []monitoring.FileEvent `json:"events"`
+	}
+
+	// Query selects alerts for List. Zero fields match every alert.
+	Query struct {
+		RuleIDs    []string
+		Severities []string
+		DedupKey   string
+		// Since excludes alerts at or before it, Until those after it.
+		Since time.Time
+		Until time.Time
+		Limit int
+	}
+
+	// Store keeps alerts in an event store. Alert IDs are the store's
+	// sequence numbers.
+	Store struct {
+		store *store.Store
+	}
+
+	// Engine evaluates rules against the events of a monitor. On its first
+	// run it starts with the events collected after it started.
+	Engine struct {
+		rules    []*Rule
+		source   monitoring.EventSource
+		alerts   *Store
+		interval time.Duration
+		log      *logger.Logger
+	}
+
+	EngineOption func(*Engine)
+)
+
+// NewStore keeps alerts in s.
+func NewStore(s *store.Store) *Store {
+	return &Store{store: s}
+}
+
+// List returns the alerts selected by query, newest first.
+func (s *Store) List(query Query) ([]Alert, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultListLimit
+	}
+	if limit < 0 || limit > MaxListLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxListLimit)
+	}
+
+	var alerts []Alert
+	var decodeErr error
+	err := s.store.Scan(store.Filter{After: query.Since, Reverse: true}, func(r store.Record) bool {
+		var alert Alert
+		if decodeErr = json.Unmarshal(r.Data, &alert); decodeErr != nil {
+			return false
+		}
+		alert.ID = strconv.FormatUint(r.Seq, 10)
+		if query.match(alert) {
+			alerts = append(alerts, alert)
+		}
+		return len(alerts) < limit
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// Since returns up to limit alerts stored after the alert with ID after,
+// oldest first, and the ID of the last one returned, or after if there
+// are none.
+func (s *Store) Since(after uint64, limit int) ([]Alert, uint64, error) {
+	var alerts []Alert
+	var decodeErr error
+	err := s.store.Scan(store.Filter{AfterSeq: after}, func(r store.Record) bool {
+		var alert Alert
+		if decodeErr = json.Unmarshal(r.Data, &alert); decodeErr != nil {
+			return false
+		}
+		alert.ID = strconv.FormatUint(r.Seq, 10)
+		alerts = append(alerts, alert)
+		after = r.Seq
+		return len(alerts) < limit
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	return alerts, after, err
+}
+
+// Last returns the ID of the newest alert, 0 before the first.
+func (s *Store) Last() uint64 {
+	return s.store.Stats().LastSeq
+}
+
+func (q Query) match(alert Alert) bool {
+	switch {
+	case len(q.RuleIDs) > 0 && !slices.Contains(q.RuleIDs, alert.RuleID):
+		return false
+	case len(q.Severities) > 0 && !slices.Contains(q.Severities, alert.Severity):
+		return false
+	case q.DedupKey != "" && alert.DedupKey != q.DedupKey:
+		return false
+	case !q.Until.IsZero() && alert.Time.After(q.Until):
+		return false
+	}
+	return true
+}
+
+// commit stores alerts along with the cursor of the events they were
+// raised by.
+func (s *Store) commit(alerts []Alert, cursor monitoring.Cursor) error {
+	records := make([]store.Record, 0, len(alerts))
+	for _, alert := range alerts {
+		data, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		records = append(records, store.Record{Time: alert.Time, Data: data})
+	}
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	return s.store.Commit(records, data)
+}
+
+// cursor returns the cursor saved with the last alerts, if any.
+func (s *Store) cursor() (monitoring.Cursor, bool, error) {
+	var cursor monitoring.Cursor
+	saved := s.store.Cursor()
+	if saved == nil {
+		return cursor, false, nil
+	}
+	if err := json.Unmarshal(saved, &cursor); err != nil {
+		return cursor, false, fmt.Errorf("failed to decode alert cursor: %w", err)
+	}
+	return cursor, true, nil
+}
+
+// Close closes the underlying store.
+func (s *Store) Close() error {
+	return s.store.Close()
+}
+
+// WithInterval sets how often new events are evaluated.
+func WithInterval(interval time.Duration) EngineOption {
+	return func(e *Engine) {
+		if interval > 0 {
+			e.interval = interval
+		}
+	}
+}
+
+func NewEngine(rules []*Rule, source monitoring.EventSource, alerts *Store, log *logger.Logger, opts ...EngineOption) *Engine {
+	e := &Engine{
+		rules:    rules,
+		source:   source,
+		alerts:   alerts,
+		interval: defaultInterval,
+		log:      log,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Run evaluates new events every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context) error {
+	cursor, err := e.loadCursor(ctx)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if cursor, err = e.Evaluate(ctx, cursor); err != nil && ctx.Err() == nil {
+				e.log.Error("Failed to evaluate alert rules", "error", err)
+			}
+		}
+	}
+}
+
+// loadCursor returns the cursor saved with the alerts, or the monitor's
+// head when there is none yet.
+func (e *Engine) loadCursor(ctx context.Context) (monitoring.Cursor, error) {
+	cursor, ok, err := e.alerts.cursor()
+	if err != nil || ok {
+		return cursor, err
+	}
+	if cursor, err = e.source.Head(ctx); err != nil {
+		return cursor, fmt.Errorf("failed to find the newest event for alerting: %w", err)
+	}
+	return cursor, e.alerts.commit(nil, cursor)
+}
+
+// Evaluate runs the rules over the events after cursor, stores the alerts
+// they raise and returns the cursor after the last event evaluated.
+func (e *Engine) Evaluate(ctx context.Context, cursor monitoring.Cursor) (monitoring.Cursor, error) {
+	for {
+		events, next, err := e.source.EventsSince(ctx, cursor)
+		if err != nil {
+			return cursor, err
+		}
+		if len(events) == 0 {
+			return next, nil
+		}
+
+		var alerts []Alert
+		latest := events[0].Time
+		for _, event := range events {
+			for _, rule := range e.rules {
+				if alert, ok := rule.Observe(event); ok {
+					alerts = append(alerts, alert)
+				}
+			}
+			if event.Time.After(latest) {
+				latest = event.Time
+			}
+		}
+		if err := e.alerts.commit(alerts, next); err != nil {
+			return cursor, fmt.Errorf("failed to store alerts: %w", err)
+		}
+		for _, alert := range alerts {
+			metrics.AlertRaised(alert.RuleID, alert.Severity)
+			e.log.Warn("Alert raised", "rule", alert.RuleID, "severity", alert.Severity, "dedup_key", alert.DedupKey, "count", alert.Count)
+		}
+		for _, rule := range e.rules {
+			rule.Expire(latest)
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,157 @@
+package alert
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+// sliceSource hands out events from a slice, numbered from 1.
+type sliceSource struct {
+	events []monitoring.FileEvent
+}
+
+func (s *sliceSource) add(path string, action monitoring.Action) {
+	id := len(s.events) + 1
+	s.events = append(s.events, monitoring.FileEvent{
+		ID:         strconv.Itoa(id),
+		Time:       time.Unix(1700000000+int64(id), 0).UTC(),
+		Action:     action,
+		Path:       path,
+		TargetPath: path,
+	})
+}
+
+func (s *sliceSource) EventsSince(ctx context.Context, cursor monitoring.Cursor) ([]monitoring.FileEvent, monitoring.Cursor, error) {
+	events := s.events[cursor.Seq:]
+	if len(events) > 0 {
+		cursor = cursor.At(events[len(events)-1])
+	}
+	return events, cursor, nil
+}
+
+func (s *sliceSource) Head(ctx context.Context) (monitoring.Cursor, error) {
+	if len(s.events) == 0 {
+		return monitoring.Cursor{}, nil
+	}
+	return monitoring.Cursor{}.At(s.events[len(s.events)-1]), nil
+}
+
+func newTestEngine(t *testing.T, dir string, source *sliceSource) (*Engine, *Store) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	var rules []*Rule
+	for _, cfg := range []config.Rule{
+		{ID: "shadow", Severity: "critical", Paths: []string{"/etc/shadow"}},
+		{ID: "mass-delete", Severity: "high", Paths: []string{"/srv/"}, Actions: []string{"DELETED"}, Threshold: 2, Window: time.Minute},
+	} {
+		rule, err := NewRule(cfg)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+	s, err := store.Open(dir)
+	require.NoError(t, err)
+	alerts := NewStore(s)
+	t.Cleanup(func() { _ = alerts.Close() })
+	return NewEngine(rules, source, alerts, mockLogger), alerts
+}
+
+func TestEngine(t *testing.T) {
+	dir := t.TempDir()
+	source := &sliceSource{}
+	source.add("/etc/shadow", monitoring.ActionUpdated)
+
+	engine, alerts := newTestEngine(t, dir, source)
+	cursor, err := engine.source.Head(context.Background())
+	require.NoError(t, err)
+
+	source.add("/srv/a", monitoring.ActionDeleted)
+	source.add("/etc/shadow", monitoring.ActionUpdated)
+	source.add("/srv/b", monitoring.ActionDeleted)
+	source.add("/srv/c", monitoring.ActionDeleted)
+	cursor, err = engine.Evaluate(context.Background(), cursor)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), cursor.Seq)
+
+	list, err := alerts.List(Query{})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "2", list[0].ID)
+	assert.Equal(t, "mass-delete", list[0].RuleID)
+	assert.Equal(t, "high", list[0].Severity)
+	assert.Equal(t, 3, list[0].Count)
+	assert.Equal(t, "1", list[1].ID)
+	assert.Equal(t, "shadow", list[1].DedupKey)
+	assert.Equal(t, "3", list[1].Events[0].ID)
+
+	list, err = alerts.List(Query{Severities: []string{"critical"}})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "shadow", list[0].RuleID)
+
+	list, err = alerts.List(Query{RuleIDs: []string{"shadow"}, Since: list[0].Time})
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	list, err = alerts.List(Query{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "2", list[0].ID)
+
+	_, err = alerts.List(Query{Limit: MaxListLimit + 1})
+	assert.Error(t, err)
+
+	// Outputs read alerts in the order they were raised.
+	assert.Equal(t, uint64(2), alerts.Last())
+	since, last, err := alerts.Since(0, 1)
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, "shadow", since[0].RuleID)
+	assert.Equal(t, uint64(1), last)
+	since, last, err = alerts.Since(last, 10)
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, "2", since[0].ID)
+	since, last, err = alerts.Since(last, 10)
+	require.NoError(t, err)
+	assert.Empty(t, since)
+	assert.Equal(t, uint64(2), last)
+}
+
+func TestEngineResumes(t *testing.T) {
+	dir := t.TempDir()
+	source := &sliceSource{}
+	source.add("/etc/shadow", monitoring.ActionCreated)
+	engine, alerts := newTestEngine(t, dir, source)
+
+	// Events from before the first run are not evaluated.
+	cursor, err := engine.loadCursor(context.Background())
+	require.NoError(t, err)
+	source.add("/etc/shadow", monitoring.ActionUpdated)
+	_, err = engine.Evaluate(context.Background(), cursor)
+	require.NoError(t, err)
+	require.NoError(t, alerts.Close())
+
+	// A restarted engine carries on after the events already evaluated.
+	source.add("/etc/shadow", monitoring.ActionDeleted)
+	engine, alerts = newTestEngine(t, dir, source)
+	cursor, err = engine.loadCursor(context.Background())
+	require.NoError(t, err)
+	_, err = engine.Evaluate(context.Background(), cursor)
+	require.NoError(t, err)
+
+	list, err := alerts.List(Query{})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, monitoring.ActionDeleted, list[0].Events[0].Action)
+	assert.Equal(t, monitoring.ActionUpdated, list[1].Events[0].Action)
+}
@@ -0,0 +1,44 @@
+package alert
+
+import (
+	"fmt"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+// NewFromConfig builds the engine evaluating the configured rules against
+// the events of monitor, and the store it keeps alerts in. Without rules it
+// returns neither.
+func NewFromConfig(cfg *config.Config, monitor monitoring.Monitor, log *logger.Logger) (*Engine, *Store, error) {
+	if len(cfg.Alerts.Rules) == 0 {
+		return nil, nil, nil
+	}
+	source, ok := monitoring.EventSourceOf(monitor)
+	if !ok {
+		return nil, nil, fmt.Errorf("alert rules need a monitor that delivers events incrementally: %w", monitoring.ErrCursorUnsupported)
+	}
+	if cfg.Alerts.Path == "" {
+		return nil, nil, fmt.Errorf("alert rules need an alerts path to keep alerts in")
+	}
+
+	rules := make([]*Rule, 0, len(cfg.Alerts.Rules))
+	for _, ruleCfg := range cfg.Alerts.Rules {
+		rule, err := NewRule(ruleCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	s, err := store.Open(cfg.Alerts.Path,
+		store.WithMaxAge(cfg.Alerts.MaxAge),
+		store.WithLogger(log))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open alert store: %w", err)
+	}
+	alerts := NewStore(s)
+	return NewEngine(rules, source, alerts, log, WithInterval(cfg.Alerts.Interval)), alerts, nil
+}
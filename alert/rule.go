@@ -0,0 +1,279 @@
+package alert
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/ignore"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	GroupByPath      = "path"
+	GroupByDirectory = "directory"
+	GroupByCategory  = "category"
+	GroupByUID       = "uid"
+
+	defaultSeverity = "medium"
+)
+
+type (
+	// Rule is a compiled alert rule. It keeps the events counted towards
+	// its threshold and when each dedup key last fired, so it must only be
+	// used by one goroutine.
+	Rule struct {
+		id          string
+		description string
+		severity    string
+		prefixes    []string
+		globs       []*regexp.Regexp
+		actions     []monitoring.Action
+		categories  []string
+		uids        []uint32
+		hashes      []string
+		hours       *timeOfDay
+		threshold   int
+		window      time.Duration
+		groupBy     string
+		suppress    time.Duration
+
+		pending map[string][]monitoring.FileEvent
+		fired   map[string]time.Time
+	}
+
+	// timeOfDay is a range of the day in loc, from and to being offsets from
+	// midnight. A range with to before from spans midnight.
+	timeOfDay struct {
+		from, to time.Duration
+		loc      *time.Location
+	}
+)
+
+// NewRule compiles the rule described by cfg.
+func NewRule(cfg config.Rule) (*Rule, error) {
+	r := &Rule{
+		id:          cfg.ID,
+		description: cfg.Description,
+		severity:    cfg.Severity,
+		categories:  cfg.Categories,
+		uids:        cfg.UIDs,
+		threshold:   cfg.Threshold,
+		window:      cfg.Window,
+		groupBy:     cfg.GroupBy,
+		suppress:    cfg.Suppress,
+		pending:     make(map[string][]monitoring.FileEvent),
+		fired:       make(map[string]time.Time),
+	}
+	if r.id == "" {
+		return nil, fmt.Errorf("alert rule has no id")
+	}
+	if r.severity == "" {
+		r.severity = defaultSeverity
+	}
+	if r.threshold > 0 && r.window <= 0 {
+		return nil, fmt.Errorf("alert rule %s: a threshold needs a window", r.id)
+	}
+	for _, p := range cfg.Paths {
+		if !strings.ContainsAny(p, "*?[") {
+			r.prefixes = append(r.prefixes, p)
+			continue
+		}
+		glob, err := ignore.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: invalid path %q: %w", r.id, p, err)
+		}
+		r.globs = append(r.globs, glob)
+	}
+	for _, action := range cfg.Actions {
+		r.actions = append(r.actions, monitoring.Action(strings.ToUpper(action)))
+	}
+	for _, hash := range cfg.Hashes {
+		r.hashes = append(r.hashes, strings.ToLower(hash))
+	}
+	if cfg.Hours != "" {
+		hours, err := parseTimeOfDay(cfg.Hours, cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: %w", r.id, err)
+		}
+		r.hours = hours
+	}
+	return r, nil
+}
+
+func (r *Rule) ID() string {
+	return r.id
+}
+
+// Observe evaluates event and returns the alert it raises, if any.
+func (r *Rule) Observe(event monitoring.FileEvent) (Alert, bool) {
+	if !r.Match(event) {
+		return Alert{}, false
+	}
+	group := r.group(event)
+	events := []monitoring.FileEvent{event}
+
+	if r.threshold > 0 {
+		events = append(r.pending[group], event)
+		// Events arrive in the order they were collected, which is only
+		// roughly the order they happened in, so every event is checked.
+		start := event.Time.Add(-r.window)
+		events = slices.DeleteFunc(events, func(e monitoring.FileEvent) bool {
+			return !e.Time.After(start)
+		})
+		if len(events) <= r.threshold {
+			r.pending[group] = events
+			return Alert{}, false
+		}
+		delete(r.pending, group)
+	}
+
+	key := r.id
+	if group != "" {
+		key += ":" + group
+	}
+	if last, ok := r.fired[key]; ok && r.suppress > 0 && event.Time.Sub(last) < r.suppress {
+		return Alert{}, false
+	}
+	r.fired[key] = event.Time
+
+	alert := Alert{
+		Time:        event.Time,
+		RuleID:      r.id,
+		Severity:    r.severity,
+		Description: r.description,
+		DedupKey:    key,
+		Count:       len(events),
+		Events:      events,
+	}
+	if len(alert.Events) > MaxAlertEvents {
+		alert.Events = alert.Events[len(alert.Events)-MaxAlertEvents:]
+	}
+	return alert, true
+}
+
+// Expire forgets the events counted towards the threshold and the dedup
+// keys that can no longer matter at now, so groups that stopped matching
+// do not pile up.
+func (r *Rule) Expire(now time.Time) {
+	for group, events := range r.pending {
+		if !events[len(events)-1].Time.After(now.Add(-r.window)) {
+			delete(r.pending, group)
+		}
+	}
+	for key, last := range r.fired {
+		if !last.After(now.Add(-r.suppress)) {
+			delete(r.fired, key)
+		}
+	}
+}
+
+// Match reports whether event meets every condition of the rule.
+func (r *Rule) Match(event monitoring.FileEvent) bool {
+	switch {
+	case len(r.prefixes)+len(r.globs) > 0 && !r.matchPath(event.TargetPath):
+		return false
+	case len(r.actions) > 0 && !slices.Contains(r.actions, event.Action):
+		return false
+	case len(r.categories) > 0 && !slices.Contains(r.categories, event.Category):
+		return false
+	case len(r.uids) > 0 && (event.UID == nil || !slices.Contains(r.uids, *event.UID)):
+		return false
+	case len(r.hashes) > 0 && !r.matchHash(event.Hashes):
+		return false
+	case r.hours != nil && !r.hours.contains(event.Time):
+		return false
+	}
+	return true
+}
+
+// matchPath matches p against the globs, and against the prefixes as whole
+// directories, so /etc/ssh does not match /etc/sshd.
+func (r *Rule) matchPath(p string) bool {
+	for _, prefix := range r.prefixes {
+		if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	for _, glob := range r.globs {
+		if glob.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchHash(hashes *monitoring.Hashes) bool {
+	if hashes == nil {
+		return false
+	}
+	for _, hash := range []string{hashes.MD5, hashes.SHA1, hashes.SHA256} {
+		if hash != "" && slices.Contains(r.hashes, strings.ToLower(hash)) {
+			return true
+		}
+	}
+	return false
+}
+
+// group returns the value of the rule's group_by field for event.
+func (r *Rule) group(event monitoring.FileEvent) string {
+	switch r.groupBy {
+	case GroupByPath:
+		return event.TargetPath
+	case GroupByDirectory:
+		return path.Dir(event.TargetPath)
+	case GroupByCategory:
+		return event.Category
+	case GroupByUID:
+		if event.UID == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*event.UID), 10)
+	default:
+		return ""
+	}
+}
+
+// parseTimeOfDay parses a range such as "22:00-06:00" in the named time
+// zone, the local one if empty.
+func parseTimeOfDay(hours, timezone string) (*timeOfDay, error) {
+	loc := time.Local
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid hours %q: want a range such as 22:00-06:00", hours)
+	}
+	t := &timeOfDay{loc: loc}
+	for _, bound := range []struct {
+		value string
+		into  *time.Duration
+	}{{from, &t.from}, {to, &t.to}} {
+		clock, err := time.Parse("15:04", strings.TrimSpace(bound.value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hours %q: want a range such as 22:00-06:00", hours)
+		}
+		*bound.into = time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute
+	}
+	return t, nil
+}
+
+// contains reports whether t falls in the range, which includes its start
+// and excludes its end.
+func (d *timeOfDay) contains(t time.Time) bool {
+	t = t.In(d.loc)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if d.from <= d.to {
+		return offset >= d.from && offset < d.to
+	}
+	return offset >= d.from || offset < d.to
+}
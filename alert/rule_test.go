@@ -0,0 +1,147 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func event(path string, action monitoring.Action, t time.Time) monitoring.FileEvent {
+	return monitoring.FileEvent{Time: t, Action: action, Path: path, TargetPath: path}
+}
+
+func TestRuleMatch(t *testing.T) {
+	uid := uint32(0)
+	night := time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)
+	base := monitoring.FileEvent{
+		Time:       night,
+		Action:     monitoring.ActionUpdated,
+		Path:       "/etc/ssh/sshd_config",
+		TargetPath: "/etc/ssh/sshd_config",
+		Category:   "etc",
+		UID:        &uid,
+		Hashes:     &monitoring.Hashes{SHA256: "ABC123"},
+	}
+
+	tests := []struct {
+		name  string
+		rule  config.Rule
+		event func(monitoring.FileEvent) monitoring.FileEvent
+		match bool
+	}{
+		{"empty rule", config.Rule{}, nil, true},
+		{"prefix", config.Rule{Paths: []string{"/etc/ssh/"}}, nil, true},
+		{"glob", config.Rule{Paths: []string{"/var/**", "/etc/**/*_config"}}, nil, true},
+		{"other path", config.Rule{Paths: []string{"/srv/"}}, nil, false},
+		{"directory prefix", config.Rule{Paths: []string{"/etc/ssh"}}, nil, true},
+		{"file prefix", config.Rule{Paths: []string{"/etc/ssh/sshd_config"}}, nil, true},
+		{"sibling directory", config.Rule{Paths: []string{"/etc/ss"}}, nil, false},
+		{"sibling file", config.Rule{Paths: []string{"/etc/ssh/sshd"}}, nil, false},
+		{"action", config.Rule{Actions: []string{"updated", "DELETED"}}, nil, true},
+		{"other action", config.Rule{Actions: []string{"DELETED"}}, nil, false},
+		{"category", config.Rule{Categories: []string{"etc"}}, nil, true},
+		{"other category", config.Rule{Categories: []string{"homes"}}, nil, false},
+		{"owner", config.Rule{UIDs: []uint32{0}}, nil, true},
+		{"other owner", config.Rule{UIDs: []uint32{1000}}, nil, false},
+		{"unknown owner", config.Rule{UIDs: []uint32{0}}, func(e monitoring.FileEvent) monitoring.FileEvent { e.UID = nil; return e }, false},
+		{"hash", config.Rule{Hashes: []string{"abc123"}}, nil, true},
+		{"no hash", config.Rule{Hashes: []string{"abc123"}}, func(e monitoring.FileEvent) monitoring.FileEvent { e.Hashes = nil; return e }, false},
+		{"overnight hours", config.Rule{Hours: "22:00-06:00", Timezone: "UTC"}, nil, true},
+		{"daytime hours", config.Rule{Hours: "09:00-17:00", Timezone: "UTC"}, nil, false},
+		{"hours in time zone", config.Rule{Hours: "08:00-12:00", Timezone: "Asia/Tokyo"}, nil, true},
+		{"end of hours", config.Rule{Hours: "22:00-23:30", Timezone: "UTC"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.ID = "test"
+			rule, err := NewRule(tt.rule)
+			require.NoError(t, err)
+			e := base
+			if tt.event != nil {
+				e = tt.event(e)
+			}
+			assert.Equal(t, tt.match, rule.Match(e))
+		})
+	}
+}
+
+func TestNewRuleErrors(t *testing.T) {
+	for _, cfg := range []config.Rule{
+		{},
+		{ID: "threshold", Threshold: 5},
+		{ID: "hours", Hours: "22:00"},
+		{ID: "clock", Hours: "22:00-25:00"},
+		{ID: "zone", Hours: "22:00-06:00", Timezone: "Mars/Olympus"},
+	} {
+		_, err := NewRule(cfg)
+		assert.Error(t, err, cfg.ID)
+	}
+}
+
+func TestRuleSingleEvent(t *testing.T) {
+	rule, err := NewRule(config.Rule{ID: "passwd", Severity: "critical", Paths: []string{"/etc/passwd"}, GroupBy: GroupByPath, Suppress: time.Minute})
+	require.NoError(t, err)
+	start := time.Unix(1700000000, 0)
+
+	alert, ok := rule.Observe(event("/etc/passwd", monitoring.ActionUpdated, start))
+	require.True(t, ok)
+	assert.Equal(t, "passwd", alert.RuleID)
+	assert.Equal(t, "critical", alert.Severity)
+	assert.Equal(t, "passwd:/etc/passwd", alert.DedupKey)
+	assert.Equal(t, 1, alert.Count)
+	assert.Len(t, alert.Events, 1)
+
+	// The same key is suppressed until a minute has passed.
+	_, ok = rule.Observe(event("/etc/passwd", monitoring.ActionUpdated, start.Add(30*time.Second)))
+	assert.False(t, ok)
+	_, ok = rule.Observe(event("/etc/passwd", monitoring.ActionUpdated, start.Add(time.Minute)))
+	assert.True(t, ok)
+	_, ok = rule.Observe(event("/etc/group", monitoring.ActionUpdated, start))
+	assert.False(t, ok)
+}
+
+func TestRuleThreshold(t *testing.T) {
+	rule, err := NewRule(config.Rule{
+		ID:        "mass-delete",
+		Paths:     []string{"/srv/"},
+		Actions:   []string{"DELETED"},
+		Threshold: 3,
+		Window:    time.Minute,
+		GroupBy:   GroupByDirectory,
+	})
+	require.NoError(t, err)
+	start := time.Unix(1700000000, 0)
+
+	// Deletes spread over more than a minute never exceed the threshold.
+	for i := 0; i < 6; i++ {
+		_, ok := rule.Observe(event("/srv/a/file", monitoring.ActionDeleted, start.Add(time.Duration(i)*40*time.Second)))
+		assert.False(t, ok)
+	}
+
+	// Directories are counted apart.
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		_, ok := rule.Observe(event("/srv/a/file", monitoring.ActionDeleted, later.Add(time.Duration(i)*time.Second)))
+		assert.False(t, ok)
+		_, ok = rule.Observe(event("/srv/b/file", monitoring.ActionDeleted, later.Add(time.Duration(i)*time.Second)))
+		assert.False(t, ok)
+	}
+	alert, ok := rule.Observe(event("/srv/a/other", monitoring.ActionDeleted, later.Add(10*time.Second)))
+	require.True(t, ok)
+	assert.Equal(t, "mass-delete:/srv/a", alert.DedupKey)
+	assert.Equal(t, 4, alert.Count)
+	assert.Equal(t, "/srv/a/other", alert.Events[3].TargetPath)
+	assert.Equal(t, later.Add(10*time.Second), alert.Time)
+
+	// Counting starts over once the rule fired.
+	_, ok = rule.Observe(event("/srv/a/file", monitoring.ActionDeleted, later.Add(11*time.Second)))
+	assert.False(t, ok)
+
+	rule.Expire(later.Add(2 * time.Minute))
+	assert.Empty(t, rule.pending)
+}
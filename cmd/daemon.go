@@ -16,11 +16,16 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/tejiriaustin/savannah-assessment/alert"
 	"github.com/tejiriaustin/savannah-assessment/config"
 	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/format"
 	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/metrics"
 	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/output"
 	"github.com/tejiriaustin/savannah-assessment/server"
+	"github.com/tejiriaustin/savannah-assessment/tracing"
 )
 
 var serviceCmd = &cobra.Command{
@@ -43,23 +48,39 @@ func startDaemonService(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	monitorClient, err := monitoring.New(cfg.OsqueryConfig, monitoring.WithLogger(log))
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing", "error", err)
+	}
+
+	monitorClient, err := monitoring.NewFromConfig(cfg, log)
 	if err != nil {
 		log.Fatal("Failed to create monitoring client", "error", err)
 	}
 
+	alertEngine, alerts, err := alert.NewFromConfig(cfg, monitorClient, log)
+	if err != nil {
+		log.Fatal("Failed to set up alert rules", "error", err)
+	}
+
+	forwarders, err := output.NewFromConfig(cfg, monitorClient, alerts, log)
+	if err != nil {
+		log.Fatal("Failed to set up outputs", "error", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	var (
 		wg      sync.WaitGroup
-		errChan = make(chan error, 2)
+		errChan = make(chan error, 3+len(forwarders))
 		cmdChan = make(chan daemon.Command, 100)
 	)
+	metrics.CommandQueue(func() int { return len(cmdChan) }, cap(cmdChan))
 
-	wg.Add(2)
+	wg.Add(2 + len(forwarders))
 	go func() {
 		defer wg.Done()
-		if err := startServer(ctx, log, cfg, monitorClient, cmdChan); err != nil {
+		if err := startServer(ctx, log, cfg, monitorClient, alerts, cmdChan); err != nil {
 			errChan <- fmt.Errorf("server error: %w", err)
 		}
 	}()
@@ -71,6 +92,25 @@ func startDaemonService(cmd *cobra.Command, args []string) {
 		}
 	}()
 
+	for _, forwarder := range forwarders {
+		go func(forwarder *output.Forwarder) {
+			defer wg.Done()
+			if err := forwarder.Run(ctx); err != nil {
+				errChan <- fmt.Errorf("output %s error: %w", forwarder.Name(), err)
+			}
+		}(forwarder)
+	}
+
+	if alertEngine != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := alertEngine.Run(ctx); err != nil {
+				errChan <- fmt.Errorf("alert rules error: %w", err)
+			}
+		}()
+	}
+
 	go func() {
 		wg.Wait()
 		close(errChan)
@@ -106,12 +146,38 @@ func startDaemonService(cmd *cobra.Command, args []string) {
 		log.Info("Shutdown timed out")
 	}
 
+	for _, forwarder := range forwarders {
+		if err := forwarder.Close(); err != nil {
+			log.Error("Failed to close output", "output", forwarder.Name(), "error", err)
+		}
+	}
+	if alerts != nil {
+		if err := alerts.Close(); err != nil {
+			log.Error("Failed to close alert store", "error", err)
+		}
+	}
+	if err := monitorClient.Close(); err != nil {
+		log.Error("Failed to close monitoring client", "error", err)
+	}
+	tracingCtx, tracingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer tracingCancel()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		log.Error("Failed to flush traces", "error", err)
+	}
+
 	log.Info("Daemon service stopped")
 }
 
-func startServer(ctx context.Context, log *logger.Logger, cfg *config.Config, monitorClient monitoring.Monitor, cmdChan chan daemon.Command) error {
+func startServer(ctx context.Context, log *logger.Logger, cfg *config.Config, monitorClient monitoring.Monitor, alerts *alert.Store, cmdChan chan daemon.Command) error {
 
-	h := server.NewHandler(log).SetupHandler(monitorClient, cmdChan)
+	h := server.NewHandler(log,
+		server.WithStreamBuffer(cfg.Stream.Buffer, cfg.Stream.SlowConsumers == "drop"),
+		server.WithHeartbeat(cfg.Stream.Heartbeat),
+		server.WithStreamPollInterval(cfg.Stream.PollInterval),
+		server.WithFormatOptions(format.OptionsFromConfig(cfg)),
+		server.WithTracing(cfg.Tracing.ServiceName),
+		server.WithAlerts(alerts),
+	).SetupHandler(monitorClient, cmdChan)
 
 	err := server.New(cfg, log).Start(h)
 	if err != nil {
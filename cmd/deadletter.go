@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/output"
+)
+
+var deadLetterOutput string
+
+var deadLetterCmd = &cobra.Command{
+	Use:   "deadletter",
+	Short: "Inspect and replay webhook deliveries that failed",
+	Long: `Webhook batches that could not be delivered after every retry are kept in
+a dead-letter queue per output. These commands list, show, replay and drop them.`,
+}
+
+var deadLetterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List failed deliveries, oldest first",
+	Run: func(cmd *cobra.Command, args []string) {
+		webhooks := configuredWebhooks()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tOUTPUT\tFAILED AT\tATTEMPTS\tEVENTS\tALERTS\tERROR")
+		for _, webhook := range webhooks {
+			letters, err := webhook.DeadLetters().List()
+			if err != nil {
+				log.Error("Failed to read dead-letter queue", "error", err)
+				os.Exit(1)
+			}
+			for _, letter := range letters {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", letter.ID, letter.Output,
+					letter.FailedAt.Format(time.RFC3339), letter.Attempts, letter.Events, letter.Alerts, letter.Error)
+			}
+		}
+		_ = w.Flush()
+	},
+}
+
+var deadLetterShowCmd = &cobra.Command{
+	Use:   "show ID",
+	Short: "Print a failed delivery with its payload",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, letter := findLetter(configuredWebhooks(), args[0])
+		fmt.Printf("ID:           %s\n", letter.ID)
+		fmt.Printf("Output:       %s\n", letter.Output)
+		fmt.Printf("Failed at:    %s\n", letter.FailedAt.Format(time.RFC3339))
+		fmt.Printf("Attempts:     %d\n", letter.Attempts)
+		fmt.Printf("Events:       %d\n", letter.Events)
+		fmt.Printf("Alerts:       %d\n", letter.Alerts)
+		fmt.Printf("Error:        %s\n", letter.Error)
+		fmt.Printf("Content type: %s\n\n", letter.ContentType)
+		fmt.Println(strings.TrimRight(letter.Payload, "\n"))
+	},
+}
+
+var deadLetterReplayCmd = &cobra.Command{
+	Use:   "replay [ID...]",
+	Short: "Send failed deliveries again, all of them if no ID is given",
+	Long: `Send failed deliveries again with a fresh signature. Delivered batches are
+removed from the queue; batches that fail again stay with their error updated.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		webhooks := configuredWebhooks()
+		type pending struct {
+			webhook *output.Webhook
+			letter  output.Letter
+		}
+		var replays []pending
+		if len(args) > 0 {
+			for _, id := range args {
+				webhook, letter := findLetter(webhooks, id)
+				replays = append(replays, pending{webhook, letter})
+			}
+		} else {
+			for _, webhook := range webhooks {
+				letters, err := webhook.DeadLetters().List()
+				if err != nil {
+					log.Error("Failed to read dead-letter queue", "error", err)
+					os.Exit(1)
+				}
+				for _, letter := range letters {
+					replays = append(replays, pending{webhook, letter})
+				}
+			}
+		}
+
+		failed := 0
+		for _, replay := range replays {
+			if err := replay.webhook.Replay(ctx, replay.letter); err != nil {
+				fmt.Printf("%s: failed: %v\n", replay.letter.ID, err)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: delivered\n", replay.letter.ID)
+		}
+		if failed > 0 {
+			log.Error(fmt.Sprintf("%d of %d deliveries failed again", failed, len(replays)))
+			os.Exit(1)
+		}
+	},
+}
+
+var deadLetterDropCmd = &cobra.Command{
+	Use:   "drop ID...",
+	Short: "Remove failed deliveries without sending them",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		webhooks := configuredWebhooks()
+		for _, id := range args {
+			webhook, _ := findLetter(webhooks, id)
+			if err := webhook.DeadLetters().Remove(id); err != nil {
+				log.Error("Failed to drop dead letter", "id", id, "error", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// configuredWebhooks returns the webhook outputs in the config, or only the
+// one named by --output.
+func configuredWebhooks() []*output.Webhook {
+	cfg := config.GetConfig()
+	var webhooks []*output.Webhook
+	for _, out := range cfg.Outputs {
+		if out.Type != "webhook" || (deadLetterOutput != "" && out.Name != deadLetterOutput) {
+			continue
+		}
+		webhook, err := output.NewWebhookFromConfig(cfg, out, log)
+		if err != nil {
+			log.Error("Failed to open webhook output", "error", err)
+			os.Exit(1)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if deadLetterOutput != "" && len(webhooks) == 0 {
+		log.Error(fmt.Sprintf("No webhook output named %q", deadLetterOutput))
+		os.Exit(1)
+	}
+	return webhooks
+}
+
+func findLetter(webhooks []*output.Webhook, id string) (*output.Webhook, output.Letter) {
+	for _, webhook := range webhooks {
+		letter, err := webhook.DeadLetters().Get(id)
+		if err == nil {
+			return webhook, letter
+		}
+		if !errors.Is(err, output.ErrLetterNotFound) {
+			log.Error("Failed to read dead letter", "id", id, "error", err)
+			os.Exit(1)
+		}
+	}
+	log.Error(fmt.Sprintf("No dead letter %s", id))
+	os.Exit(1)
+	return nil, output.Letter{}
+}
+
+func init() {
+	deadLetterCmd.PersistentFlags().StringVar(&deadLetterOutput, "output", "", "only this webhook output")
+
+	rootCmd.AddCommand(deadLetterCmd)
+	deadLetterCmd.AddCommand(deadLetterListCmd)
+	deadLetterCmd.AddCommand(deadLetterShowCmd)
+	deadLetterCmd.AddCommand(deadLetterReplayCmd)
+	deadLetterCmd.AddCommand(deadLetterDropCmd)
+}
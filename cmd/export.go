@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/server"
+)
+
+// dateLayout is the layout of --since and --until given as a plain date.
+const dateLayout = "2006-01-02"
+
+var exportFlags struct {
+	format     string
+	gzip       bool
+	output     string
+	server     string
+	since      string
+	until      string
+	path       string
+	actions    []string
+	categories []string
+	uid        string
+	hash       string
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export events to CSV or JSON Lines",
+	Long: `Export the events the daemon has collected, oldest first, to a file or stdout.
+Events are streamed from the daemon's /events/export endpoint and written as they
+arrive, so exports of any size run in constant memory. A file is only put in place
+once the export is complete.
+
+Times are RFC 3339, Unix seconds or a date. A date covers the whole day, so
+  export --path /etc/ --since 2024-07-01 --until 2024-09-30 -o q3.csv
+exports every change under /etc in the third quarter of 2024.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		count, err := exportEvents(ctx)
+		if err != nil {
+			log.Error("Export failed", "error", err)
+			os.Exit(1)
+		}
+		if exportFlags.output != "-" {
+			fmt.Fprintf(os.Stderr, "Exported %s events to %s\n", count, exportFlags.output)
+		}
+	},
+}
+
+func init() {
+	flags := exportCmd.Flags()
+	flags.StringVar(&exportFlags.format, "format", "jsonl", "csv, jsonl, cef, leef, ecs or ocsf")
+	flags.BoolVar(&exportFlags.gzip, "gzip", false, "gzip the output; implied by an --output ending in .gz")
+	flags.StringVarP(&exportFlags.output, "output", "o", "-", "file to write, - for stdout")
+	flags.StringVar(&exportFlags.server, "server", "", "URL of the daemon (default http://localhost and the configured port)")
+	flags.StringVar(&exportFlags.since, "since", "", "only events after this time")
+	flags.StringVar(&exportFlags.until, "until", "", "only events up to this time")
+	flags.StringVar(&exportFlags.path, "path", "", "path prefix or glob the events' target path must match")
+	flags.StringSliceVar(&exportFlags.actions, "action", nil, "only these actions")
+	flags.StringSliceVar(&exportFlags.categories, "category", nil, "only these categories")
+	flags.StringVar(&exportFlags.uid, "uid", "", "only files owned by this uid")
+	flags.StringVar(&exportFlags.hash, "hash", "", "only files with this md5, sha1 or sha256")
+	rootCmd.AddCommand(exportCmd)
+}
+
+// exportEvents copies the export to the output and returns the number of
+// events the daemon reports having sent.
+func exportEvents(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, exportURL(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the daemon: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+		return "", fmt.Errorf("daemon answered %s: %s", resp.Status, body.Error)
+	}
+
+	out, commit, err := createExportFile(exportFlags.output)
+	if err != nil {
+		return "", err
+	}
+	w := io.Writer(out)
+	var zw *gzip.Writer
+	if exportFlags.gzip || strings.HasSuffix(exportFlags.output, ".gz") {
+		zw = gzip.NewWriter(out)
+		w = zw
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	// Trailers are only known once the body has been read.
+	if err == nil {
+		if message := resp.Trailer.Get(server.ExportErrorTrailer); message != "" {
+			err = errors.New(message)
+		} else if resp.Trailer.Get(server.ExportCountTrailer) == "" {
+			err = errors.New("the daemon ended the export early")
+		}
+	}
+	if err := commit(err); err != nil {
+		return "", err
+	}
+	return resp.Trailer.Get(server.ExportCountTrailer), nil
+}
+
+func exportURL() string {
+	base := exportFlags.server
+	if base == "" {
+		base = fmt.Sprintf("http://localhost%s", config.GetConfig().Port)
+	}
+
+	format := exportFlags.format
+	if format == "jsonl" {
+		format = "json"
+	}
+	params := url.Values{"format": {format}}
+	for name, value := range map[string]string{
+		"path": exportFlags.path,
+		"uid":  exportFlags.uid,
+		"hash": exportFlags.hash,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	for _, action := range exportFlags.actions {
+		params.Add("action", action)
+	}
+	for _, category := range exportFlags.categories {
+		params.Add("category", category)
+	}
+	if exportFlags.since != "" {
+		// The daemon excludes events at since; a day starts just after the
+		// end of the one before.
+		params.Set("since", exportTime(exportFlags.since, -time.Nanosecond))
+	}
+	if exportFlags.until != "" {
+		params.Set("until", exportTime(exportFlags.until, 24*time.Hour-time.Nanosecond))
+	}
+	return strings.TrimSuffix(base, "/") + "/events/export?" + params.Encode()
+}
+
+// exportTime passes times on to the daemon, turning a date into the time
+// offset from its midnight in UTC.
+func exportTime(value string, offset time.Duration) string {
+	day, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return value
+	}
+	return day.Add(offset).Format(time.RFC3339Nano)
+}
+
+// createExportFile opens the destination of an export. Files are written
+// under a temporary name that commit renames into place if the export
+// succeeded and removes otherwise.
+func createExportFile(name string) (io.Writer, func(error) error, error) {
+	if name == "-" {
+		return os.Stdout, func(err error) error { return err }, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	commit := func(err error) error {
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), 0640)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), name)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+		}
+		return err
+	}
+	return tmp, commit, nil
+}
@@ -79,8 +79,14 @@ var configViewCmd = &cobra.Command{
 		fmt.Println("Current configuration:")
 		fmt.Printf("Monitor directory: %s\n", viper.GetString("monitor_dir"))
 		fmt.Printf("Check frequency: %s\n", viper.GetDuration("check_frequency"))
-		fmt.Printf("API endpoint: %s\n", viper.GetString("api_endpoint"))
 		fmt.Printf("Osquery socket: %s\n", viper.GetString("osquery_socket"))
+		fmt.Printf("Monitor backend: %s\n", viper.GetString("monitor_backend"))
+		for _, watch := range config.GetConfig().WatchList() {
+			fmt.Printf("Watch: %s (label: %s, recursive: %t, severity: %s)\n", watch.Path, watch.Label, watch.Recursive, watch.Severity)
+		}
+		for _, out := range config.GetConfig().Outputs {
+			fmt.Printf("Output: %s (type: %s, format: %s)\n", out.Name, out.Type, out.Format)
+		}
 	},
 }
 
@@ -106,13 +112,10 @@ var ConfigureOsqueryCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.GetConfig()
 
-		monitorClient, err := monitoring.New(
-			cfg.OsqueryConfig,
-			monitoring.WithLogger(log),
-			monitoring.WithMonitorDirs([]string{cfg.MonitoredDirectory}),
-		)
+		monitorClient, err := monitoring.NewOsqueryFromConfig(cfg, log)
 		if err != nil {
 			log.Error("Failed to create monitoring client: ", err.Error())
+			return
 		}
 		err = monitorClient.UpdateOrCreateJSONFile("/var/osquery/osquery.conf") // filePath is left as a magic variable because it serves no other purpose in this codebase
 		if err != nil {
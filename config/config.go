@@ -17,16 +17,243 @@ import (
 	"github.com/tejiriaustin/savannah-assessment/logger"
 )
 
-type Config struct {
-	ConfigPath         string
-	Port               string        `mapstructure:"port"`
-	MonitoredDirectory string        `mapstructure:"monitored_directory"`
-	CheckFrequency     time.Duration `mapstructure:"check_frequency"`
-	OsqueryConfig      string        `mapstructure:"osquery_config"`
-	OsquerySocket      string        `mapstructure:"osquery_socket"`
-	PidFilePath        string        `mapstructure:"pid_file_path"`
-	mutex              sync.RWMutex
-}
+type (
+	Config struct {
+		ConfigPath         string
+		Port               string         `mapstructure:"port"`
+		MonitoredDirectory string         `mapstructure:"monitored_directory"`
+		Watches            []Watch        `mapstructure:"watches" validate:"dive"`
+		Ignore             IgnoreRules    `mapstructure:"ignore"`
+		Coalesce           Coalesce       `mapstructure:"coalesce"`
+		Store              Store          `mapstructure:"store"`
+		Stream             Stream         `mapstructure:"stream"`
+		Outputs            []Output       `mapstructure:"outputs" validate:"unique=Name,dive"`
+		OutputDir          string         `mapstructure:"output_dir"`
+		Formats            Formats        `mapstructure:"formats"`
+		CheckFrequency     time.Duration  `mapstructure:"check_frequency"`
+		OsqueryConfig      string         `mapstructure:"osquery_config"`
+		OsquerySocket      string         `mapstructure:"osquery_socket"`
+		PidFilePath        string         `mapstructure:"pid_file_path"`
+		MonitorBackend     string         `mapstructure:"monitor_backend" validate:"required"`
+		Osquery            OsqueryBackend `mapstructure:"osquery"`
+		Native             NativeBackend  `mapstructure:"native"`
+		Polling            PollingBackend `mapstructure:"polling"`
+		Tracing            Tracing        `mapstructure:"tracing"`
+		Alerts             Alerts         `mapstructure:"alerts"`
+		mutex              sync.RWMutex
+	}
+
+	// Watch is one entry of the watches list. Path is a directory or glob,
+	// and Label becomes the category of every event under it.
+	Watch struct {
+		Path      string `mapstructure:"path" validate:"required"`
+		Recursive bool   `mapstructure:"recursive"`
+		Label     string `mapstructure:"label"`
+		Severity  string `mapstructure:"severity" validate:"omitempty,oneof=info low medium high critical"`
+		Hash      *bool  `mapstructure:"hash"`
+	}
+
+	// IgnoreRules drops events for matching paths before they are served or
+	// exported. Patterns use .gitignore syntax and are anchored at the
+	// filesystem root; File names the per-directory ignore file to honour.
+	IgnoreRules struct {
+		Patterns []string `mapstructure:"patterns"`
+		File     string   `mapstructure:"file"`
+	}
+
+	// Coalesce merges the raw events of one save or move into a single
+	// logical event. Events within Window of each other that share a path,
+	// inode or content hash are merged; a zero Window serves raw events.
+	Coalesce struct {
+		Window time.Duration `mapstructure:"window" validate:"gte=0"`
+	}
+
+	// Store keeps every collected event on disk, so history outlives the
+	// backend's own retention and tracker restarts. An empty Path disables it
+	// and events are served from the backend directly. Zero limits are
+	// unlimited.
+	Store struct {
+		Path            string        `mapstructure:"path"`
+		MaxAge          time.Duration `mapstructure:"max_age" validate:"gte=0"`
+		MaxSizeMB       int64         `mapstructure:"max_size_mb" validate:"gte=0"`
+		MaxEvents       int           `mapstructure:"max_events" validate:"gte=0"`
+		CollectInterval time.Duration `mapstructure:"collect_interval" validate:"gt=0"`
+		CompactInterval time.Duration `mapstructure:"compact_interval" validate:"gte=0"`
+	}
+
+	// Stream configures /events/stream. Each client gets Buffer events of
+	// slack; a client further behind is disconnected, or with SlowConsumers
+	// "drop" loses the events that do not fit. PollInterval is how often new
+	// events are looked for when the monitor cannot report them itself.
+	Stream struct {
+		Buffer        int           `mapstructure:"buffer" validate:"gt=0"`
+		Heartbeat     time.Duration `mapstructure:"heartbeat" validate:"gt=0"`
+		PollInterval  time.Duration `mapstructure:"poll_interval" validate:"gt=0"`
+		SlowConsumers string        `mapstructure:"slow_consumers" validate:"oneof=disconnect drop"`
+	}
+
+	// Output forwards collected events to an external system. Type selects
+	// the destination, which reads its own block of options, and Format how
+	// events are encoded. With Alerts it also forwards the alerts raised by
+	// alert rules. Each output keeps how far it got, and its dead letters,
+	// under output_dir/Name.
+	Output struct {
+		Name          string        `mapstructure:"name" validate:"required"`
+		Type          string        `mapstructure:"type" validate:"oneof=webhook syslog file"`
+		Format        string        `mapstructure:"format" validate:"omitempty,oneof=json cef leef ecs ocsf"`
+		Alerts        bool          `mapstructure:"alerts"`
+		BatchSize     int           `mapstructure:"batch_size" validate:"gte=0"`
+		FlushInterval time.Duration `mapstructure:"flush_interval" validate:"gte=0"`
+		Webhook       WebhookOutput `mapstructure:"webhook"`
+		Syslog        SyslogOutput  `mapstructure:"syslog"`
+		File          FileOutput    `mapstructure:"file"`
+	}
+
+	// FileOutput configures an output of type "file", which appends one
+	// record per line to Path.
+	FileOutput struct {
+		Path string `mapstructure:"path"`
+	}
+
+	// Formats describes the tracker in CEF and LEEF headers. SignatureIDs
+	// maps event actions to the CEF signature ID and LEEF event ID; actions
+	// without an entry use their name. AlertSignatureIDs maps alert rule
+	// IDs or severities to the IDs of alerts, which otherwise use the rule
+	// ID.
+	Formats struct {
+		Vendor            string            `mapstructure:"vendor"`
+		Product           string            `mapstructure:"product"`
+		Version           string            `mapstructure:"version"`
+		SignatureIDs      map[string]string `mapstructure:"signature_ids"`
+		AlertSignatureIDs map[string]string `mapstructure:"alert_signature_ids"`
+	}
+
+	// WebhookOutput configures an output of type "webhook". Requests are
+	// signed with Secret. A nil MaxRetries uses the default.
+	WebhookOutput struct {
+		URL            string            `mapstructure:"url"`
+		Secret         string            `mapstructure:"secret"`
+		Headers        map[string]string `mapstructure:"headers"`
+		Timeout        time.Duration     `mapstructure:"timeout" validate:"gte=0"`
+		MaxRetries     *int              `mapstructure:"max_retries" validate:"omitempty,gte=0"`
+		InitialBackoff time.Duration     `mapstructure:"initial_backoff" validate:"gte=0"`
+		MaxBackoff     time.Duration     `mapstructure:"max_backoff" validate:"gte=0"`
+	}
+
+	// SyslogOutput configures an output of type "syslog". Network "tls" is
+	// TCP with TLS, verified against CAFile when set and the system roots
+	// otherwise; CertFile and KeyFile add a client certificate.
+	SyslogOutput struct {
+		Network        string        `mapstructure:"network" validate:"omitempty,oneof=udp tcp tls"`
+		Address        string        `mapstructure:"address"`
+		Facility       string        `mapstructure:"facility"`
+		AppName        string        `mapstructure:"app_name"`
+		Hostname       string        `mapstructure:"hostname"`
+		CAFile         string        `mapstructure:"ca_file"`
+		CertFile       string        `mapstructure:"cert_file"`
+		KeyFile        string        `mapstructure:"key_file"`
+		ServerName     string        `mapstructure:"server_name"`
+		Timeout        time.Duration `mapstructure:"timeout" validate:"gte=0"`
+		InitialBackoff time.Duration `mapstructure:"initial_backoff" validate:"gte=0"`
+		MaxBackoff     time.Duration `mapstructure:"max_backoff" validate:"gte=0"`
+	}
+
+	// OsqueryBackend configures the "osquery" monitor backend. Mode selects
+	// between driving osqueryi over stdin ("osqueryi"), querying a running
+	// osqueryd over osquery_socket ("socket") and launching osqueryd and
+	// tailing its results log ("managed").
+	OsqueryBackend struct {
+		Mode          string        `mapstructure:"mode" validate:"oneof=osqueryi socket managed"`
+		Binary        string        `mapstructure:"binary"`
+		DaemonBinary  string        `mapstructure:"daemon_binary"`
+		DatabasePath  string        `mapstructure:"database_path"`
+		LoggerPath    string        `mapstructure:"logger_path"`
+		MaxRetries    int           `mapstructure:"max_retries" validate:"gte=0"`
+		SocketTimeout time.Duration `mapstructure:"socket_timeout"`
+		Supervisor    Supervisor    `mapstructure:"supervisor"`
+
+		// FilePaths adds osquery file_paths categories next to the monitored
+		// directory, which is always watched as "homes". ExcludePaths and
+		// FileAccesses refer to these categories by name.
+		FilePaths    map[string][]string `mapstructure:"file_paths"`
+		ExcludePaths map[string][]string `mapstructure:"exclude_paths"`
+		FileAccesses []string            `mapstructure:"file_accesses"`
+		EventsExpiry time.Duration       `mapstructure:"events_expiry" validate:"gte=0"`
+		EventsMax    int                 `mapstructure:"events_max" validate:"gte=0"`
+	}
+
+	// Supervisor configures how a crashed osqueryi or osqueryd is restarted.
+	// After max_crashes crashes within crash_window the process is left down
+	// and the daemon reports monitoring as failed.
+	Supervisor struct {
+		InitialBackoff time.Duration `mapstructure:"initial_backoff" validate:"gt=0"`
+		MaxBackoff     time.Duration `mapstructure:"max_backoff" validate:"gtefield=InitialBackoff"`
+		Multiplier     float64       `mapstructure:"multiplier" validate:"gte=1"`
+		Jitter         float64       `mapstructure:"jitter" validate:"gte=0,lte=1"`
+		MaxCrashes     int           `mapstructure:"max_crashes" validate:"gte=0"`
+		CrashWindow    time.Duration `mapstructure:"crash_window" validate:"gt=0"`
+	}
+
+	// NativeBackend configures the inotify based "native" monitor backend.
+	NativeBackend struct {
+		HashFiles bool `mapstructure:"hash_files"`
+	}
+
+	// Tracing exports OpenTelemetry traces of HTTP requests, commands and
+	// osquery queries. Exporter "otlp" sends them to the collector at
+	// Endpoint over Protocol, "stdout" prints them for local debugging, and
+	// no Exporter turns tracing off. SampleRatio is the fraction of traces
+	// started by the tracker that are kept.
+	Tracing struct {
+		Exporter    string            `mapstructure:"exporter" validate:"omitempty,oneof=otlp stdout"`
+		Endpoint    string            `mapstructure:"endpoint"`
+		Protocol    string            `mapstructure:"protocol" validate:"oneof=grpc http"`
+		Insecure    bool              `mapstructure:"insecure"`
+		Headers     map[string]string `mapstructure:"headers"`
+		ServiceName string            `mapstructure:"service_name" validate:"required"`
+		SampleRatio float64           `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+	}
+
+	// Alerts evaluates Rules against every collected event and keeps the
+	// alerts they raise in a store at Path, dropping those older than
+	// MaxAge. New events are evaluated every Interval. Without rules
+	// alerting is off.
+	Alerts struct {
+		Path     string        `mapstructure:"path"`
+		MaxAge   time.Duration `mapstructure:"max_age" validate:"gte=0"`
+		Interval time.Duration `mapstructure:"interval" validate:"gt=0"`
+		Rules    []Rule        `mapstructure:"rules" validate:"unique=ID,dive"`
+	}
+
+	// Rule raises an alert for events matching all of its conditions; empty
+	// conditions match every event. Paths are prefixes or globs, Hours a
+	// range of the time of day such as "22:00-06:00" in Timezone. With a
+	// Threshold, an alert is raised once more than Threshold events of one
+	// GroupBy value match within Window. Alerts with the same dedup key are
+	// raised at most once per Suppress.
+	Rule struct {
+		ID          string        `mapstructure:"id" validate:"required"`
+		Description string        `mapstructure:"description"`
+		Severity    string        `mapstructure:"severity" validate:"omitempty,oneof=info low medium high critical"`
+		Paths       []string      `mapstructure:"paths"`
+		Actions     []string      `mapstructure:"actions"`
+		Categories  []string      `mapstructure:"categories"`
+		UIDs        []uint32      `mapstructure:"uids"`
+		Hashes      []string      `mapstructure:"hashes"`
+		Hours       string        `mapstructure:"hours"`
+		Timezone    string        `mapstructure:"timezone"`
+		Threshold   int           `mapstructure:"threshold" validate:"gte=0"`
+		Window      time.Duration `mapstructure:"window" validate:"required_with=Threshold,gte=0"`
+		GroupBy     string        `mapstructure:"group_by" validate:"omitempty,oneof=path directory category uid"`
+		Suppress    time.Duration `mapstructure:"suppress" validate:"gte=0"`
+	}
+
+	// PollingBackend configures the "polling" monitor backend, which scans
+	// the monitored directory every check_frequency.
+	PollingBackend struct {
+		HashFiles bool `mapstructure:"hash_files"`
+	}
+)
 
 var (
 	appConfig     Config
@@ -34,6 +261,32 @@ var (
 	configRWMutex sync.RWMutex
 )
 
+// WatchList returns the configured watches. Configs without a watches list
+// fall back to monitored_directory, watched with the default label.
+func (c *Config) WatchList() []Watch {
+	if len(c.Watches) > 0 {
+		return c.Watches
+	}
+	return []Watch{{Path: c.MonitoredDirectory}}
+}
+
+// DefaultDataDir returns the directory the event store, outputs and alerts
+// are kept under unless configured otherwise: /var/lib/filemodtracker when
+// running as root, and the user's state directory, $XDG_STATE_HOME or
+// ~/.local/state, otherwise, so the daemon also runs without root.
+func DefaultDataDir() string {
+	if os.Geteuid() == 0 {
+		return "/var/lib/filemodtracker"
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "filemodtracker")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "filemodtracker")
+	}
+	return filepath.Join(os.TempDir(), "filemodtracker")
+}
+
 func GetConfig() *Config {
 	configRWMutex.RLock()
 	defer configRWMutex.RUnlock()
@@ -55,6 +308,47 @@ func InitConfig(validator *validator.Validate, logger *logger.Logger) func() {
 		viper.SetDefault("osquery_config", "/var/osquery/osquery.conf")
 		viper.SetDefault("osquery_socket", "/var/osquery/osquery.em")
 		viper.SetDefault("pid_file_path", filepath.Join(os.TempDir(), "filemodtracker.pid"))
+		viper.SetDefault("monitor_backend", "osquery")
+		viper.SetDefault("ignore.file", ".fimignore")
+		viper.SetDefault("coalesce.window", "2s")
+		dataDir := DefaultDataDir()
+		viper.SetDefault("store.path", filepath.Join(dataDir, "events"))
+		viper.SetDefault("store.max_age", "2160h")
+		viper.SetDefault("store.max_size_mb", 1024)
+		viper.SetDefault("store.collect_interval", "5s")
+		viper.SetDefault("store.compact_interval", "1h")
+		viper.SetDefault("stream.buffer", 1024)
+		viper.SetDefault("stream.heartbeat", "15s")
+		viper.SetDefault("stream.poll_interval", "1s")
+		viper.SetDefault("stream.slow_consumers", "disconnect")
+		viper.SetDefault("output_dir", filepath.Join(dataDir, "outputs"))
+		viper.SetDefault("osquery.mode", "osqueryi")
+		viper.SetDefault("osquery.binary", "osqueryi")
+		viper.SetDefault("osquery.daemon_binary", "osqueryd")
+		viper.SetDefault("osquery.database_path", "/var/tmp/osquery_data/osquery.db")
+		viper.SetDefault("osquery.logger_path", "/var/tmp/osquery_data/logs")
+		viper.SetDefault("osquery.max_retries", 3)
+		viper.SetDefault("osquery.socket_timeout", "10s")
+		viper.SetDefault("osquery.file_paths", map[string][]string{
+			"etc": {"/etc/%%"},
+			"tmp": {"/tmp/%%"},
+		})
+		viper.SetDefault("osquery.events_expiry", "1h")
+		viper.SetDefault("osquery.events_max", 50000)
+		viper.SetDefault("osquery.supervisor.initial_backoff", "1s")
+		viper.SetDefault("osquery.supervisor.max_backoff", "1m")
+		viper.SetDefault("osquery.supervisor.multiplier", 2)
+		viper.SetDefault("osquery.supervisor.jitter", 0.2)
+		viper.SetDefault("osquery.supervisor.max_crashes", 5)
+		viper.SetDefault("osquery.supervisor.crash_window", "10m")
+		viper.SetDefault("native.hash_files", true)
+		viper.SetDefault("polling.hash_files", false)
+		viper.SetDefault("tracing.protocol", "grpc")
+		viper.SetDefault("tracing.service_name", "filemodtracker")
+		viper.SetDefault("tracing.sample_ratio", 1)
+		viper.SetDefault("alerts.path", filepath.Join(dataDir, "alerts"))
+		viper.SetDefault("alerts.max_age", "2160h")
+		viper.SetDefault("alerts.interval", "5s")
 
 		if err := viper.ReadInConfig(); err != nil {
 			var configFileNotFoundError viper.ConfigFileNotFoundError
@@ -7,9 +7,20 @@ import (
 	"os/exec"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/tejiriaustin/savannah-assessment/config"
 	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/metrics"
 	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/tracing"
+)
+
+const (
+	defaultCollectInterval = 5 * time.Second
+	collectTimeout         = 30 * time.Second
 )
 
 type (
@@ -19,10 +30,18 @@ type (
 		cfg         *config.Config
 		fileTracker monitoring.Monitor
 		cmdChan     <-chan Command
+		lastState   monitoring.SupervisorState
+		restarts    int
 	}
 	Command struct {
 		Command string
 		Args    []string
+
+		// SpanContext is the trace of the request that sent the command and
+		// Queued when it was sent, so the command's wait in the queue and
+		// its execution show up in that trace.
+		SpanContext trace.SpanContext
+		Queued      time.Time
 	}
 )
 
@@ -51,29 +70,137 @@ func (d *Daemon) StartDaemon(ctx context.Context) error {
 		d.logger.Info("Started file tracking...")
 		return err
 	}
+
+	// Monitors backed by the event store only see events the daemon
+	// collects for them.
+	var collect <-chan time.Time
+	collector, ok := monitoring.CollectorOf(d.fileTracker)
+	if ok {
+		interval := d.cfg.Store.CollectInterval
+		if interval <= 0 {
+			interval = defaultCollectInterval
+		}
+		collectTicker := time.NewTicker(interval)
+		defer collectTicker.Stop()
+		collect = collectTicker.C
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			d.logger.Info("daemon stopping due to context cancellation")
+			if collector != nil {
+				// Keep what arrived since the last tick.
+				d.collect(context.Background(), collector)
+			}
 			return nil
+		case <-collect:
+			d.collect(ctx, collector)
 		case <-d.ticker.C:
 			d.logger.Debug("Performing periodic check")
-			cmd := <-d.cmdChan
-			d.logger.Info("Received command", "command", cmd)
-			if err := d.executeCommand(cmd); err != nil {
-				return fmt.Errorf("error executing command: %v", err)
+			if err := d.checkMonitor(); err != nil {
+				return err
+			}
+			select {
+			case cmd := <-d.cmdChan:
+				d.logger.Info("Received command", "command", cmd.Command, "args", cmd.Args)
+				if err := d.executeCommand(d.commandContext(cmd), cmd); err != nil {
+					return fmt.Errorf("error executing command: %v", err)
+				}
+			default:
 			}
 		}
 	}
 }
 
-func (d *Daemon) executeCommand(cmd Command) error {
+// checkMonitor records the state of a supervised monitor in metrics, logs
+// changes to it and fails once it has given up restarting, so the tracker
+// never keeps running while nothing is being monitored.
+func (d *Daemon) checkMonitor() error {
+	status, ok := monitoring.StatusOf(d.fileTracker)
+	if !ok {
+		return nil
+	}
+
+	metrics.SupervisorState(string(status.State))
+	metrics.OsqueryRestarted(status.Restarts - d.restarts)
+	d.restarts = status.Restarts
+
+	if status.State != d.lastState {
+		d.logger.Info("Monitor state changed",
+			"from", d.lastState,
+			"to", status.State,
+			"restarts", status.Restarts,
+			"last_exit_code", status.LastExitCode,
+		)
+		d.lastState = status.State
+	}
+
+	if status.State == monitoring.StateFailed {
+		d.logger.Error("File monitoring has failed", "restarts", status.Restarts, "last_error", status.LastError)
+		return fmt.Errorf("file monitoring failed after %d restarts: %s", status.Restarts, status.LastError)
+	}
+	return nil
+}
+
+// collect moves new events into the event store. Failures are logged and
+// retried on the next tick, since the backend still holds the events.
+func (d *Daemon) collect(ctx context.Context, collector monitoring.Collector) {
+	ctx, cancel := context.WithTimeout(ctx, collectTimeout)
+	defer cancel()
+	ctx, span := tracing.Tracer().Start(ctx, "Daemon.collect")
+	defer span.End()
+
+	added, err := collector.Collect(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "collection failed")
+		d.logger.Error("Failed to collect file events", "error", err)
+		return
+	}
+	span.SetAttributes(attribute.Int("events.collected", added))
+	metrics.Collected(time.Now())
+	if added > 0 {
+		d.logger.Debug("Collected file events", "count", added)
+	}
+}
+
+// commandContext returns a context in the trace cmd was sent from, with a
+// span covering the time cmd spent in the queue.
+func (d *Daemon) commandContext(cmd Command) context.Context {
+	ctx := trace.ContextWithSpanContext(context.Background(), cmd.SpanContext)
+	if cmd.Queued.IsZero() {
+		return ctx
+	}
+	_, span := tracing.Tracer().Start(ctx, "command.queued",
+		trace.WithTimestamp(cmd.Queued),
+		trace.WithAttributes(attribute.String("process.command", cmd.Command)),
+	)
+	span.End()
+	return ctx
+}
+
+func (d *Daemon) executeCommand(ctx context.Context, cmd Command) error {
+	_, span := tracing.Tracer().Start(ctx, "Daemon.executeCommand", trace.WithAttributes(
+		attribute.String("process.command", cmd.Command),
+		attribute.Int("process.command_args.count", len(cmd.Args)),
+	))
+	defer span.End()
+
 	command := exec.Command(cmd.Command, cmd.Args...)
 	var stdout, stderr bytes.Buffer
 	command.Stdout = &stdout
 	command.Stderr = &stderr
 
 	err := command.Run()
+	metrics.CommandExecuted(err)
+	if command.ProcessState != nil {
+		span.SetAttributes(attribute.Int("process.exit.code", command.ProcessState.ExitCode()))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "command execution failed")
+	}
 
 	stdoutStr := stdout.String()
 	stderrStr := stderr.String()
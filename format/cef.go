@@ -0,0 +1,136 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+var (
+	// cefHeaderEscaper escapes CEF header fields, which may not contain
+	// line breaks.
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	// cefValueEscaper escapes CEF extension values.
+	cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// cefFormatter writes ArcSight Common Event Format records. File fields use
+// the keys of the ArcSight extension dictionary; the owner's uid and gid and
+// the category have no standard key and go in custom fields.
+type cefFormatter struct {
+	opts Options
+}
+
+var _ AlertFormatter = (*cefFormatter)(nil)
+
+func (f *cefFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	return f.record(f.opts.signatureID(event.Action), eventName(event.Action), event.Severity,
+		cefExtensions(event, f.opts.Hostname)), nil
+}
+
+// FormatAlert writes an alert with the rule and dedup key in custom fields
+// and the path of the last matched event in filePath.
+func (f *cefFormatter) FormatAlert(a alert.Alert) ([]byte, error) {
+	return f.record(f.opts.alertSignatureID(a), alertName(a), a.Severity,
+		cefAlertExtensions(a, f.opts.Hostname)), nil
+}
+
+func (f *cefFormatter) record(signatureID, name, sev string, exts [][2]string) []byte {
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, field := range []string{
+		f.opts.Vendor,
+		f.opts.Product,
+		f.opts.Version,
+		signatureID,
+		name,
+		strconv.Itoa(severity(sev)),
+	} {
+		b.WriteString("|" + cefHeaderEscaper.Replace(field))
+	}
+	b.WriteString("|")
+
+	for i, ext := range exts {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(ext[0] + "=" + cefValueEscaper.Replace(ext[1]))
+	}
+	return []byte(b.String())
+}
+
+func (f *cefFormatter) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func cefExtensions(event monitoring.FileEvent, hostname string) [][2]string {
+	var exts [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			exts = append(exts, [2]string{key, value})
+		}
+	}
+	if !event.Time.IsZero() {
+		add("rt", strconv.FormatInt(event.Time.UnixMilli(), 10))
+	}
+	add("externalId", event.ID)
+	add("act", string(event.Action))
+	add("dvchost", hostname)
+	add("filePath", event.TargetPath)
+	add("fname", baseName(event.TargetPath))
+	if event.Path != event.TargetPath {
+		add("oldFilePath", event.Path)
+		add("oldFileName", baseName(event.Path))
+	}
+	if event.Size != nil {
+		add("fsize", strconv.FormatInt(*event.Size, 10))
+	}
+	add("filePermission", event.Mode)
+	if event.Inode != 0 {
+		add("fileId", strconv.FormatUint(event.Inode, 10))
+	}
+	if event.ModTime != nil {
+		add("fileModificationTime", strconv.FormatInt(event.ModTime.UnixMilli(), 10))
+	}
+	if event.Hashes != nil {
+		add("fileHash", event.Hashes.SHA256)
+	}
+	if event.Category != "" {
+		add("cs1Label", "category")
+		add("cs1", event.Category)
+	}
+	if event.UID != nil {
+		add("cn1Label", "uid")
+		add("cn1", strconv.FormatUint(uint64(*event.UID), 10))
+	}
+	if event.GID != nil {
+		add("cn2Label", "gid")
+		add("cn2", strconv.FormatUint(uint64(*event.GID), 10))
+	}
+	return exts
+}
+
+func cefAlertExtensions(a alert.Alert, hostname string) [][2]string {
+	var exts [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			exts = append(exts, [2]string{key, value})
+		}
+	}
+	if !a.Time.IsZero() {
+		add("rt", strconv.FormatInt(a.Time.UnixMilli(), 10))
+	}
+	add("externalId", a.ID)
+	add("cat", "alert")
+	add("dvchost", hostname)
+	add("cnt", strconv.Itoa(a.Count))
+	add("msg", a.Description)
+	add("filePath", alertPath(a))
+	add("cs2Label", "rule")
+	add("cs2", a.RuleID)
+	add("cs3Label", "dedupKey")
+	add("cs3", a.DedupKey)
+	return exts
+}
@@ -0,0 +1,146 @@
+package format
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func testEvent() monitoring.FileEvent {
+	size := int64(1024)
+	uid, gid := uint32(0), uint32(42)
+	mtime := time.Date(2024, 6, 1, 11, 59, 0, 0, time.UTC)
+	return monitoring.FileEvent{
+		ID:         "17",
+		Time:       time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Action:     monitoring.ActionUpdated,
+		Path:       "/etc/hosts",
+		TargetPath: "/etc/hosts",
+		Category:   "system",
+		Severity:   "high",
+		Size:       &size,
+		Mode:       "0644",
+		UID:        &uid,
+		GID:        &gid,
+		Inode:      7,
+		ModTime:    &mtime,
+		Hashes:     &monitoring.Hashes{MD5: "m", SHA1: "s1", SHA256: "s256"},
+	}
+}
+
+func testOptions() Options {
+	return Options{
+		Vendor:       "Acme",
+		Product:      "FIM",
+		Version:      "2.1",
+		SignatureIDs: map[monitoring.Action]string{monitoring.ActionUpdated: "100"},
+		Hostname:     "web-1",
+	}
+}
+
+func TestCEF(t *testing.T) {
+	f, err := New("cef", testOptions())
+	require.NoError(t, err)
+
+	record, err := f.Format(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "CEF:0|Acme|FIM|2.1|100|File updated|8|rt=1717243200000 externalId=17 act=UPDATED dvchost=web-1 "+
+		"filePath=/etc/hosts fname=hosts fsize=1024 filePermission=0644 fileId=7 fileModificationTime=1717243140000 "+
+		"fileHash=s256 cs1Label=category cs1=system cn1Label=uid cn1=0 cn2Label=gid cn2=42", string(record))
+}
+
+func TestCEFEscaping(t *testing.T) {
+	opts := testOptions()
+	opts.Vendor = `Ac|me\`
+	opts.Product = "F\nIM"
+	f, err := New("cef", opts)
+	require.NoError(t, err)
+
+	event := monitoring.FileEvent{
+		ID:         "1",
+		Action:     monitoring.ActionMoved,
+		Path:       `/tmp/a=b`,
+		TargetPath: "/tmp/new|name\\x\r\nline",
+	}
+	record, err := f.Format(event)
+	require.NoError(t, err)
+	assert.Equal(t, `CEF:0|Ac\|me\\|F IM|2.1|MOVED|File moved|1|externalId=1 act=MOVED dvchost=web-1 `+
+		`filePath=/tmp/new|name\\x\r\nline fname=new|name\\x\r\nline oldFilePath=/tmp/a\=b oldFileName=a\=b`, string(record))
+}
+
+func testAlert() alert.Alert {
+	return alert.Alert{
+		ID:          "3",
+		Time:        time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		RuleID:      "etc-changed",
+		Severity:    "high",
+		Description: "System configuration changed",
+		DedupKey:    "etc-changed:/etc",
+		Count:       1,
+		Events:      []monitoring.FileEvent{testEvent()},
+	}
+}
+
+func TestCEFAlert(t *testing.T) {
+	opts := testOptions()
+	opts.AlertSignatureIDs = map[string]string{"etc-changed": "900", "critical": "999"}
+	f, err := New("cef", opts)
+	require.NoError(t, err)
+
+	record, err := f.(AlertFormatter).FormatAlert(testAlert())
+	require.NoError(t, err)
+	assert.Equal(t, "CEF:0|Acme|FIM|2.1|900|System configuration changed|8|rt=1717243200000 externalId=3 cat=alert "+
+		"dvchost=web-1 cnt=1 msg=System configuration changed filePath=/etc/hosts "+
+		"cs2Label=rule cs2=etc-changed cs3Label=dedupKey cs3=etc-changed:/etc", string(record))
+
+	// Without an entry for the rule the severity's is used, and without
+	// that the rule ID.
+	a := testAlert()
+	a.RuleID, a.Severity = "Other", "critical"
+	record, err = f.(AlertFormatter).FormatAlert(a)
+	require.NoError(t, err)
+	assert.Contains(t, string(record), "|999|System configuration changed|10|")
+	a.Severity = "low"
+	record, err = f.(AlertFormatter).FormatAlert(a)
+	require.NoError(t, err)
+	assert.Contains(t, string(record), "|Other|System configuration changed|3|")
+}
+
+func TestCEFAlertEscaping(t *testing.T) {
+	f, err := New("cef", testOptions())
+	require.NoError(t, err)
+
+	a := alert.Alert{
+		ID:          "1",
+		RuleID:      "a|b",
+		Severity:    "info",
+		Description: "x=y\\z\nline|pipe",
+		DedupKey:    "a|b:/tmp/a=b",
+		Count:       2,
+		Events:      []monitoring.FileEvent{{TargetPath: "/tmp/a=b\r"}},
+	}
+	record, err := f.(AlertFormatter).FormatAlert(a)
+	require.NoError(t, err)
+	assert.Equal(t, `CEF:0|Acme|FIM|2.1|a\|b|x=y\\z line\|pipe|1|externalId=1 cat=alert dvchost=web-1 cnt=2 `+
+		`msg=x\=y\\z\nline|pipe filePath=/tmp/a\=b\r cs2Label=rule cs2=a|b cs3Label=dedupKey cs3=a|b:/tmp/a\=b`, string(record))
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		f, err := New(name, Options{})
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, f.ContentType())
+	}
+	for _, name := range []string{"json", "cef", "leef"} {
+		f, err := New(name, Options{})
+		require.NoError(t, err)
+		assert.Implements(t, (*AlertFormatter)(nil), f, name)
+	}
+	_, err := New("xml", Options{})
+	assert.ErrorContains(t, err, "unknown format")
+}
@@ -0,0 +1,90 @@
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// csvColumns are the columns of CSV records, named like the JSON fields.
+var csvColumns = []string{
+	"id", "time", "action", "path", "target_path", "category", "severity",
+	"size", "mode", "uid", "gid", "inode", "atime", "mtime", "ctime",
+	"md5", "sha1", "sha256", "pid", "executable", "raw_ids",
+}
+
+// csvFormatter writes events as RFC 4180 rows under the header csvColumns.
+// Missing attributes are empty cells, times are RFC 3339 and raw_ids are
+// separated by spaces.
+type csvFormatter struct{}
+
+var _ HeaderFormatter = csvFormatter{}
+
+func (csvFormatter) Header() []byte {
+	return csvRow(csvColumns)
+}
+
+func (csvFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	row := []string{
+		event.ID,
+		csvTime(&event.Time),
+		string(event.Action),
+		event.Path,
+		event.TargetPath,
+		event.Category,
+		event.Severity,
+		"", // size
+		event.Mode,
+		"", "", "", // uid, gid, inode
+		csvTime(event.AccessTime),
+		csvTime(event.ModTime),
+		csvTime(event.ChangeTime),
+		"", "", "", // hashes
+		"", "", // process
+		strings.Join(event.RawIDs, " "),
+	}
+	if event.Size != nil {
+		row[7] = strconv.FormatInt(*event.Size, 10)
+	}
+	if event.UID != nil {
+		row[9] = strconv.FormatUint(uint64(*event.UID), 10)
+	}
+	if event.GID != nil {
+		row[10] = strconv.FormatUint(uint64(*event.GID), 10)
+	}
+	if event.Inode != 0 {
+		row[11] = strconv.FormatUint(event.Inode, 10)
+	}
+	if event.Hashes != nil {
+		row[15], row[16], row[17] = event.Hashes.MD5, event.Hashes.SHA1, event.Hashes.SHA256
+	}
+	if event.Process != nil {
+		row[18], row[19] = strconv.FormatInt(event.Process.PID, 10), event.Process.Executable
+	}
+	return csvRow(row), nil
+}
+
+func (csvFormatter) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+// csvRow encodes one row without its line break.
+func csvRow(fields []string) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	// Writing to a buffer cannot fail.
+	_ = w.Write(fields)
+	w.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,53 @@
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func TestCSV(t *testing.T) {
+	f, err := New("csv", Options{})
+	require.NoError(t, err)
+	header, ok := f.(HeaderFormatter)
+	require.True(t, ok)
+
+	updated := testEvent()
+	updated.Process = &monitoring.Process{PID: 812, Executable: "/usr/bin/vim"}
+	moved := monitoring.FileEvent{
+		ID:         "2",
+		Action:     monitoring.ActionMoved,
+		Path:       "/tmp/a, \"b\"",
+		TargetPath: "/tmp/line\nbreak",
+		RawIDs:     []string{"1", "2"},
+	}
+
+	var buf bytes.Buffer
+	buf.Write(header.Header())
+	buf.WriteByte('\n')
+	for _, event := range []monitoring.FileEvent{updated, moved} {
+		record, err := f.Format(event)
+		require.NoError(t, err)
+		buf.Write(record)
+		buf.WriteByte('\n')
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err, buf.String())
+	require.Len(t, rows, 3)
+	assert.Equal(t, csvColumns, rows[0])
+	assert.Equal(t, []string{
+		"17", "2024-06-01T12:00:00Z", "UPDATED", "/etc/hosts", "/etc/hosts", "system", "high",
+		"1024", "0644", "0", "42", "7", "", "2024-06-01T11:59:00Z", "",
+		"m", "s1", "s256", "812", "/usr/bin/vim", "",
+	}, rows[1])
+	assert.Equal(t, "/tmp/a, \"b\"", rows[2][3])
+	assert.Equal(t, "/tmp/line\nbreak", rows[2][4])
+	assert.Equal(t, "", rows[2][1])
+	assert.Equal(t, "1 2", rows[2][20])
+}
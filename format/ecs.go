@@ -0,0 +1,185 @@
+package format
+
+import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// ecsVersion is the Elastic Common Schema version the documents follow.
+const ecsVersion = "8.11.0"
+
+type (
+	// ecsFormatter writes Elastic Common Schema documents. Fields ECS has no
+	// place for go under the "filemodtracker" namespace.
+	ecsFormatter struct {
+		opts Options
+	}
+
+	ecsDocument struct {
+		Timestamp      time.Time      `json:"@timestamp"`
+		ECS            ecsVersionInfo `json:"ecs"`
+		Event          ecsEvent       `json:"event"`
+		File           ecsFile        `json:"file"`
+		Host           ecsHost        `json:"host"`
+		Process        *ecsProcess    `json:"process,omitempty"`
+		Observer       ecsObserver    `json:"observer"`
+		FileModTracker ecsCustom      `json:"filemodtracker"`
+	}
+
+	ecsVersionInfo struct {
+		Version string `json:"version"`
+	}
+
+	ecsEvent struct {
+		ID       string   `json:"id,omitempty"`
+		Kind     string   `json:"kind"`
+		Category []string `json:"category"`
+		Type     []string `json:"type"`
+		Action   string   `json:"action"`
+		Severity int      `json:"severity"`
+		Module   string   `json:"module"`
+		Dataset  string   `json:"dataset"`
+	}
+
+	ecsFile struct {
+		Path      string     `json:"path"`
+		Name      string     `json:"name,omitempty"`
+		Directory string     `json:"directory,omitempty"`
+		Extension string     `json:"extension,omitempty"`
+		Type      string     `json:"type"`
+		Size      *int64     `json:"size,omitempty"`
+		Mode      string     `json:"mode,omitempty"`
+		UID       string     `json:"uid,omitempty"`
+		GID       string     `json:"gid,omitempty"`
+		Inode     string     `json:"inode,omitempty"`
+		Accessed  *time.Time `json:"accessed,omitempty"`
+		Mtime     *time.Time `json:"mtime,omitempty"`
+		Ctime     *time.Time `json:"ctime,omitempty"`
+		Hash      *ecsHash   `json:"hash,omitempty"`
+	}
+
+	ecsHash struct {
+		MD5    string `json:"md5,omitempty"`
+		SHA1   string `json:"sha1,omitempty"`
+		SHA256 string `json:"sha256,omitempty"`
+	}
+
+	ecsHost struct {
+		Name     string `json:"name,omitempty"`
+		Hostname string `json:"hostname,omitempty"`
+	}
+
+	ecsProcess struct {
+		PID        int64             `json:"pid"`
+		Executable string            `json:"executable,omitempty"`
+		Name       string            `json:"name,omitempty"`
+		Parent     *ecsParentProcess `json:"parent,omitempty"`
+	}
+
+	ecsParentProcess struct {
+		PID int64 `json:"pid"`
+	}
+
+	ecsObserver struct {
+		Vendor  string `json:"vendor"`
+		Product string `json:"product"`
+		Version string `json:"version"`
+		Type    string `json:"type"`
+	}
+
+	ecsCustom struct {
+		Category string `json:"category,omitempty"`
+		Severity string `json:"severity,omitempty"`
+		// OldPath is where a moved file came from.
+		OldPath string   `json:"old_path,omitempty"`
+		RawIDs  []string `json:"raw_ids,omitempty"`
+	}
+)
+
+func (f *ecsFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	doc := ecsDocument{
+		Timestamp: event.Time.UTC(),
+		ECS:       ecsVersionInfo{Version: ecsVersion},
+		Event: ecsEvent{
+			ID:       event.ID,
+			Kind:     "event",
+			Category: []string{"file"},
+			Type:     []string{ecsEventType(event.Action)},
+			Action:   strings.ToLower(string(event.Action)),
+			Severity: severity(event.Severity),
+			Module:   "filemodtracker",
+			Dataset:  "filemodtracker.file",
+		},
+		File: ecsFile{
+			Path:      event.TargetPath,
+			Name:      baseName(event.TargetPath),
+			Directory: dirName(event.TargetPath),
+			Extension: strings.TrimPrefix(path.Ext(event.TargetPath), "."),
+			Type:      "file",
+			Size:      event.Size,
+			Mode:      event.Mode,
+			Accessed:  utc(event.AccessTime),
+			Mtime:     utc(event.ModTime),
+			Ctime:     utc(event.ChangeTime),
+		},
+		Host: ecsHost{Name: f.opts.Hostname, Hostname: f.opts.Hostname},
+		Observer: ecsObserver{
+			Vendor:  f.opts.Vendor,
+			Product: f.opts.Product,
+			Version: f.opts.Version,
+			Type:    "fim",
+		},
+		FileModTracker: ecsCustom{
+			Category: event.Category,
+			Severity: event.Severity,
+			RawIDs:   event.RawIDs,
+		},
+	}
+	if event.UID != nil {
+		doc.File.UID = strconv.FormatUint(uint64(*event.UID), 10)
+	}
+	if event.GID != nil {
+		doc.File.GID = strconv.FormatUint(uint64(*event.GID), 10)
+	}
+	if event.Inode != 0 {
+		doc.File.Inode = strconv.FormatUint(event.Inode, 10)
+	}
+	if event.Hashes != nil {
+		doc.File.Hash = &ecsHash{MD5: event.Hashes.MD5, SHA1: event.Hashes.SHA1, SHA256: event.Hashes.SHA256}
+	}
+	if event.Path != event.TargetPath {
+		doc.FileModTracker.OldPath = event.Path
+	}
+	if p := event.Process; p != nil {
+		doc.Process = &ecsProcess{PID: p.PID, Executable: p.Executable, Name: baseName(p.Executable)}
+		if p.PPID != 0 {
+			doc.Process.Parent = &ecsParentProcess{PID: p.PPID}
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func (f *ecsFormatter) ContentType() string {
+	return ndjsonContentType
+}
+
+// ecsEventType maps an action to the ECS event.type of the file category.
+func ecsEventType(action monitoring.Action) string {
+	switch action {
+	case monitoring.ActionCreated, monitoring.ActionMovedTo:
+		return "creation"
+	case monitoring.ActionDeleted, monitoring.ActionMovedFrom:
+		return "deletion"
+	case monitoring.ActionUpdated, monitoring.ActionAttributesModified, monitoring.ActionMoved:
+		return "change"
+	case monitoring.ActionOpened, monitoring.ActionAccessed:
+		return "access"
+	default:
+		return "info"
+	}
+}
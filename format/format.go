@@ -0,0 +1,249 @@
+// Package format encodes file events for the systems they are sent to: the
+// tracker's own JSON, the CEF and LEEF line formats SIEMs ingest, the
+// Elastic Common Schema and OCSF documents of Elasticsearch and security
+// data lakes, and CSV for spreadsheets. JSON, CEF and LEEF also encode the
+// alerts raised by alert rules.
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	defaultVendor  = "FileModTracker"
+	defaultProduct = "FileModTracker"
+	defaultVersion = "1.0"
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+type (
+	// Formatter encodes one event as a single record without a trailing
+	// newline.
+	Formatter interface {
+		Format(event monitoring.FileEvent) ([]byte, error)
+		// ContentType is the media type of records written one per line.
+		ContentType() string
+	}
+
+	// HeaderFormatter is a Formatter whose records follow a header line,
+	// like the column names of CSV.
+	HeaderFormatter interface {
+		Formatter
+		// Header returns the header line without a trailing newline.
+		Header() []byte
+	}
+
+	// AlertFormatter is a Formatter that also encodes alerts.
+	AlertFormatter interface {
+		Formatter
+		FormatAlert(a alert.Alert) ([]byte, error)
+	}
+
+	// Options describe the tracker in the headers of CEF and LEEF records
+	// and the observer or product of ECS and OCSF ones.
+	// SignatureIDs maps actions to the CEF signature ID or LEEF event ID;
+	// actions without an entry use their name. AlertSignatureIDs does the
+	// same for alerts, keyed by lower case rule ID or severity; alerts
+	// without an entry use their rule ID.
+	Options struct {
+		Vendor            string
+		Product           string
+		Version           string
+		SignatureIDs      map[monitoring.Action]string
+		AlertSignatureIDs map[string]string
+		Hostname          string
+	}
+
+	jsonFormatter struct{}
+)
+
+// Names lists the formats New accepts.
+func Names() []string {
+	return []string{"json", "cef", "leef", "ecs", "ocsf", "csv"}
+}
+
+// New returns the formatter named name.
+func New(name string, opts Options) (Formatter, error) {
+	opts = opts.withDefaults()
+	switch name {
+	case "json":
+		return jsonFormatter{}, nil
+	case "cef":
+		return &cefFormatter{opts: opts}, nil
+	case "leef":
+		return &leefFormatter{opts: opts}, nil
+	case "ecs":
+		return &ecsFormatter{opts: opts}, nil
+	case "ocsf":
+		return &ocsfFormatter{opts: opts}, nil
+	case "csv":
+		return csvFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, available formats: %s", name, strings.Join(Names(), ", "))
+	}
+}
+
+// OptionsFromConfig returns the options of the formats block of cfg.
+func OptionsFromConfig(cfg *config.Config) Options {
+	opts := Options{
+		Vendor:  cfg.Formats.Vendor,
+		Product: cfg.Formats.Product,
+		Version: cfg.Formats.Version,
+	}
+	if len(cfg.Formats.SignatureIDs) > 0 {
+		opts.SignatureIDs = make(map[monitoring.Action]string, len(cfg.Formats.SignatureIDs))
+		for action, id := range cfg.Formats.SignatureIDs {
+			// Viper lowercases map keys.
+			opts.SignatureIDs[monitoring.Action(strings.ToUpper(action))] = id
+		}
+	}
+	if len(cfg.Formats.AlertSignatureIDs) > 0 {
+		opts.AlertSignatureIDs = make(map[string]string, len(cfg.Formats.AlertSignatureIDs))
+		for key, id := range cfg.Formats.AlertSignatureIDs {
+			opts.AlertSignatureIDs[strings.ToLower(key)] = id
+		}
+	}
+	return opts
+}
+
+func (o Options) withDefaults() Options {
+	if o.Vendor == "" {
+		o.Vendor = defaultVendor
+	}
+	if o.Product == "" {
+		o.Product = defaultProduct
+	}
+	if o.Version == "" {
+		o.Version = defaultVersion
+	}
+	if o.Hostname == "" {
+		o.Hostname, _ = os.Hostname()
+	}
+	return o
+}
+
+func (o Options) signatureID(action monitoring.Action) string {
+	if id, ok := o.SignatureIDs[action]; ok {
+		return id
+	}
+	return string(action)
+}
+
+var _ AlertFormatter = jsonFormatter{}
+
+// alertSignatureID returns the signature ID of a, looked up by rule ID
+// before severity.
+func (o Options) alertSignatureID(a alert.Alert) string {
+	if id, ok := o.AlertSignatureIDs[strings.ToLower(a.RuleID)]; ok {
+		return id
+	}
+	if id, ok := o.AlertSignatureIDs[a.Severity]; ok {
+		return id
+	}
+	return a.RuleID
+}
+
+func (jsonFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (jsonFormatter) FormatAlert(a alert.Alert) ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (jsonFormatter) ContentType() string {
+	return ndjsonContentType
+}
+
+// severity maps an event severity to the 0 to 10 scale of CEF and LEEF.
+func severity(s string) int {
+	switch s {
+	case "critical":
+		return 10
+	case "high":
+		return 8
+	case "medium":
+		return 5
+	case "low":
+		return 3
+	default:
+		return 1
+	}
+}
+
+// eventName describes an action for people, e.g. "File updated".
+func eventName(action monitoring.Action) string {
+	switch action {
+	case monitoring.ActionCreated:
+		return "File created"
+	case monitoring.ActionUpdated:
+		return "File updated"
+	case monitoring.ActionDeleted:
+		return "File deleted"
+	case monitoring.ActionAttributesModified:
+		return "File attributes modified"
+	case monitoring.ActionMovedFrom:
+		return "File moved away"
+	case monitoring.ActionMovedTo:
+		return "File moved here"
+	case monitoring.ActionMoved:
+		return "File moved"
+	case monitoring.ActionOpened:
+		return "File opened"
+	case monitoring.ActionAccessed:
+		return "File accessed"
+	default:
+		return "File " + strings.ToLower(string(action))
+	}
+}
+
+// alertName describes an alert for people: the rule's description, or its
+// ID without one.
+func alertName(a alert.Alert) string {
+	if a.Description != "" {
+		return a.Description
+	}
+	return "Alert " + a.RuleID
+}
+
+// alertPath returns the target path of the last event that raised a.
+func alertPath(a alert.Alert) string {
+	if len(a.Events) == 0 {
+		return ""
+	}
+	return a.Events[len(a.Events)-1].TargetPath
+}
+
+// baseName returns the last element of a slash separated path.
+func baseName(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Base(p)
+}
+
+// dirName returns all but the last element of a slash separated path.
+func dirName(p string) string {
+	if p == "" {
+		return ""
+	}
+	return path.Dir(p)
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,78 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenEvents are encoded by TestGolden, each into testdata/<format>_<name>.golden.
+func goldenEvents() map[string]monitoring.FileEvent {
+	updated := testEvent()
+	updated.Process = &monitoring.Process{PID: 812, PPID: 1, Executable: "/usr/bin/vim"}
+
+	atime := time.Date(2024, 6, 1, 11, 58, 0, 0, time.UTC)
+	moved := monitoring.FileEvent{
+		ID:         "20",
+		Time:       time.Date(2024, 6, 1, 12, 0, 5, 0, time.UTC),
+		Action:     monitoring.ActionMoved,
+		Path:       "/var/www/index.html.tmp",
+		TargetPath: "/var/www/index.html",
+		Category:   "www",
+		Severity:   "medium",
+		Inode:      99,
+		AccessTime: &atime,
+		RawIDs:     []string{"18", "19"},
+	}
+
+	deleted := monitoring.FileEvent{
+		ID:         "21",
+		Time:       time.Date(2024, 6, 1, 12, 1, 0, 0, time.UTC),
+		Action:     monitoring.ActionDeleted,
+		Path:       "/tmp/session",
+		TargetPath: "/tmp/session",
+		Category:   "tmp",
+		Severity:   "info",
+	}
+
+	return map[string]monitoring.FileEvent{"updated": updated, "moved": moved, "deleted": deleted}
+}
+
+func TestGolden(t *testing.T) {
+	for _, name := range []string{"ecs", "ocsf"} {
+		f, err := New(name, testOptions())
+		require.NoError(t, err)
+		assert.Equal(t, "application/x-ndjson", f.ContentType())
+
+		for event, fileEvent := range goldenEvents() {
+			t.Run(name+"_"+event, func(t *testing.T) {
+				record, err := f.Format(fileEvent)
+				require.NoError(t, err)
+				assert.NotContains(t, string(record), "\n", "records must fit on one line")
+
+				var indented bytes.Buffer
+				require.NoError(t, json.Indent(&indented, record, "", "  "))
+				indented.WriteByte('\n')
+
+				golden := filepath.Join("testdata", name+"_"+event+".golden")
+				if *update {
+					require.NoError(t, os.WriteFile(golden, indented.Bytes(), 0644))
+				}
+				want, err := os.ReadFile(golden)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), indented.String())
+			})
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// leefTimeLayout is LEEF's default devTime format, so devTimeFormat can be
+// left out.
+const leefTimeLayout = "Jan 02 2006 15:04:05.000 MST"
+
+var (
+	// leefHeaderEscaper escapes LEEF header fields, which may not contain
+	// line breaks.
+	leefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	// leefValueEscaper escapes attribute values, which are separated by tabs.
+	leefValueEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+)
+
+// leefFormatter writes IBM QRadar Log Event Extended Format 2.0 records with
+// tab separated attributes. The predefined keys are used where they fit and
+// file fields get keys of their own.
+type leefFormatter struct {
+	opts Options
+}
+
+var _ AlertFormatter = (*leefFormatter)(nil)
+
+func (f *leefFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	return f.record(f.opts.signatureID(event.Action), leefAttributes(event, f.opts.Hostname)), nil
+}
+
+// FormatAlert writes an alert with cat "alert" and the path of the last
+// matched event as its resource.
+func (f *leefFormatter) FormatAlert(a alert.Alert) ([]byte, error) {
+	return f.record(f.opts.alertSignatureID(a), leefAlertAttributes(a, f.opts.Hostname)), nil
+}
+
+func (f *leefFormatter) record(eventID string, attrs [][2]string) []byte {
+	var b strings.Builder
+	b.WriteString("LEEF:2.0")
+	for _, field := range []string{
+		f.opts.Vendor,
+		f.opts.Product,
+		f.opts.Version,
+		eventID,
+	} {
+		b.WriteString("|" + leefHeaderEscaper.Replace(field))
+	}
+	b.WriteString("|x09|")
+
+	for i, attr := range attrs {
+		if i > 0 {
+			b.WriteString("\t")
+		}
+		b.WriteString(attr[0] + "=" + leefValueEscaper.Replace(attr[1]))
+	}
+	return []byte(b.String())
+}
+
+func (f *leefFormatter) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func leefAttributes(event monitoring.FileEvent, hostname string) [][2]string {
+	var attrs [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, [2]string{key, value})
+		}
+	}
+	if !event.Time.IsZero() {
+		add("devTime", event.Time.UTC().Format(leefTimeLayout))
+	}
+	add("cat", event.Category)
+	add("sev", strconv.Itoa(severity(event.Severity)))
+	add("identHostName", hostname)
+	add("resource", event.TargetPath)
+	add("eventId", event.ID)
+	add("action", string(event.Action))
+	add("fileName", baseName(event.TargetPath))
+	if event.Path != event.TargetPath {
+		add("oldFilePath", event.Path)
+	}
+	if event.Size != nil {
+		add("fileSize", strconv.FormatInt(*event.Size, 10))
+	}
+	add("fileMode", event.Mode)
+	if event.Inode != 0 {
+		add("inode", strconv.FormatUint(event.Inode, 10))
+	}
+	if event.UID != nil {
+		add("uid", strconv.FormatUint(uint64(*event.UID), 10))
+	}
+	if event.GID != nil {
+		add("gid", strconv.FormatUint(uint64(*event.GID), 10))
+	}
+	if event.ModTime != nil {
+		add("fileModificationTime", event.ModTime.UTC().Format(leefTimeLayout))
+	}
+	if event.Hashes != nil {
+		add("md5", event.Hashes.MD5)
+		add("sha1", event.Hashes.SHA1)
+		add("sha256", event.Hashes.SHA256)
+	}
+	return attrs
+}
+
+func leefAlertAttributes(a alert.Alert, hostname string) [][2]string {
+	var attrs [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			attrs = append(attrs, [2]string{key, value})
+		}
+	}
+	if !a.Time.IsZero() {
+		add("devTime", a.Time.UTC().Format(leefTimeLayout))
+	}
+	add("cat", "alert")
+	add("sev", strconv.Itoa(severity(a.Severity)))
+	add("identHostName", hostname)
+	add("resource", alertPath(a))
+	add("alertId", a.ID)
+	add("ruleId", a.RuleID)
+	add("dedupKey", a.DedupKey)
+	add("count", strconv.Itoa(a.Count))
+	add("msg", a.Description)
+	return attrs
+}
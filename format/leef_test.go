@@ -0,0 +1,112 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func TestLEEF(t *testing.T) {
+	f, err := New("leef", testOptions())
+	require.NoError(t, err)
+
+	record, err := f.Format(testEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "LEEF:2.0|Acme|FIM|2.1|100|x09|"+strings.Join([]string{
+		"devTime=Jun 01 2024 12:00:00.000 UTC",
+		"cat=system",
+		"sev=8",
+		"identHostName=web-1",
+		"resource=/etc/hosts",
+		"eventId=17",
+		"action=UPDATED",
+		"fileName=hosts",
+		"fileSize=1024",
+		"fileMode=0644",
+		"inode=7",
+		"uid=0",
+		"gid=42",
+		"fileModificationTime=Jun 01 2024 11:59:00.000 UTC",
+		"md5=m",
+		"sha1=s1",
+		"sha256=s256",
+	}, "\t"), string(record))
+}
+
+func TestLEEFEscaping(t *testing.T) {
+	opts := testOptions()
+	opts.SignatureIDs = nil
+	opts.Vendor = "Ac|me"
+	f, err := New("leef", opts)
+	require.NoError(t, err)
+
+	event := monitoring.FileEvent{
+		ID:         "1",
+		Action:     monitoring.ActionCreated,
+		Path:       "/tmp/a\tb=c\\d\ne",
+		TargetPath: "/tmp/a\tb=c\\d\ne",
+	}
+	record, err := f.Format(event)
+	require.NoError(t, err)
+	assert.Equal(t, `LEEF:2.0|Ac\|me|FIM|2.1|CREATED|x09|`+strings.Join([]string{
+		"sev=1",
+		"identHostName=web-1",
+		`resource=/tmp/a\tb=c\\d\ne`,
+		"eventId=1",
+		"action=CREATED",
+		`fileName=a\tb=c\\d\ne`,
+	}, "\t"), string(record))
+}
+
+func TestLEEFAlert(t *testing.T) {
+	opts := testOptions()
+	opts.AlertSignatureIDs = map[string]string{"high": "800"}
+	f, err := New("leef", opts)
+	require.NoError(t, err)
+
+	record, err := f.(AlertFormatter).FormatAlert(testAlert())
+	require.NoError(t, err)
+	assert.Equal(t, "LEEF:2.0|Acme|FIM|2.1|800|x09|"+strings.Join([]string{
+		"devTime=Jun 01 2024 12:00:00.000 UTC",
+		"cat=alert",
+		"sev=8",
+		"identHostName=web-1",
+		"resource=/etc/hosts",
+		"alertId=3",
+		"ruleId=etc-changed",
+		"dedupKey=etc-changed:/etc",
+		"count=1",
+		"msg=System configuration changed",
+	}, "\t"), string(record))
+}
+
+func TestLEEFAlertEscaping(t *testing.T) {
+	f, err := New("leef", testOptions())
+	require.NoError(t, err)
+
+	a := alert.Alert{
+		ID:          "1",
+		RuleID:      "a|b\nc",
+		Severity:    "medium",
+		Description: "tab\there\\",
+		DedupKey:    "a|b\nc",
+		Count:       1,
+	}
+	record, err := f.(AlertFormatter).FormatAlert(a)
+	require.NoError(t, err)
+	assert.Equal(t, `LEEF:2.0|Acme|FIM|2.1|a\|b c|x09|`+strings.Join([]string{
+		"cat=alert",
+		"sev=5",
+		"identHostName=web-1",
+		"alertId=1",
+		`ruleId=a|b\nc`,
+		`dedupKey=a|b\nc`,
+		"count=1",
+		`msg=tab\there\\`,
+	}, "\t"), string(record))
+}
@@ -0,0 +1,284 @@
+package format
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	// ocsfVersion is the OCSF schema version the records follow.
+	ocsfVersion = "1.1.0"
+
+	ocsfCategorySystemActivity = 1
+	ocsfClassFileActivity      = 1001
+
+	// ocsfFileTypeRegular is file.type_id for regular files.
+	ocsfFileTypeRegular = 1
+)
+
+// OCSF File System Activity activity IDs.
+const (
+	ocsfActivityOther         = 99
+	ocsfActivityCreate        = 1
+	ocsfActivityRead          = 2
+	ocsfActivityUpdate        = 3
+	ocsfActivityDelete        = 4
+	ocsfActivityRename        = 5
+	ocsfActivitySetAttributes = 6
+	ocsfActivityOpen          = 14
+)
+
+var (
+	ocsfActivityNames = map[int]string{
+		ocsfActivityOther:         "Other",
+		ocsfActivityCreate:        "Create",
+		ocsfActivityRead:          "Read",
+		ocsfActivityUpdate:        "Update",
+		ocsfActivityDelete:        "Delete",
+		ocsfActivityRename:        "Rename",
+		ocsfActivitySetAttributes: "Set Attributes",
+		ocsfActivityOpen:          "Open",
+	}
+	ocsfSeverityNames = map[int]string{
+		1: "Informational",
+		2: "Low",
+		3: "Medium",
+		4: "High",
+		5: "Critical",
+	}
+	ocsfHashAlgorithms = []struct {
+		id   int
+		name string
+		hash func(*monitoring.Hashes) string
+	}{
+		{1, "MD5", func(h *monitoring.Hashes) string { return h.MD5 }},
+		{2, "SHA-1", func(h *monitoring.Hashes) string { return h.SHA1 }},
+		{3, "SHA-256", func(h *monitoring.Hashes) string { return h.SHA256 }},
+	}
+)
+
+type (
+	// ocsfFormatter writes OCSF File System Activity (class 1001) records.
+	// Fields OCSF has no attribute for go in "unmapped".
+	ocsfFormatter struct {
+		opts Options
+	}
+
+	ocsfRecord struct {
+		ActivityID   int           `json:"activity_id"`
+		ActivityName string        `json:"activity_name"`
+		CategoryUID  int           `json:"category_uid"`
+		CategoryName string        `json:"category_name"`
+		ClassUID     int           `json:"class_uid"`
+		ClassName    string        `json:"class_name"`
+		TypeUID      int           `json:"type_uid"`
+		TypeName     string        `json:"type_name"`
+		Time         int64         `json:"time"`
+		SeverityID   int           `json:"severity_id"`
+		Severity     string        `json:"severity"`
+		Message      string        `json:"message"`
+		Metadata     ocsfMetadata  `json:"metadata"`
+		Device       ocsfDevice    `json:"device"`
+		Actor        *ocsfActor    `json:"actor,omitempty"`
+		File         ocsfFile      `json:"file"`
+		FileResult   *ocsfFile     `json:"file_result,omitempty"`
+		Unmapped     *ocsfUnmapped `json:"unmapped,omitempty"`
+	}
+
+	ocsfMetadata struct {
+		UID     string      `json:"uid,omitempty"`
+		Version string      `json:"version"`
+		Product ocsfProduct `json:"product"`
+	}
+
+	ocsfProduct struct {
+		Name       string `json:"name"`
+		VendorName string `json:"vendor_name"`
+		Version    string `json:"version"`
+	}
+
+	ocsfDevice struct {
+		Hostname string `json:"hostname,omitempty"`
+		TypeID   int    `json:"type_id"`
+	}
+
+	ocsfActor struct {
+		Process ocsfProcess `json:"process"`
+	}
+
+	ocsfProcess struct {
+		PID           int64        `json:"pid"`
+		Name          string       `json:"name,omitempty"`
+		File          *ocsfFile    `json:"file,omitempty"`
+		ParentProcess *ocsfProcess `json:"parent_process,omitempty"`
+	}
+
+	ocsfFile struct {
+		Name         string     `json:"name"`
+		Path         string     `json:"path"`
+		ParentFolder string     `json:"parent_folder,omitempty"`
+		TypeID       int        `json:"type_id"`
+		Size         *int64     `json:"size,omitempty"`
+		UID          string     `json:"uid,omitempty"`
+		Owner        *ocsfUser  `json:"owner,omitempty"`
+		AccessedTime *int64     `json:"accessed_time,omitempty"`
+		ModifiedTime *int64     `json:"modified_time,omitempty"`
+		Hashes       []ocsfHash `json:"hashes,omitempty"`
+	}
+
+	ocsfUser struct {
+		UID string `json:"uid"`
+	}
+
+	ocsfHash struct {
+		AlgorithmID int    `json:"algorithm_id"`
+		Algorithm   string `json:"algorithm"`
+		Value       string `json:"value"`
+	}
+
+	ocsfUnmapped struct {
+		Category string   `json:"category,omitempty"`
+		Mode     string   `json:"mode,omitempty"`
+		GID      *uint32  `json:"gid,omitempty"`
+		Ctime    *int64   `json:"ctime,omitempty"`
+		RawIDs   []string `json:"raw_ids,omitempty"`
+	}
+)
+
+func (f *ocsfFormatter) Format(event monitoring.FileEvent) ([]byte, error) {
+	activity := ocsfActivity(event.Action)
+	severityID := ocsfSeverity(event.Severity)
+	record := ocsfRecord{
+		ActivityID:   activity,
+		ActivityName: ocsfActivityNames[activity],
+		CategoryUID:  ocsfCategorySystemActivity,
+		CategoryName: "System Activity",
+		ClassUID:     ocsfClassFileActivity,
+		ClassName:    "File System Activity",
+		TypeUID:      ocsfClassFileActivity*100 + activity,
+		TypeName:     "File System Activity: " + ocsfActivityNames[activity],
+		Time:         event.Time.UnixMilli(),
+		SeverityID:   severityID,
+		Severity:     ocsfSeverityNames[severityID],
+		Message:      eventName(event.Action) + ": " + event.TargetPath,
+		Metadata: ocsfMetadata{
+			UID:     event.ID,
+			Version: ocsfVersion,
+			Product: ocsfProduct{Name: f.opts.Product, VendorName: f.opts.Vendor, Version: f.opts.Version},
+		},
+		// type_id 0 is an unknown device type.
+		Device: ocsfDevice{Hostname: f.opts.Hostname},
+		File:   ocsfFileOf(event, event.Path),
+	}
+	// For a rename, file is the file before it and file_result after it.
+	if event.Path != event.TargetPath {
+		result := ocsfFileOf(event, event.TargetPath)
+		record.FileResult = &result
+	}
+	if p := event.Process; p != nil {
+		record.Actor = &ocsfActor{Process: ocsfProcess{PID: p.PID, Name: baseName(p.Executable)}}
+		if p.Executable != "" {
+			record.Actor.Process.File = &ocsfFile{
+				Name:         baseName(p.Executable),
+				Path:         p.Executable,
+				ParentFolder: dirName(p.Executable),
+				TypeID:       ocsfFileTypeRegular,
+			}
+		}
+		if p.PPID != 0 {
+			record.Actor.Process.ParentProcess = &ocsfProcess{PID: p.PPID}
+		}
+	}
+	unmapped := ocsfUnmapped{
+		Category: event.Category,
+		Mode:     event.Mode,
+		GID:      event.GID,
+		Ctime:    unixMilli(event.ChangeTime),
+		RawIDs:   event.RawIDs,
+	}
+	if unmapped.Category != "" || unmapped.Mode != "" || unmapped.GID != nil || unmapped.Ctime != nil || len(unmapped.RawIDs) > 0 {
+		record.Unmapped = &unmapped
+	}
+	return json.Marshal(record)
+}
+
+func (f *ocsfFormatter) ContentType() string {
+	return ndjsonContentType
+}
+
+// ocsfFileOf returns the OCSF file object of the file at path, with the
+// attributes event recorded.
+func ocsfFileOf(event monitoring.FileEvent, path string) ocsfFile {
+	file := ocsfFile{
+		Name:         baseName(path),
+		Path:         path,
+		ParentFolder: dirName(path),
+		TypeID:       ocsfFileTypeRegular,
+		Size:         event.Size,
+		AccessedTime: unixMilli(event.AccessTime),
+		ModifiedTime: unixMilli(event.ModTime),
+	}
+	if event.Inode != 0 {
+		file.UID = strconv.FormatUint(event.Inode, 10)
+	}
+	if event.UID != nil {
+		file.Owner = &ocsfUser{UID: strconv.FormatUint(uint64(*event.UID), 10)}
+	}
+	if event.Hashes != nil {
+		for _, algorithm := range ocsfHashAlgorithms {
+			if value := algorithm.hash(event.Hashes); value != "" {
+				file.Hashes = append(file.Hashes, ocsfHash{AlgorithmID: algorithm.id, Algorithm: algorithm.name, Value: value})
+			}
+		}
+	}
+	return file
+}
+
+// ocsfActivity maps an action to a File System Activity activity_id.
+func ocsfActivity(action monitoring.Action) int {
+	switch action {
+	case monitoring.ActionCreated:
+		return ocsfActivityCreate
+	case monitoring.ActionUpdated:
+		return ocsfActivityUpdate
+	case monitoring.ActionDeleted:
+		return ocsfActivityDelete
+	case monitoring.ActionMoved, monitoring.ActionMovedFrom, monitoring.ActionMovedTo:
+		return ocsfActivityRename
+	case monitoring.ActionAttributesModified:
+		return ocsfActivitySetAttributes
+	case monitoring.ActionOpened:
+		return ocsfActivityOpen
+	case monitoring.ActionAccessed:
+		return ocsfActivityRead
+	default:
+		return ocsfActivityOther
+	}
+}
+
+// ocsfSeverity maps an event severity to an OCSF severity_id.
+func ocsfSeverity(s string) int {
+	switch s {
+	case "critical":
+		return 5
+	case "high":
+		return 4
+	case "medium":
+		return 3
+	case "low":
+		return 2
+	default:
+		return 1
+	}
+}
+
+func unixMilli(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
@@ -0,0 +1,272 @@
+// Package ignore decides whether a path is excluded by rules written with
+// .gitignore semantics: "*", "?" and "[...]" match within a path segment, "**"
+// matches across segments, "!" re-includes a path, a trailing "/" only matches
+// directories, and rules can come from ignore files placed in any directory.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadInterval bounds how often an ignore file is checked for changes.
+const reloadInterval = 10 * time.Second
+
+type (
+	// Matcher applies the configured rules followed by the rules of every
+	// ignore file found between the filesystem root and a path. As with git,
+	// the last matching rule wins and rules in deeper files override rules
+	// from shallower ones.
+	Matcher struct {
+		rules    []rule
+		fileName string
+
+		mutex sync.Mutex
+		files map[string]*ignoreFile
+	}
+
+	rule struct {
+		pattern string
+		base    string
+		negate  bool
+		dirOnly bool
+		re      *regexp.Regexp
+	}
+
+	ignoreFile struct {
+		checkedAt time.Time
+		modTime   time.Time
+		rules     []rule
+	}
+)
+
+// New builds a Matcher from patterns, which are anchored at the filesystem
+// root like the lines of a .gitignore placed there. fileName names the ignore
+// file read from each directory; an empty fileName disables ignore files.
+func New(patterns []string, fileName string) (*Matcher, error) {
+	m := &Matcher{
+		fileName: fileName,
+		files:    make(map[string]*ignoreFile),
+	}
+	for _, pattern := range patterns {
+		r, ok, err := parseRule(pattern, "")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m, nil
+}
+
+// Empty reports whether the matcher can never ignore anything.
+func (m *Matcher) Empty() bool {
+	return len(m.rules) == 0 && m.fileName == ""
+}
+
+// Match reports whether path is ignored. isDir says whether path is a
+// directory, which only matters for rules ending in "/". A path inside an
+// ignored directory is ignored even if a later rule re-includes it.
+func (m *Matcher) Match(path string, isDir bool) bool {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+
+	volume := filepath.ToSlash(filepath.VolumeName(path))
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(path, volume), "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		return false
+	}
+
+	rules := m.rules
+	dir := volume
+	for i, segment := range segments {
+		rules = append(rules[:len(rules):len(rules)], m.fileRules(dir)...)
+
+		dir += "/" + segment
+		last := i == len(segments)-1
+		if ignored(rules, dir, isDir || !last) {
+			return true
+		}
+	}
+	return false
+}
+
+func ignored(rules []rule, path string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.match(path) {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+func (r rule) match(path string) bool {
+	if !strings.HasPrefix(path, r.base+"/") {
+		return false
+	}
+	return r.re.MatchString(path[len(r.base)+1:])
+}
+
+// fileRules returns the rules of the ignore file in dir, re-reading it when it
+// changed since it was last loaded.
+func (m *Matcher) fileRules(dir string) []rule {
+	if m.fileName == "" {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cached, ok := m.files[dir]
+	if ok && time.Since(cached.checkedAt) < reloadInterval {
+		return cached.rules
+	}
+
+	path := filepath.FromSlash(dir + "/" + m.fileName)
+	info, err := os.Stat(path)
+	if err != nil {
+		m.files[dir] = &ignoreFile{checkedAt: time.Now()}
+		return nil
+	}
+	if ok && cached.modTime.Equal(info.ModTime()) {
+		cached.checkedAt = time.Now()
+		return cached.rules
+	}
+
+	rules, _ := readRules(path, dir)
+	m.files[dir] = &ignoreFile{checkedAt: time.Now(), modTime: info.ModTime(), rules: rules}
+	return rules
+}
+
+// readRules parses an ignore file. Lines that do not form a valid pattern are
+// skipped, as git does.
+func readRules(path, base string) ([]rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok, err := parseRule(scanner.Text(), base); err == nil && ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// parseRule parses one .gitignore line relative to base. It returns false for
+// blank lines and comments.
+func parseRule(line, base string) (rule, bool, error) {
+	pattern := strings.TrimSuffix(line, "\r")
+	pattern = trimTrailingSpaces(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return rule{}, false, nil
+	}
+
+	r := rule{pattern: pattern, base: base}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return rule{}, false, nil
+	}
+
+	// A pattern without a slash matches a name at any depth; any other
+	// pattern is relative to the directory the rule belongs to.
+	if strings.Contains(pattern, "/") {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		pattern = "**/" + pattern
+	}
+
+	re, err := regexp.Compile("^" + translate(pattern) + "$")
+	if err != nil {
+		return rule{}, false, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+	}
+	r.re = re
+	return r, true, nil
+}
+
+// Glob compiles pattern, written in the syntax of ignore rules, into a regular
+// expression matching whole paths.
+func Glob(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^" + translate(pattern) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+// translate turns a glob into a regular expression over slash separated
+// paths.
+func translate(pattern string) string {
+	var re strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		atSegmentStart := i == 0 || pattern[i-1] == '/'
+		switch c := pattern[i]; {
+		case atSegmentStart && strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case atSegmentStart && pattern[i:] == "**":
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return re.String()
+}
+
+// trimTrailingSpaces drops trailing spaces unless they are escaped with a
+// backslash.
+func trimTrailingSpaces(pattern string) string {
+	for strings.HasSuffix(pattern, " ") && !strings.HasSuffix(pattern, `\ `) {
+		pattern = pattern[:len(pattern)-1]
+	}
+	if strings.HasSuffix(pattern, `\ `) {
+		pattern = pattern[:len(pattern)-2] + " "
+	}
+	return pattern
+}
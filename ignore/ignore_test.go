@@ -0,0 +1,99 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	m, err := New([]string{
+		"# editor files",
+		"*.swp",
+		"*~",
+		"node_modules/",
+		"**/.git/objects/**",
+		"/var/log/",
+		"!/var/log/keep.log",
+		"home/*/tmp",
+		"**/cache/*.bin",
+		"[Bb]uild/",
+		`\#notes`,
+		"trailing   ",
+	}, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"/home/alice/.notes.txt.swp", false, true},
+		{"/home/alice/notes.txt~", false, true},
+		{"/home/alice/notes.txt", false, false},
+		{"/home/alice/app/node_modules", true, true},
+		{"/home/alice/app/node_modules/lodash/index.js", false, true},
+		{"/home/alice/node_modules", false, false},
+		{"/home/alice/repo/.git/objects/ab/cdef", false, true},
+		{"/home/alice/repo/.git/HEAD", false, false},
+		{"/var/log/syslog", false, true},
+		// A file below an ignored directory cannot be re-included.
+		{"/var/log/keep.log", false, true},
+		{"/srv/var/log/syslog", false, false},
+		{"/home/bob/tmp/x", false, true},
+		{"/home/bob/sub/tmp", false, false},
+		{"/opt/cache/blob.bin", false, true},
+		{"/opt/cache/deep/blob.bin", false, false},
+		{"/src/Build/out.o", false, true},
+		{"/src/build/out.o", false, true},
+		{"/src/rebuild/out.o", false, false},
+		{"/home/alice/#notes", false, true},
+		{"/home/alice/trailing", false, true},
+		{"/", true, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ignored, m.Match(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestMatchNegation(t *testing.T) {
+	m, err := New([]string{"*.log", "!important.log", "logs/*", "!logs/keep"}, "")
+	require.NoError(t, err)
+
+	assert.True(t, m.Match("/srv/debug.log", false))
+	assert.False(t, m.Match("/srv/important.log", false))
+	assert.True(t, m.Match("/logs/other", false))
+	assert.False(t, m.Match("/logs/keep", false))
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "dist"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".fimignore"), []byte("*.tmp\n/top-only\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".fimignore"), []byte("dist/\n!keep.tmp\n"), 0644))
+
+	m, err := New(nil, ".fimignore")
+	require.NoError(t, err)
+	assert.False(t, m.Empty())
+
+	assert.True(t, m.Match(filepath.Join(dir, "a.tmp"), false))
+	assert.True(t, m.Match(filepath.Join(dir, "top-only"), false))
+	assert.False(t, m.Match(filepath.Join(project, "top-only"), false))
+	assert.True(t, m.Match(filepath.Join(project, "dist", "bundle.js"), false))
+	// Rules in deeper files override shallower ones.
+	assert.False(t, m.Match(filepath.Join(project, "keep.tmp"), false))
+	assert.True(t, m.Match(filepath.Join(project, "other.tmp"), false))
+	// Rules only apply below the directory holding the file.
+	assert.False(t, m.Match(filepath.Join(dir, "dist", "bundle.js"), false))
+}
+
+func TestNewEmpty(t *testing.T) {
+	m, err := New([]string{"", "# comment"}, "")
+	require.NoError(t, err)
+	assert.True(t, m.Empty())
+	assert.False(t, m.Match("/anything", false))
+}
@@ -0,0 +1,200 @@
+// Package metrics holds the Prometheus metrics of the tracker. Every metric
+// is registered on Registry, which the server exposes at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "filemodtracker"
+
+// Registry holds the tracker's metrics along with the Go runtime and
+// process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	eventsCollected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_collected_total",
+		Help:      "File events collected into the event store.",
+	}, []string{"action", "category"})
+
+	collectionLag = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "collection_lag_seconds",
+		Help:      "Time from a file event to its collection into the event store.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600},
+	})
+
+	lastCollection = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_collection_timestamp_seconds",
+		Help:      "Unix time of the last successful collection.",
+	})
+
+	osqueryQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "osquery_query_duration_seconds",
+		Help:      "Latency of osquery queries.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"transport", "result"})
+
+	osqueryRestarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "osquery_restarts_total",
+		Help:      "Restarts of the supervised osquery process.",
+	})
+
+	supervisorState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "osquery_supervisor_state",
+		Help:      "State of the supervised osquery process; 1 for the current state.",
+	}, []string{"state"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	alertsRaised = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_raised_total",
+		Help:      "Alerts raised by alert rules.",
+	}, []string{"rule", "severity"})
+
+	commandsReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_received_total",
+		Help:      "Commands accepted by /command and /execute.",
+	})
+
+	commandsExecuted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_executed_total",
+		Help:      "Commands run, whether or not they succeeded.",
+	})
+
+	commandsFailed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_failed_total",
+		Help:      "Commands that could not be run or exited with an error.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		eventsCollected,
+		collectionLag,
+		lastCollection,
+		osqueryQueryDuration,
+		osqueryRestarts,
+		supervisorState,
+		httpRequests,
+		httpRequestDuration,
+		alertsRaised,
+		commandsReceived,
+		commandsExecuted,
+		commandsFailed,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// EventCollected counts an event collected lag after it happened.
+func EventCollected(action, category string, lag time.Duration) {
+	eventsCollected.WithLabelValues(action, category).Inc()
+	collectionLag.Observe(lag.Seconds())
+}
+
+// Collected records that a collection succeeded at t.
+func Collected(t time.Time) {
+	lastCollection.Set(float64(t.UnixNano()) / 1e9)
+}
+
+// OsqueryQuery records the latency of a query sent over transport, the
+// extension socket or osqueryi.
+func OsqueryQuery(transport string, duration time.Duration, err error) {
+	osqueryQueryDuration.WithLabelValues(transport, result(err)).Observe(duration.Seconds())
+}
+
+// OsqueryRestarted counts restarts of the supervised osquery process.
+func OsqueryRestarted(restarts int) {
+	if restarts > 0 {
+		osqueryRestarts.Add(float64(restarts))
+	}
+}
+
+// SupervisorState records the current state of the supervised osquery
+// process. Only the current state is reported.
+func SupervisorState(state string) {
+	supervisorState.Reset()
+	supervisorState.WithLabelValues(state).Set(1)
+}
+
+// HTTPRequest records a request to route, the pattern it matched.
+func HTTPRequest(method, route string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// AlertRaised counts an alert raised by rule.
+func AlertRaised(rule, severity string) {
+	alertsRaised.WithLabelValues(rule, severity).Inc()
+}
+
+// CommandReceived counts a command accepted for execution.
+func CommandReceived() {
+	commandsReceived.Inc()
+}
+
+// CommandExecuted counts a command that was run, and a failure if err is
+// not nil.
+func CommandExecuted(err error) {
+	commandsExecuted.Inc()
+	if err != nil {
+		commandsFailed.Inc()
+	}
+}
+
+// CommandQueue reports the depth and capacity of the queue commands wait
+// in for the daemon. It must only be called once.
+func CommandQueue(depth func() int, capacity int) {
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "command_queue_depth",
+			Help:      "Commands waiting for the daemon to run them.",
+		}, func() float64 { return float64(depth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "command_queue_capacity",
+			Help:      "Commands that can wait for the daemon before /command blocks.",
+		}, func() float64 { return float64(capacity) }),
+	)
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventCollected(t *testing.T) {
+	EventCollected("CREATED", "config", 2*time.Second)
+	EventCollected("CREATED", "config", 3*time.Second)
+	EventCollected("DELETED", "", time.Second)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(eventsCollected.WithLabelValues("CREATED", "config")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(eventsCollected.WithLabelValues("DELETED", "")))
+
+	Collected(time.Unix(1700000000, 0))
+	assert.Equal(t, 1700000000.0, testutil.ToFloat64(lastCollection))
+}
+
+func TestSupervisorState(t *testing.T) {
+	SupervisorState("restarting")
+	SupervisorState("running")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(supervisorState))
+	assert.Equal(t, 1.0, testutil.ToFloat64(supervisorState.WithLabelValues("running")))
+
+	before := testutil.ToFloat64(osqueryRestarts)
+	OsqueryRestarted(2)
+	OsqueryRestarted(0)
+	assert.Equal(t, before+2, testutil.ToFloat64(osqueryRestarts))
+}
+
+func TestCommands(t *testing.T) {
+	executed, failed := testutil.ToFloat64(commandsExecuted), testutil.ToFloat64(commandsFailed)
+
+	CommandExecuted(nil)
+	CommandExecuted(errors.New("exit status 1"))
+
+	assert.Equal(t, executed+2, testutil.ToFloat64(commandsExecuted))
+	assert.Equal(t, failed+1, testutil.ToFloat64(commandsFailed))
+}
+
+func TestHandler(t *testing.T) {
+	queue := make(chan struct{}, 10)
+	queue <- struct{}{}
+	CommandQueue(func() int { return len(queue) }, cap(queue))
+	OsqueryQuery("socket", 30*time.Millisecond, nil)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "filemodtracker_command_queue_depth 1\n")
+	assert.Contains(t, body, "filemodtracker_command_queue_capacity 10\n")
+	assert.Contains(t, body, `filemodtracker_osquery_query_duration_seconds_count{result="ok",transport="socket"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
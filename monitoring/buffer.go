@@ -0,0 +1,149 @@
+package monitoring
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBufferSize mirrors osquery's default events_max so backends that keep
+// their own history hold roughly as many rows as the file_events table would.
+const defaultBufferSize = 50000
+
+// eventBuffer is a bounded, in-memory history of file events for backends that
+// generate events themselves instead of querying osquery for them.
+type eventBuffer struct {
+	mutex  sync.RWMutex
+	events []FileEvent
+	max    int
+	eid    uint64
+	// stream tells this buffer's cursors apart from those of buffers before
+	// a restart, whose IDs counted from 1 as well.
+	stream string
+}
+
+func newEventBuffer(max int) *eventBuffer {
+	if max <= 0 {
+		max = defaultBufferSize
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return &eventBuffer{max: max, stream: hex.EncodeToString(id)}
+}
+
+// add stamps the event with an ID and appends it, evicting the oldest events
+// once the buffer is full.
+func (b *eventBuffer) add(event FileEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.eid++
+	event.ID = strconv.FormatUint(b.eid, 10)
+	b.events = append(b.events, event)
+	if len(b.events) > b.max {
+		b.events = append(b.events[:0:0], b.events[len(b.events)-b.max:]...)
+	}
+}
+
+func (b *eventBuffer) all() []FileEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	events := make([]FileEvent, len(b.events))
+	copy(events, b.events)
+	return events
+}
+
+// since returns the events added after cursor and the cursor following them.
+// Events evicted before they were read are lost.
+func (b *eventBuffer) since(cursor Cursor) ([]FileEvent, Cursor) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if cursor.Stream != b.stream {
+		cursor = Cursor{Stream: b.stream}
+	}
+	i := sort.Search(len(b.events), func(i int) bool {
+		return eventSeq(b.events[i]) > cursor.Seq
+	})
+	events := make([]FileEvent, len(b.events)-i)
+	copy(events, b.events[i:])
+
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		cursor.Seq, cursor.Time = eventSeq(last), last.Time
+	}
+	return events, cursor
+}
+
+// head returns the cursor after the newest event.
+func (b *eventBuffer) head() Cursor {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	cursor := Cursor{Stream: b.stream, Seq: b.eid}
+	if len(b.events) > 0 {
+		cursor.Time = b.events[len(b.events)-1].Time
+	}
+	return cursor
+}
+
+func (b *eventBuffer) byPath(path string, since time.Time) []FileEvent {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var events []FileEvent
+	for _, event := range b.events {
+		if event.Time.After(since) && strings.HasPrefix(event.TargetPath, path) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (b *eventBuffer) summary(since time.Time) []ActionSummary {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return summarize(b.events, since)
+}
+
+// summarize returns one summary per action for the events after since, like
+// the osquery GetFileChangesSummary query.
+func summarize(events []FileEvent, since time.Time) []ActionSummary {
+	summaries := make(map[Action]*ActionSummary)
+	for _, event := range events {
+		if !event.Time.After(since) {
+			continue
+		}
+		s, ok := summaries[event.Action]
+		if !ok {
+			summaries[event.Action] = &ActionSummary{
+				Action:          event.Action,
+				Count:           1,
+				FirstOccurrence: event.Time,
+				LastOccurrence:  event.Time,
+			}
+			continue
+		}
+		s.Count++
+		if event.Time.Before(s.FirstOccurrence) {
+			s.FirstOccurrence = event.Time
+		}
+		if event.Time.After(s.LastOccurrence) {
+			s.LastOccurrence = event.Time
+		}
+	}
+
+	results := make([]ActionSummary, 0, len(summaries))
+	for _, s := range summaries {
+		results = append(results, *s)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Action < results[j].Action
+	})
+	return results
+}
@@ -0,0 +1,323 @@
+package monitoring
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// emptySHA256 is the hash of an empty file. Unrelated empty files share it, so
+// it never links events.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxGroupWindows caps how many windows one group may span from its first
+// event, so a file written more often than once a window still yields an
+// event every few windows instead of one that never ends.
+const maxGroupWindows = 5
+
+type (
+	// RawEventSource is implemented by monitors that merge raw events into
+	// logical ones and can still return the raw rows.
+	RawEventSource interface {
+		GetRawFileEvents(ctx context.Context) ([]FileEvent, error)
+	}
+
+	// CoalescingMonitor merges the bursts of raw events an editor or package
+	// manager produces for one change into a single logical event. The raw
+	// rows stay available through GetRawFileEvents.
+	CoalescingMonitor struct {
+		Monitor
+		window time.Duration
+	}
+)
+
+var (
+	_ Monitor        = (*CoalescingMonitor)(nil)
+	_ RawEventSource = (*CoalescingMonitor)(nil)
+	_ EventScanner   = (*CoalescingMonitor)(nil)
+)
+
+func NewCoalescing(monitor Monitor, window time.Duration) *CoalescingMonitor {
+	return &CoalescingMonitor{Monitor: monitor, window: window}
+}
+
+// Unwrap returns the monitor whose events are coalesced.
+func (c *CoalescingMonitor) Unwrap() Monitor {
+	return c.Monitor
+}
+
+func (c *CoalescingMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	events, err := c.Monitor.GetFileEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return coalesce(events, c.window), nil
+}
+
+func (c *CoalescingMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	events, err := c.Monitor.GetFileEventsByPath(ctx, path, since)
+	if err != nil {
+		return nil, err
+	}
+	return coalesce(events, c.window), nil
+}
+
+// GetFileChangesSummary counts logical changes rather than raw rows.
+func (c *CoalescingMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	events, err := c.GetFileEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(events, since), nil
+}
+
+func (c *CoalescingMonitor) GetRawFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return c.Monitor.GetFileEvents(ctx)
+}
+
+// ScanEvents coalesces the events of the wrapped monitor's scan as they are
+// read. Raw events are read a window beyond the bounds, so changes crossing
+// them are merged whole, and are coalesced in runs that end where no event
+// follows within a window, since no change can span that gap.
+func (c *CoalescingMonitor) ScanEvents(ctx context.Context, bounds *ScanBounds, fn func(FileEvent) bool) error {
+	scanner, ok := c.Monitor.(EventScanner)
+	if !ok {
+		return ErrScanUnsupported
+	}
+
+	raw := *bounds
+	widen := func() {
+		raw.Since, raw.Until = bounds.Since, bounds.Until
+		if !raw.Since.IsZero() {
+			raw.Since = raw.Since.Add(-c.window)
+		}
+		if !raw.Until.IsZero() {
+			raw.Until = raw.Until.Add(c.window)
+		}
+	}
+	widen()
+
+	var run []FileEvent
+	var oldest, newest time.Time
+	flush := func() bool {
+		if bounds.Descending {
+			// coalesce relies on recorded order for events at the same time.
+			slices.Reverse(run)
+		}
+		for _, event := range coalesce(run, c.window) {
+			if bounds.contains(event) && !fn(event) {
+				return false
+			}
+		}
+		run = run[:0]
+		widen()
+		return true
+	}
+
+	stopped := false
+	err := scanner.ScanEvents(ctx, &raw, func(event FileEvent) bool {
+		if len(run) > 0 && (event.Time.Sub(newest) > c.window || oldest.Sub(event.Time) > c.window) {
+			if !flush() {
+				stopped = true
+				return false
+			}
+		}
+		if len(run) == 0 || event.Time.Before(oldest) {
+			oldest = event.Time
+		}
+		if len(run) == 0 || event.Time.After(newest) {
+			newest = event.Time
+		}
+		run = append(run, event)
+		return true
+	})
+	if err != nil || stopped || len(run) == 0 {
+		return err
+	}
+	flush()
+	return nil
+}
+
+// coalesce groups events that happened within window of each other and share
+// a path, an inode, or, for the two halves of a move, a content hash, as long
+// as the group spans no more than maxGroupWindows windows. Each
+// group that reads as one change becomes a single logical event; groups that
+// do not are returned as they are.
+func coalesce(events []FileEvent, window time.Duration) []FileEvent {
+	if window <= 0 || len(events) < 2 {
+		return events
+	}
+
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return events[order[a]].Time.Before(events[order[b]].Time)
+	})
+
+	groups := newUnionFind(len(events))
+	// start holds the time of the first event of each group, by root.
+	start := make([]time.Time, len(events))
+	for i, event := range events {
+		start[i] = event.Time
+	}
+	maxSpan := maxGroupWindows * window
+	lastSeen := make(map[string]int)
+	link := func(key string, i int) {
+		if j, ok := lastSeen[key]; ok && events[i].Time.Sub(events[j].Time) <= window {
+			a, b := groups.find(i), groups.find(j)
+			first := start[a]
+			if start[b].Before(first) {
+				first = start[b]
+			}
+			// Events are linked in time order, so events[i] is the group's last.
+			if a != b && events[i].Time.Sub(first) <= maxSpan {
+				groups.union(a, b)
+				start[groups.find(a)] = first
+			}
+		}
+		lastSeen[key] = i
+	}
+	for _, i := range order {
+		event := events[i]
+		if event.TargetPath != "" {
+			link("path:"+event.TargetPath, i)
+		}
+		if event.Inode != 0 {
+			link("inode:"+strconv.FormatUint(event.Inode, 10), i)
+		}
+		if hash := event.sha256(); hash != "" && hash != emptySHA256 {
+			// A hash only pairs a removed path with the next path that
+			// appears with the same content, so copies stay separate.
+			switch event.Action {
+			case ActionDeleted, ActionMovedFrom:
+				lastSeen["moved:"+hash] = i
+			case ActionCreated, ActionMovedTo:
+				link("moved:"+hash, i)
+				delete(lastSeen, "moved:"+hash)
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for _, i := range order {
+		root := groups.find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+
+	results := make([]FileEvent, 0, len(roots))
+	for _, root := range roots {
+		group := make([]FileEvent, 0, len(members[root]))
+		for _, i := range members[root] {
+			group = append(group, events[i])
+		}
+		if logical, ok := logicalEvent(group); ok {
+			results = append(results, logical)
+		} else {
+			results = append(results, group...)
+		}
+	}
+	return results
+}
+
+// logicalEvent describes a time ordered group of raw events as one change, by
+// comparing which paths existed before the group and which exist after it.
+func logicalEvent(group []FileEvent) (FileEvent, bool) {
+	if len(group) == 1 {
+		return group[0], true
+	}
+
+	first := make(map[string]Action)
+	last := make(map[string]FileEvent)
+	var paths []string
+	for _, event := range group {
+		if _, ok := first[event.TargetPath]; !ok {
+			first[event.TargetPath] = event.Action
+			paths = append(paths, event.TargetPath)
+		}
+		last[event.TargetPath] = event
+	}
+
+	var before, after, both []string
+	for _, path := range paths {
+		existed := first[path] != ActionCreated && first[path] != ActionMovedTo
+		lastAction := last[path].Action
+		exists := lastAction != ActionDeleted && lastAction != ActionMovedFrom
+		switch {
+		case existed && exists:
+			both = append(both, path)
+		case existed:
+			before = append(before, path)
+		case exists:
+			after = append(after, path)
+		}
+	}
+
+	var logical FileEvent
+	switch {
+	case len(both) == 1 && len(paths) == 1:
+		logical = last[both[0]]
+		logical.Action = singlePathAction(group)
+	case len(both) == 1:
+		logical = last[both[0]]
+		logical.Action = ActionUpdated
+	case len(both) == 0 && len(before) == 1 && len(after) == 1:
+		logical = last[after[0]]
+		logical.Action = ActionMoved
+		logical.Path = before[0]
+	case len(both) == 0 && len(before) == 0 && len(after) == 1:
+		logical = last[after[0]]
+		logical.Action = ActionCreated
+	case len(both) == 0 && len(before) == 1 && len(after) == 0:
+		logical = last[before[0]]
+		logical.Action = ActionDeleted
+	default:
+		return FileEvent{}, false
+	}
+
+	logical.RawIDs = make([]string, 0, len(group))
+	for _, event := range group {
+		logical.RawIDs = append(logical.RawIDs, event.ID)
+	}
+	return logical, true
+}
+
+// singlePathAction summarises repeated events for one existing path: only
+// attribute changes stay ATTRIBUTES_MODIFIED, anything else is an update.
+func singlePathAction(group []FileEvent) Action {
+	for _, event := range group {
+		if event.Action != ActionAttributesModified {
+			return ActionUpdated
+		}
+	}
+	return ActionAttributesModified
+}
+
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	u := make(unionFind, n)
+	for i := range u {
+		u[i] = i
+	}
+	return u
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) union(a, b int) {
+	u[u.find(a)] = u.find(b)
+}
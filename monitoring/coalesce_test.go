@@ -0,0 +1,162 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+// rawEvent builds an event the way a backend records it.
+func rawEvent(id, path string, action Action, inode uint64, t int64) FileEvent {
+	return FileEvent{ID: id, Time: time.Unix(t, 0), Action: action, Path: path, TargetPath: path, Inode: inode}
+}
+
+func withHash(event FileEvent, sha256 string) FileEvent {
+	event.Hashes = &Hashes{SHA256: sha256}
+	return event
+}
+
+func TestCoalesce(t *testing.T) {
+	moved := rawEvent("2", "/srv/b.txt", ActionMovedTo, 30, 100)
+	moved.Action, moved.Path, moved.RawIDs = ActionMoved, "/srv/a.txt", []string{"1", "2"}
+
+	saved := rawEvent("6", "/etc/app/config.yaml", ActionMovedTo, 20, 101)
+	saved.Action, saved.RawIDs = ActionUpdated, []string{"1", "2", "3", "4", "5", "6", "7"}
+
+	renamed := withHash(rawEvent("2", "/srv/b.txt", ActionCreated, 31, 101), "abc")
+	renamed.Action, renamed.Path, renamed.RawIDs = ActionMoved, "/srv/a.txt", []string{"1", "2"}
+
+	written := rawEvent("3", "/srv/new.txt", ActionAttributesModified, 0, 101)
+	written.Action, written.RawIDs = ActionCreated, []string{"1", "2", "3"}
+
+	streamed := rawEvent("6", "/srv/log.txt", ActionUpdated, 0, 110)
+	streamed.RawIDs = []string{"1", "2", "3", "4", "5", "6"}
+
+	tests := []struct {
+		name     string
+		events   []FileEvent
+		expected []FileEvent
+	}{
+		{
+			name: "editor save through a temp file and backup",
+			events: []FileEvent{
+				rawEvent("1", "/etc/app/config.yaml.tmp", ActionCreated, 20, 100),
+				rawEvent("2", "/etc/app/config.yaml.tmp", ActionUpdated, 20, 100),
+				rawEvent("3", "/etc/app/config.yaml", ActionMovedFrom, 10, 101),
+				rawEvent("4", "/etc/app/config.yaml~", ActionMovedTo, 10, 101),
+				rawEvent("5", "/etc/app/config.yaml.tmp", ActionMovedFrom, 20, 101),
+				rawEvent("6", "/etc/app/config.yaml", ActionMovedTo, 20, 101),
+				rawEvent("7", "/etc/app/config.yaml~", ActionDeleted, 10, 102),
+			},
+			expected: []FileEvent{saved},
+		},
+		{
+			name: "rename by inode",
+			events: []FileEvent{
+				rawEvent("1", "/srv/a.txt", ActionMovedFrom, 30, 100),
+				rawEvent("2", "/srv/b.txt", ActionMovedTo, 30, 100),
+			},
+			expected: []FileEvent{moved},
+		},
+		{
+			name: "rename seen by polling is matched by hash",
+			events: []FileEvent{
+				withHash(rawEvent("1", "/srv/a.txt", ActionDeleted, 0, 100), "abc"),
+				withHash(rawEvent("2", "/srv/b.txt", ActionCreated, 31, 101), "abc"),
+				withHash(rawEvent("3", "/srv/c.txt", ActionCreated, 32, 101), "abc"),
+			},
+			expected: []FileEvent{
+				renamed,
+				withHash(rawEvent("3", "/srv/c.txt", ActionCreated, 32, 101), "abc"),
+			},
+		},
+		{
+			name: "new file written in several steps",
+			events: []FileEvent{
+				rawEvent("1", "/srv/new.txt", ActionCreated, 0, 100),
+				rawEvent("2", "/srv/new.txt", ActionUpdated, 0, 100),
+				rawEvent("3", "/srv/new.txt", ActionAttributesModified, 0, 101),
+			},
+			expected: []FileEvent{written},
+		},
+		{
+			name: "events outside the window stay separate",
+			events: []FileEvent{
+				rawEvent("1", "/srv/log.txt", ActionUpdated, 0, 100),
+				rawEvent("2", "/srv/log.txt", ActionUpdated, 0, 110),
+			},
+			expected: []FileEvent{
+				rawEvent("1", "/srv/log.txt", ActionUpdated, 0, 100),
+				rawEvent("2", "/srv/log.txt", ActionUpdated, 0, 110),
+			},
+		},
+		{
+			name: "a steady stream is cut off after five windows",
+			events: []FileEvent{
+				rawEvent("1", "/srv/log.txt", ActionUpdated, 0, 100),
+				rawEvent("2", "/srv/log.txt", ActionUpdated, 0, 102),
+				rawEvent("3", "/srv/log.txt", ActionUpdated, 0, 104),
+				rawEvent("4", "/srv/log.txt", ActionUpdated, 0, 106),
+				rawEvent("5", "/srv/log.txt", ActionUpdated, 0, 108),
+				rawEvent("6", "/srv/log.txt", ActionUpdated, 0, 110),
+				rawEvent("7", "/srv/log.txt", ActionUpdated, 0, 112),
+			},
+			expected: []FileEvent{
+				streamed,
+				rawEvent("7", "/srv/log.txt", ActionUpdated, 0, 112),
+			},
+		},
+		{
+			name: "a file created and removed is kept raw",
+			events: []FileEvent{
+				rawEvent("1", "/tmp/lock", ActionCreated, 0, 100),
+				rawEvent("2", "/tmp/lock", ActionDeleted, 0, 100),
+			},
+			expected: []FileEvent{
+				rawEvent("1", "/tmp/lock", ActionCreated, 0, 100),
+				rawEvent("2", "/tmp/lock", ActionDeleted, 0, 100),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, coalesce(tt.events, 2*time.Second))
+		})
+	}
+}
+
+func TestCoalescingMonitor(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	polling, err := NewPolling(DirWatches([]string{t.TempDir() + "/%%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	polling.events.add(rawEvent("", "/home/alice/a.txt", ActionMovedFrom, 7, 100))
+	polling.events.add(rawEvent("", "/home/alice/b.txt", ActionMovedTo, 7, 100))
+	polling.events.add(rawEvent("", "/home/alice/c.txt", ActionUpdated, 8, 120))
+	monitor := NewCoalescing(polling, 2*time.Second)
+
+	events, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, ActionMoved, events[0].Action)
+	assert.Equal(t, "/home/alice/a.txt", events[0].Path)
+	assert.Equal(t, "/home/alice/b.txt", events[0].TargetPath)
+	assert.Equal(t, []string{"1", "2"}, events[0].RawIDs)
+
+	raw, err := monitor.GetRawFileEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, raw, 3)
+
+	summary, err := monitor.GetFileChangesSummary(context.Background(), time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Len(t, summary, 2)
+
+	assert.Same(t, polling, monitor.Unwrap())
+}
@@ -0,0 +1,156 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// osqueryStream names the cursors of the osquery backend, whose event
+	// IDs are kept in osquery's own database rather than by the tracker.
+	osqueryStream = "osquery"
+	// storeStream names the cursors of a StoredMonitor, which count in
+	// event store sequence numbers.
+	storeStream = "store"
+)
+
+var ErrCursorUnsupported = errors.New("monitor cannot deliver events incrementally")
+
+type (
+	// Cursor marks how far a consumer has read a monitor's events. The zero
+	// Cursor is before the first event. Cursors are JSON encoded so they can
+	// be saved and passed back after a restart.
+	Cursor struct {
+		// Stream names the sequence Seq counts in. A cursor from another
+		// stream, for example one saved before an in-memory backend was
+		// restarted, starts again from the oldest event.
+		Stream string `json:"stream,omitempty"`
+		// Seq is the sequence number, or osquery eid, of the last event read.
+		Seq uint64 `json:"seq"`
+		// Time is when the last event read happened.
+		Time time.Time `json:"time"`
+	}
+
+	// EventSource is implemented by monitors that can hand out their events
+	// incrementally. EventsSince returns the events after cursor in the
+	// order they happened, and the cursor to pass on the next call, so every
+	// event is returned exactly once however often it is called. It may
+	// return only the oldest of many events; the rest follow on the next
+	// call. Head returns the cursor after the newest event, where a consumer
+	// that only wants new events starts.
+	EventSource interface {
+		EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error)
+		Head(ctx context.Context) (Cursor, error)
+	}
+
+	// Notifier is implemented by monitors that know when they have new
+	// events. The channel returned by Updated is closed once events newer
+	// than the call are available.
+	Notifier interface {
+		Updated() <-chan struct{}
+	}
+)
+
+// EventSourceOf returns the outermost monitor in the chain wrapped by m that
+// hands out events incrementally.
+func EventSourceOf(m Monitor) (EventSource, bool) {
+	for m != nil {
+		if source, ok := m.(EventSource); ok {
+			return source, true
+		}
+		wrapper, ok := m.(interface{ Unwrap() Monitor })
+		if !ok {
+			break
+		}
+		m = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// ParseCursor decodes a cursor encoded by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// String encodes the cursor as an opaque token that is safe in URLs and
+// HTTP headers.
+func (c Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// At returns the cursor just after event, which was read from c's stream.
+func (c Cursor) At(event FileEvent) Cursor {
+	return Cursor{Stream: c.Stream, Seq: eventSeq(event), Time: event.Time}
+}
+
+// Before reports whether c is an earlier position than other. Cursors of
+// different streams are not ordered.
+func (c Cursor) Before(other Cursor) bool {
+	if c.Stream != other.Stream {
+		return false
+	}
+	if c.Stream == osqueryStream {
+		return !c.before(other.Time, other.Seq)
+	}
+	return c.Seq < other.Seq
+}
+
+func (c Cursor) equal(other Cursor) bool {
+	return c.Stream == other.Stream && c.Seq == other.Seq && c.Time.Equal(other.Time)
+}
+
+// before reports whether an event with the given time and sequence number
+// comes before the position c.
+func (c Cursor) before(t time.Time, seq uint64) bool {
+	if !t.Equal(c.Time) {
+		return t.Before(c.Time)
+	}
+	return seq <= c.Seq
+}
+
+// eventsAfter sorts events by time and sequence number and returns the ones
+// after cursor, together with the cursor following the last of them. It is
+// used for osquery, whose file_events table can only be filtered by whole
+// seconds and whose eids start again when its database is wiped.
+func eventsAfter(events []FileEvent, cursor Cursor) ([]FileEvent, Cursor) {
+	if cursor.Stream != osqueryStream {
+		cursor = Cursor{Stream: osqueryStream}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return eventSeq(events[i]) < eventSeq(events[j])
+	})
+
+	next := cursor
+	kept := events[:0:0]
+	for _, event := range events {
+		seq := eventSeq(event)
+		if cursor.before(event.Time, seq) {
+			continue
+		}
+		kept = append(kept, event)
+		next.Seq, next.Time = seq, event.Time
+	}
+	return kept, next
+}
+
+func eventSeq(event FileEvent) uint64 {
+	seq, _ := strconv.ParseUint(event.ID, 10, 64)
+	return seq
+}
@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func ids(events []FileEvent) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.ID)
+	}
+	return result
+}
+
+func TestEventsAfter(t *testing.T) {
+	events := []FileEvent{
+		rawEvent("12", "/a", ActionUpdated, 0, 101),
+		rawEvent("10", "/a", ActionCreated, 0, 100),
+		rawEvent("11", "/b", ActionCreated, 0, 100),
+	}
+
+	kept, cursor := eventsAfter(events, Cursor{})
+	assert.Equal(t, []string{"10", "11", "12"}, ids(kept))
+	assert.Equal(t, osqueryStream, cursor.Stream)
+	assert.Equal(t, uint64(12), cursor.Seq)
+
+	// The second of the cursor is read again; only later eids in it are new.
+	kept, _ = eventsAfter(events, Cursor{Stream: osqueryStream, Seq: 10, Time: events[1].Time})
+	assert.Equal(t, []string{"11", "12"}, ids(kept))
+
+	// After osquery's database was wiped eids start again, but time moves on.
+	wiped := []FileEvent{rawEvent("1", "/c", ActionCreated, 0, 102)}
+	kept, next := eventsAfter(wiped, cursor)
+	assert.Equal(t, []string{"1"}, ids(kept))
+
+	kept, after := eventsAfter(wiped, next)
+	assert.Empty(t, kept)
+	assert.True(t, after.equal(next))
+}
+
+func TestCursorString(t *testing.T) {
+	cursor := Cursor{Stream: osqueryStream, Seq: 11, Time: time.Unix(100, 0).UTC()}
+	parsed, err := ParseCursor(cursor.String())
+	require.NoError(t, err)
+	assert.True(t, parsed.equal(cursor))
+
+	_, err = ParseCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// osquery cursors order by time before eid, which restarts with its database.
+	assert.True(t, cursor.Before(Cursor{Stream: osqueryStream, Seq: 12, Time: cursor.Time}))
+	assert.True(t, cursor.Before(Cursor{Stream: osqueryStream, Seq: 1, Time: cursor.Time.Add(time.Second)}))
+	assert.False(t, cursor.Before(cursor))
+	assert.False(t, cursor.Before(Cursor{Stream: storeStream, Seq: 12}))
+	assert.True(t, Cursor{Stream: storeStream, Seq: 1}.Before(Cursor{Stream: storeStream, Seq: 2}))
+}
+
+func TestBufferSince(t *testing.T) {
+	buffer := newEventBuffer(3)
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		buffer.add(rawEvent("", path, ActionCreated, 0, 100))
+	}
+
+	// "1" was evicted before it was read.
+	events, cursor := buffer.since(Cursor{})
+	assert.Equal(t, []string{"2", "3", "4"}, ids(events))
+
+	buffer.add(rawEvent("", "/e", ActionCreated, 0, 101))
+	events, cursor = buffer.since(cursor)
+	assert.Equal(t, []string{"5"}, ids(events))
+
+	events, next := buffer.since(cursor)
+	assert.Empty(t, events)
+	assert.True(t, next.equal(cursor))
+
+	// A cursor from before a restart does not skip the new buffer's events.
+	events, _ = newEventBuffer(3).since(cursor)
+	assert.Empty(t, events)
+	restarted := newEventBuffer(3)
+	restarted.add(rawEvent("", "/f", ActionCreated, 0, 102))
+	events, _ = restarted.since(cursor)
+	assert.Equal(t, []string{"1"}, ids(events))
+}
+
+func TestOsqueryEventsSince(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "test_config.json")
+	defer os.Remove(configPath)
+
+	client, err := New(configPath, WithLogger(mockLogger), WithOsqueryBinary("echo"))
+	require.NoError(t, err)
+
+	// Two events in the same second, the second one only reported by the
+	// next poll.
+	hosts := map[string]string{"eid": "7", "path": "/etc/hosts", "action": "UPDATED", "time": "100"}
+	passwd := map[string]string{"eid": "8", "path": "/etc/passwd", "action": "UPDATED", "time": "100"}
+	var queries []string
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		queries = append(queries, query)
+		if strings.Contains(query, "time >= 0;") {
+			return fileEventRows(query, hosts)
+		}
+		return fileEventRows(query, hosts, passwd)
+	})
+
+	events, cursor, err := client.EventsSince(context.Background(), Cursor{})
+	require.NoError(t, err)
+	requireEventIDs(t, events)
+	assert.Equal(t, []string{"7"}, ids(events))
+	assert.Equal(t, "SELECT *, eid FROM file_events WHERE time >= 0;", queries[0])
+
+	events, cursor, err = client.EventsSince(context.Background(), cursor)
+	require.NoError(t, err)
+	requireEventIDs(t, events)
+	assert.Equal(t, []string{"8"}, ids(events))
+	assert.Equal(t, "SELECT *, eid FROM file_events WHERE time >= 100;", queries[1])
+	assert.Equal(t, uint64(8), cursor.Seq)
+}
@@ -0,0 +1,200 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// EventVersion is the version of the FileEvent JSON encoding described in
+// EVENTS.md. It changes when a field is renamed, removed or changes type;
+// adding an optional field does not change it.
+const EventVersion = 1
+
+// Action is what happened to a file. Backends report the actions of osquery's
+// file_events table; unknown actions are passed through unchanged.
+type Action string
+
+const (
+	ActionCreated            Action = "CREATED"
+	ActionUpdated            Action = "UPDATED"
+	ActionDeleted            Action = "DELETED"
+	ActionAttributesModified Action = "ATTRIBUTES_MODIFIED"
+	ActionMovedFrom          Action = "MOVED_FROM"
+	ActionMovedTo            Action = "MOVED_TO"
+	ActionOpened             Action = "OPENED"
+	ActionAccessed           Action = "ACCESSED"
+	// ActionMoved is only reported by CoalescingMonitor, for a file that was
+	// renamed from Path to TargetPath.
+	ActionMoved Action = "MOVED"
+)
+
+type (
+	// FileEvent is one change to a file, normalised from whichever backend
+	// observed it. File attributes are read when the event is recorded and
+	// are left out when the file could not be read, for example after it was
+	// deleted.
+	FileEvent struct {
+		// ID identifies the event within the monitor that recorded it.
+		ID     string    `json:"id"`
+		Time   time.Time `json:"time"`
+		Action Action    `json:"action"`
+		// Path is the file the event is about. For a MOVED event it is the
+		// old location and TargetPath the new one; otherwise they are equal.
+		Path       string     `json:"path"`
+		TargetPath string     `json:"target_path"`
+		Category   string     `json:"category"`
+		Severity   string     `json:"severity"`
+		Size       *int64     `json:"size,omitempty"`
+		Mode       string     `json:"mode,omitempty"`
+		UID        *uint32    `json:"uid,omitempty"`
+		GID        *uint32    `json:"gid,omitempty"`
+		Inode      uint64     `json:"inode,omitempty"`
+		AccessTime *time.Time `json:"atime,omitempty"`
+		ModTime    *time.Time `json:"mtime,omitempty"`
+		ChangeTime *time.Time `json:"ctime,omitempty"`
+		Hashes     *Hashes    `json:"hashes,omitempty"`
+		// Process is the process that caused the event, when the backend
+		// can tell.
+		Process *Process `json:"process,omitempty"`
+		// RawIDs lists the events a coalesced event was built from.
+		RawIDs []string `json:"raw_ids,omitempty"`
+	}
+
+	// Hashes are the hex encoded digests of a file's content.
+	Hashes struct {
+		MD5    string `json:"md5"`
+		SHA1   string `json:"sha1"`
+		SHA256 string `json:"sha256"`
+	}
+
+	// Process identifies a process. osquery reports it for events from
+	// process_file_events.
+	Process struct {
+		PID        int64  `json:"pid"`
+		PPID       int64  `json:"ppid,omitempty"`
+		Executable string `json:"executable,omitempty"`
+	}
+
+	// ActionSummary counts the events with one action.
+	ActionSummary struct {
+		Action          Action    `json:"action"`
+		Count           int       `json:"count"`
+		FirstOccurrence time.Time `json:"first_occurrence"`
+		LastOccurrence  time.Time `json:"last_occurrence"`
+	}
+)
+
+// MarshalJSON encodes the event with its format version.
+func (e FileEvent) MarshalJSON() ([]byte, error) {
+	type plain FileEvent
+	return json.Marshal(struct {
+		Version int `json:"version"`
+		plain
+	}{EventVersion, plain(e)})
+}
+
+// sha256 returns the event's SHA-256 digest, or "" if the file was not
+// hashed.
+func (e FileEvent) sha256() string {
+	if e.Hashes == nil {
+		return ""
+	}
+	return e.Hashes.SHA256
+}
+
+// eventFromRow normalises a file_events row. osquery returns every column as
+// a string, but rows built from other JSON may carry numbers.
+func eventFromRow(row map[string]interface{}) FileEvent {
+	path := rowString(row, "target_path")
+	if path == "" {
+		// Ad-hoc queries may only select "path".
+		path = rowString(row, "path")
+	}
+
+	event := FileEvent{
+		ID:         rowString(row, "eid"),
+		Action:     Action(rowString(row, "action")),
+		Path:       path,
+		TargetPath: path,
+		Category:   rowString(row, "category"),
+		Mode:       rowString(row, "mode"),
+	}
+	if t, ok := rowInt(row, "time"); ok {
+		event.Time = time.Unix(t, 0).UTC()
+	}
+	if size, ok := rowInt(row, "size"); ok {
+		event.Size = &size
+	}
+	if uid, ok := rowInt(row, "uid"); ok {
+		event.UID = ptr(uint32(uid))
+	}
+	if gid, ok := rowInt(row, "gid"); ok {
+		event.GID = ptr(uint32(gid))
+	}
+	if inode, ok := rowInt(row, "inode"); ok {
+		event.Inode = uint64(inode)
+	}
+	event.AccessTime = rowTime(row, "atime")
+	event.ModTime = rowTime(row, "mtime")
+	event.ChangeTime = rowTime(row, "ctime")
+
+	hashes := Hashes{
+		MD5:    rowString(row, "md5"),
+		SHA1:   rowString(row, "sha1"),
+		SHA256: rowString(row, "sha256"),
+	}
+	if hashes != (Hashes{}) {
+		event.Hashes = &hashes
+	}
+	if pid, ok := rowInt(row, "pid"); ok {
+		event.Process = &Process{PID: pid, Executable: rowString(row, "executable")}
+		event.Process.PPID, _ = rowInt(row, "ppid")
+	}
+	return event
+}
+
+// summaryFromRow reads a row of the osquery summary query.
+func summaryFromRow(row map[string]interface{}) ActionSummary {
+	summary := ActionSummary{Action: Action(rowString(row, "action"))}
+	if count, ok := rowInt(row, "count"); ok {
+		summary.Count = int(count)
+	}
+	if first, ok := rowInt(row, "first_occurrence"); ok {
+		summary.FirstOccurrence = time.Unix(first, 0).UTC()
+	}
+	if last, ok := rowInt(row, "last_occurrence"); ok {
+		summary.LastOccurrence = time.Unix(last, 0).UTC()
+	}
+	return summary
+}
+
+func rowString(row map[string]interface{}, column string) string {
+	switch value := row[column].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
+
+func rowInt(row map[string]interface{}, column string) (int64, bool) {
+	value, err := strconv.ParseInt(rowString(row, column), 10, 64)
+	return value, err == nil
+}
+
+// rowTime reads a Unix timestamp column, treating 0 as unknown.
+func rowTime(row map[string]interface{}, column string) *time.Time {
+	if t, ok := rowInt(row, column); ok && t > 0 {
+		return ptr(time.Unix(t, 0).UTC())
+	}
+	return nil
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,102 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFromRow(t *testing.T) {
+	event := eventFromRow(map[string]interface{}{
+		"eid":            "42",
+		"target_path":    "/etc/passwd",
+		"category":       "etc",
+		"action":         "UPDATED",
+		"transaction_id": "0",
+		"inode":          "1234",
+		"uid":            "0",
+		"gid":            "0",
+		"mode":           "0644",
+		"size":           float64(2048),
+		"atime":          "0",
+		"mtime":          "1700000000",
+		"ctime":          "",
+		"md5":            "m",
+		"sha1":           "s1",
+		"sha256":         "s256",
+		"hashed":         "1",
+		"time":           "1700000001",
+	})
+
+	assert.Equal(t, FileEvent{
+		ID:         "42",
+		Time:       time.Unix(1700000001, 0).UTC(),
+		Action:     ActionUpdated,
+		Path:       "/etc/passwd",
+		TargetPath: "/etc/passwd",
+		Category:   "etc",
+		Size:       ptr(int64(2048)),
+		Mode:       "0644",
+		UID:        ptr(uint32(0)),
+		GID:        ptr(uint32(0)),
+		Inode:      1234,
+		ModTime:    ptr(time.Unix(1700000000, 0).UTC()),
+		Hashes:     &Hashes{MD5: "m", SHA1: "s1", SHA256: "s256"},
+	}, event)
+
+	// Deleted files carry no attributes.
+	event = eventFromRow(map[string]interface{}{"path": "/tmp/gone", "action": "DELETED", "size": "", "uid": ""})
+	assert.Equal(t, "/tmp/gone", event.TargetPath)
+	assert.Nil(t, event.Size)
+	assert.Nil(t, event.UID)
+	assert.Nil(t, event.Hashes)
+	assert.Nil(t, event.Process)
+
+	// process_file_events rows name the process.
+	event = eventFromRow(map[string]interface{}{"path": "/etc/shadow", "action": "UPDATED", "pid": "812", "ppid": "1", "executable": "/usr/sbin/usermod"})
+	assert.Equal(t, &Process{PID: 812, PPID: 1, Executable: "/usr/sbin/usermod"}, event.Process)
+}
+
+func TestFileEventJSON(t *testing.T) {
+	event := FileEvent{
+		ID:         "7",
+		Time:       time.Unix(1700000001, 0).UTC(),
+		Action:     ActionMoved,
+		Path:       "/srv/a.txt",
+		TargetPath: "/srv/b.txt",
+		Category:   "homes",
+		Severity:   "info",
+		Size:       ptr(int64(0)),
+		Mode:       "0600",
+		UID:        ptr(uint32(501)),
+		GID:        ptr(uint32(20)),
+		Inode:      99,
+		RawIDs:     []string{"6", "7"},
+	}
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 1,
+		"id": "7",
+		"time": "2023-11-14T22:13:21Z",
+		"action": "MOVED",
+		"path": "/srv/a.txt",
+		"target_path": "/srv/b.txt",
+		"category": "homes",
+		"severity": "info",
+		"size": 0,
+		"mode": "0600",
+		"uid": 501,
+		"gid": 20,
+		"inode": 99,
+		"raw_ids": ["6", "7"]
+	}`, string(data))
+
+	var decoded FileEvent
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, event, decoded)
+}
@@ -0,0 +1,169 @@
+package monitoring
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultCategory is the file_paths category osquery reports for the
+// configured monitor directories and for watches without a label, see
+// createConfig.
+const defaultCategory = "homes"
+
+// maxHashSize caps how much data is read to hash a single file so one large
+// write cannot stall event collection.
+const maxHashSize = 64 << 20
+
+// errTooLargeToHash is returned by fileHashes for a file that has grown past
+// maxHashSize since it was stat'd.
+var errTooLargeToHash = errors.New("file too large to hash")
+
+type watchRoot struct {
+	path      string
+	recursive bool
+	label     string
+	severity  string
+	hash      *bool
+}
+
+// resolveWatchRoots turns watches into concrete paths by way of their osquery
+// file_paths patterns. A trailing "%%" watches everything below the prefix, a
+// trailing "%" watches one level, and any other "%" is expanded like a shell
+// "*". A path matched by several watches belongs to the first.
+func resolveWatchRoots(watches []Watch) []watchRoot {
+	var roots []watchRoot
+	seen := make(map[string]bool)
+	for _, watch := range watches {
+		recursive := false
+		base := watch.pattern()
+		switch {
+		case strings.HasSuffix(base, "%%"):
+			recursive = true
+			base = strings.TrimSuffix(base, "%%")
+		case strings.HasSuffix(base, "%"):
+			base = strings.TrimSuffix(base, "%")
+		}
+		base = filepath.Clean(strings.ReplaceAll(base, "%", "*"))
+
+		matches, err := filepath.Glob(base)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			roots = append(roots, watchRoot{
+				path:      match,
+				recursive: recursive,
+				label:     watch.label(),
+				severity:  watch.severity(),
+				hash:      watch.Hash,
+			})
+		}
+	}
+	return roots
+}
+
+// rootOf returns the most specific root containing path. Paths outside every
+// root get the default label and severity.
+func rootOf(roots []watchRoot, path string) watchRoot {
+	best := watchRoot{label: defaultCategory, severity: defaultSeverity}
+	for _, root := range roots {
+		if (path == root.path || strings.HasPrefix(path, root.path+string(filepath.Separator))) &&
+			len(root.path) > len(best.path) {
+			best = root
+		}
+	}
+	return best
+}
+
+// hashes reports whether files under the root are hashed, given the
+// backend's default.
+func (r watchRoot) hashes(backendDefault bool) bool {
+	if r.hash != nil {
+		return *r.hash
+	}
+	return backendDefault
+}
+
+// newFileEvent builds an event for path under root, filling in whatever can
+// still be read from the file.
+func newFileEvent(path string, root watchRoot, action Action, hash bool) FileEvent {
+	event, hashable := statFileEvent(path, root, action)
+	if hash && hashable {
+		setHashes(&event)
+	}
+	return event
+}
+
+// statFileEvent is newFileEvent without the hashes. It reports whether the
+// file is one newFileEvent would hash.
+func statFileEvent(path string, root watchRoot, action Action) (FileEvent, bool) {
+	event := FileEvent{
+		Time:       time.Now().UTC(),
+		Action:     action,
+		Path:       path,
+		TargetPath: path,
+		Category:   root.label,
+		Severity:   root.severity,
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return event, false
+	}
+	setFileInfo(&event, info)
+	return event, info.Mode().IsRegular() && info.Size() <= maxHashSize
+}
+
+func setHashes(event *FileEvent) {
+	if md5Sum, sha1Sum, sha256Sum, err := fileHashes(event.TargetPath); err == nil {
+		event.Hashes = &Hashes{MD5: md5Sum, SHA1: sha1Sum, SHA256: sha256Sum}
+	}
+}
+
+func setFileInfo(event *FileEvent, info os.FileInfo) {
+	event.Size = ptr(info.Size())
+	event.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	event.ModTime = ptr(info.ModTime().UTC())
+
+	if st, ok := statFileInfo(info); ok {
+		event.Inode = st.inode
+		event.UID = ptr(st.uid)
+		event.GID = ptr(st.gid)
+		event.AccessTime = ptr(st.atime.UTC())
+		event.ChangeTime = ptr(st.ctime.UTC())
+	}
+}
+
+func fileHashes(path string) (md5Sum, sha1Sum, sha256Sum string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer f.Close()
+
+	md5Hash, sha1Hash, sha256Hash := md5.New(), sha1.New(), sha256.New()
+	n, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash), io.LimitReader(f, maxHashSize+1))
+	if err != nil {
+		return "", "", "", err
+	}
+	if n > maxHashSize {
+		return "", "", "", errTooLargeToHash
+	}
+	return hex.EncodeToString(md5Hash.Sum(nil)),
+		hex.EncodeToString(sha1Hash.Sum(nil)),
+		hex.EncodeToString(sha256Hash.Sum(nil)),
+		nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin
+
+package monitoring
+
+import (
+	"os"
+	"time"
+)
+
+type fileStat struct {
+	inode        uint64
+	uid, gid     uint32
+	atime, ctime time.Time
+}
+
+// statFileInfo has no portable source for inode and ownership outside of
+// Linux and macOS, so those columns are left empty.
+func statFileInfo(info os.FileInfo) (fileStat, bool) {
+	return fileStat{}, false
+}
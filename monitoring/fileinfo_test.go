@@ -0,0 +1,70 @@
+package monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveWatchRoots(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "alice"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bob"), 0755))
+
+	roots := resolveWatchRoots(DirWatches([]string{dir + "/%%"}))
+	assert.Equal(t, []watchRoot{{path: dir, recursive: true, label: defaultCategory, severity: defaultSeverity}}, roots)
+
+	roots = resolveWatchRoots(DirWatches([]string{dir + "/%/%"}))
+	assert.ElementsMatch(t, []watchRoot{
+		{path: filepath.Join(dir, "alice"), label: defaultCategory, severity: defaultSeverity},
+		{path: filepath.Join(dir, "bob"), label: defaultCategory, severity: defaultSeverity},
+	}, roots)
+
+	assert.Empty(t, resolveWatchRoots(DirWatches([]string{filepath.Join(dir, "missing")})))
+
+	hash := false
+	roots = resolveWatchRoots([]Watch{
+		{Path: filepath.Join(dir, "*"), Label: "users", Severity: "high", Hash: &hash},
+		{Path: dir, Recursive: true, Label: "all"},
+	})
+	assert.ElementsMatch(t, []watchRoot{
+		{path: filepath.Join(dir, "alice"), label: "users", severity: "high", hash: &hash},
+		{path: filepath.Join(dir, "bob"), label: "users", severity: "high", hash: &hash},
+		{path: dir, recursive: true, label: "all", severity: defaultSeverity},
+	}, roots)
+
+	// The most specific root wins.
+	assert.Equal(t, "users", rootOf(roots, filepath.Join(dir, "alice", "notes.txt")).label)
+	assert.Equal(t, "all", rootOf(roots, filepath.Join(dir, "carol")).label)
+	assert.Equal(t, defaultCategory, rootOf(roots, "/elsewhere").label)
+	assert.False(t, roots[0].hashes(true))
+	assert.True(t, roots[2].hashes(true))
+}
+
+func TestWatchPattern(t *testing.T) {
+	assert.Equal(t, "/etc/%", Watch{Path: "/etc"}.pattern())
+	assert.Equal(t, "/var/www/%%", Watch{Path: "/var/www/", Recursive: true}.pattern())
+	assert.Equal(t, "/home/%/.ssh/%%", Watch{Path: "/home/*/.ssh", Recursive: true}.pattern())
+	assert.Equal(t, "/Users/%/%", Watch{Path: "/Users/%/%", Recursive: true}.pattern())
+	assert.Equal(t, "/srv/%/%", Watch{Path: "/srv/*"}.pattern())
+}
+
+func TestFileHashesSkipsLargeFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "large.bin")
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(maxHashSize+1))
+	require.NoError(t, f.Close())
+
+	_, _, _, err = fileHashes(file)
+	assert.ErrorIs(t, err, errTooLargeToHash)
+
+	require.NoError(t, os.Truncate(file, maxHashSize))
+	_, _, sha256Sum, err := fileHashes(file)
+	require.NoError(t, err)
+	assert.NotEmpty(t, sha256Sum)
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin
+
+package monitoring
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+type fileStat struct {
+	inode        uint64
+	uid, gid     uint32
+	atime, ctime time.Time
+}
+
+func statFileInfo(info os.FileInfo) (fileStat, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileStat{}, false
+	}
+	return fileStat{
+		inode: uint64(st.Ino),
+		uid:   st.Uid,
+		gid:   st.Gid,
+		atime: statAtime(st),
+		ctime: statCtime(st),
+	}, true
+}
@@ -0,0 +1,93 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/ignore"
+)
+
+type (
+	// FilteredMonitor drops events for ignored paths from another Monitor,
+	// so ignore rules apply the same way whichever backend produced them.
+	FilteredMonitor struct {
+		Monitor
+		matcher *ignore.Matcher
+	}
+)
+
+var (
+	_ Monitor     = (*FilteredMonitor)(nil)
+	_ EventSource = (*FilteredMonitor)(nil)
+)
+
+func NewFiltered(monitor Monitor, matcher *ignore.Matcher) *FilteredMonitor {
+	return &FilteredMonitor{Monitor: monitor, matcher: matcher}
+}
+
+// Unwrap returns the monitor whose events are filtered.
+func (f *FilteredMonitor) Unwrap() Monitor {
+	return f.Monitor
+}
+
+func (f *FilteredMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	events, err := f.Monitor.GetFileEvents(ctx)
+	return f.filter(events), err
+}
+
+func (f *FilteredMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	events, err := f.Monitor.GetFileEventsByPath(ctx, path, since)
+	return f.filter(events), err
+}
+
+// GetFileChangesSummary is computed from the filtered events, since the
+// backend's own summary would still count ignored paths.
+func (f *FilteredMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	events, err := f.GetFileEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(events, since), nil
+}
+
+// EventsSince drops events for ignored paths from the events of the wrapped
+// monitor. The cursor still moves past them.
+func (f *FilteredMonitor) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	source, ok := f.Monitor.(EventSource)
+	if !ok {
+		return nil, cursor, ErrCursorUnsupported
+	}
+	events, next, err := source.EventsSince(ctx, cursor)
+	return f.filter(events), next, err
+}
+
+func (f *FilteredMonitor) Head(ctx context.Context) (Cursor, error) {
+	source, ok := f.Monitor.(EventSource)
+	if !ok {
+		return Cursor{}, ErrCursorUnsupported
+	}
+	return source.Head(ctx)
+}
+
+func (f *FilteredMonitor) filter(events []FileEvent) []FileEvent {
+	if len(events) == 0 {
+		return events
+	}
+
+	kept := events[:0:0]
+	for _, event := range events {
+		if event.TargetPath != "" && f.matcher.Match(event.TargetPath, isDir(event.TargetPath)) {
+			continue
+		}
+		kept = append(kept, event)
+	}
+	return kept
+}
+
+// isDir reports whether path is a directory. Paths that no longer exist are
+// treated as files, so only rules without a trailing "/" match them.
+func isDir(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,68 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/ignore"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func TestFilteredMonitor(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	polling, err := NewPolling(DirWatches([]string{t.TempDir() + "/%%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	polling.events.add(rawEvent("", "/home/alice/app/node_modules/x/index.js", ActionCreated, 0, 100))
+	polling.events.add(rawEvent("", "/home/alice/.report.txt.swp", ActionUpdated, 0, 110))
+	polling.events.add(rawEvent("", "/home/alice/report.txt", ActionUpdated, 0, 120))
+	polling.events.add(rawEvent("", "/home/alice/repo/.git/objects/ab/cd", ActionCreated, 0, 130))
+
+	matcher, err := ignore.New([]string{"node_modules/", "*.swp", "**/.git/objects/"}, "")
+	require.NoError(t, err)
+	monitor := NewFiltered(polling, matcher)
+
+	events, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "/home/alice/report.txt", events[0].TargetPath)
+
+	events, err = monitor.GetFileEventsByPath(context.Background(), "/home/alice", time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	summary, err := monitor.GetFileChangesSummary(context.Background(), time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []ActionSummary{{
+		Action:          ActionUpdated,
+		Count:           1,
+		FirstOccurrence: time.Unix(120, 0),
+		LastOccurrence:  time.Unix(120, 0),
+	}}, summary)
+
+	assert.Same(t, polling, monitor.Unwrap())
+}
+
+func TestStatusOf(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger))
+	require.NoError(t, err)
+	matcher, err := ignore.New([]string{"*.swp"}, "")
+	require.NoError(t, err)
+
+	status, ok := StatusOf(NewFiltered(client, matcher))
+	assert.True(t, ok)
+	assert.Equal(t, StateStopped, status.State)
+
+	polling, err := NewPolling(nil, time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	_, ok = StatusOf(NewFiltered(polling, matcher))
+	assert.False(t, ok)
+}
@@ -0,0 +1,67 @@
+package monitoring
+
+const (
+	// hashWorkers is how many files are hashed at once.
+	hashWorkers = 4
+	// hashQueueSize bounds how many events wait for their hashes. Once it
+	// is reached, adding an event waits for the oldest to be handed on.
+	hashQueueSize = 1024
+)
+
+type (
+	// hasher hashes the files of events on a pool of workers, away from the
+	// loop reading file events, and hands the events on in the order they
+	// were added.
+	hasher struct {
+		emit    func(FileEvent)
+		queue   chan *hashJob
+		workers chan struct{}
+		done    chan struct{}
+	}
+
+	hashJob struct {
+		event  FileEvent
+		hashed chan struct{}
+	}
+)
+
+func newHasher(emit func(FileEvent)) *hasher {
+	h := &hasher{
+		emit:    emit,
+		queue:   make(chan *hashJob, hashQueueSize),
+		workers: make(chan struct{}, hashWorkers),
+		done:    make(chan struct{}),
+	}
+	go h.run()
+	return h
+}
+
+// add queues event, hashing its file first if hash is set.
+func (h *hasher) add(event FileEvent, hash bool) {
+	job := &hashJob{event: event, hashed: make(chan struct{})}
+	if hash {
+		go func() {
+			h.workers <- struct{}{}
+			setHashes(&job.event)
+			<-h.workers
+			close(job.hashed)
+		}()
+	} else {
+		close(job.hashed)
+	}
+	h.queue <- job
+}
+
+func (h *hasher) run() {
+	defer close(h.done)
+	for job := range h.queue {
+		<-job.hashed
+		h.emit(job.event)
+	}
+}
+
+// close hands on the events still queued and stops the hasher.
+func (h *hasher) close() {
+	close(h.queue)
+	<-h.done
+}
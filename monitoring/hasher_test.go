@@ -0,0 +1,40 @@
+package monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+
+	var emitted []FileEvent
+	h := newHasher(func(event FileEvent) {
+		emitted = append(emitted, event)
+	})
+
+	// Take every worker, so nothing can be hashed yet.
+	for i := 0; i < hashWorkers; i++ {
+		h.workers <- struct{}{}
+	}
+	h.add(FileEvent{ID: "1", TargetPath: file}, true)
+	h.add(FileEvent{ID: "2", TargetPath: file}, false)
+	h.add(FileEvent{ID: "3", TargetPath: filepath.Join(dir, "missing")}, true)
+	for i := 0; i < hashWorkers; i++ {
+		<-h.workers
+	}
+	h.close()
+
+	// Events are handed on in the order they were added, once hashed.
+	require.Equal(t, []string{"1", "2", "3"}, ids(emitted))
+	require.NotNil(t, emitted[0].Hashes)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", emitted[0].Hashes.SHA256)
+	assert.Nil(t, emitted[1].Hashes)
+	assert.Nil(t, emitted[2].Hashes)
+}
@@ -0,0 +1,123 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MaxHistogramBuckets bounds how many buckets one histogram series may have.
+const MaxHistogramBuckets = 10000
+
+const (
+	GroupByNone      GroupBy = ""
+	GroupByAction    GroupBy = "action"
+	GroupByCategory  GroupBy = "category"
+	GroupByDirectory GroupBy = "directory"
+)
+
+var ErrInvalidHistogram = errors.New("invalid histogram")
+
+type (
+	// GroupBy selects what splits a histogram into series.
+	GroupBy string
+
+	// HistogramSeries counts the events of one group per time bucket. Every
+	// series of a histogram has the same buckets, including empty ones.
+	HistogramSeries struct {
+		Group   string            `json:"group"`
+		Buckets []HistogramBucket `json:"buckets"`
+	}
+
+	// HistogramBucket counts the events from Time until the next bucket.
+	HistogramBucket struct {
+		Time  time.Time `json:"time"`
+		Count int       `json:"count"`
+	}
+)
+
+// EventHistogram counts m's events selected by query in buckets of the given
+// width, aligned to the Unix epoch, with one series per group. The buckets
+// span query.Since, or the oldest event, to query.Until, or now.
+func EventHistogram(ctx context.Context, m Monitor, query EventQuery, bucket time.Duration, groupBy GroupBy) ([]HistogramSeries, error) {
+	if bucket < time.Second {
+		return nil, fmt.Errorf("%w: bucket must be at least 1s, got %s", ErrInvalidHistogram, bucket)
+	}
+	switch groupBy {
+	case GroupByNone, GroupByAction, GroupByCategory, GroupByDirectory:
+	default:
+		return nil, fmt.Errorf("%w: unknown group_by %q", ErrInvalidHistogram, groupBy)
+	}
+
+	end := query.Until
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := query.Since
+	if !start.IsZero() && end.Sub(start)/bucket >= MaxHistogramBuckets {
+		return nil, fmt.Errorf("%w: more than %d buckets", ErrInvalidHistogram, MaxHistogramBuckets)
+	}
+
+	events, err := MatchingEvents(ctx, m, query)
+	if err != nil {
+		return nil, err
+	}
+	if start.IsZero() {
+		start = end
+		for _, event := range events {
+			if event.Time.Before(start) {
+				start = event.Time
+			}
+		}
+	}
+	start, end = start.Truncate(bucket), end.Truncate(bucket)
+	n := int(end.Sub(start)/bucket) + 1
+	if n > MaxHistogramBuckets {
+		return nil, fmt.Errorf("%w: more than %d buckets", ErrInvalidHistogram, MaxHistogramBuckets)
+	}
+
+	counts := make(map[string][]int)
+	if groupBy == GroupByNone {
+		counts[groupOf(FileEvent{}, groupBy)] = make([]int, n)
+	}
+	for _, event := range events {
+		i := int(event.Time.Sub(start) / bucket)
+		if i < 0 || i >= n {
+			continue
+		}
+		group := groupOf(event, groupBy)
+		if counts[group] == nil {
+			counts[group] = make([]int, n)
+		}
+		counts[group][i]++
+	}
+
+	series := make([]HistogramSeries, 0, len(counts))
+	for group, buckets := range counts {
+		s := HistogramSeries{Group: group, Buckets: make([]HistogramBucket, n)}
+		for i, count := range buckets {
+			s.Buckets[i] = HistogramBucket{Time: start.Add(time.Duration(i) * bucket).UTC(), Count: count}
+		}
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Group < series[j].Group
+	})
+	return series, nil
+}
+
+func groupOf(event FileEvent, groupBy GroupBy) string {
+	switch groupBy {
+	case GroupByAction:
+		return string(event.Action)
+	case GroupByCategory:
+		return event.Category
+	case GroupByDirectory:
+		return filepath.Dir(event.TargetPath)
+	default:
+		return "all"
+	}
+}
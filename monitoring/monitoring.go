@@ -9,8 +9,8 @@ type (
 	Monitor interface {
 		Start(ctx context.Context) error
 		Close() error
-		GetFileEvents() ([]map[string]interface{}, error)
-		GetFileEventsByPath(path string, since time.Time) ([]map[string]interface{}, error)
-		GetFileChangesSummary(since time.Time) ([]map[string]interface{}, error)
+		GetFileEvents(ctx context.Context) ([]FileEvent, error)
+		GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error)
+		GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error)
 	}
 )
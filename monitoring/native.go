@@ -0,0 +1,299 @@
+//go:build linux
+
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+type (
+	// NativeMonitor watches the monitored directories with inotify directly, so
+	// it works on hosts where osquery is not installed.
+	NativeMonitor struct {
+		watches       []Watch
+		roots         []watchRoot
+		watcher       *fsnotify.Watcher
+		events        *eventBuffer
+		hasher        *hasher
+		log           *logger.Logger
+		hashFiles     bool
+		retryInterval time.Duration
+
+		mutex   sync.Mutex
+		watched map[string]bool
+		pending map[string]bool
+		cancel  context.CancelFunc
+		done    chan struct{}
+	}
+)
+
+var (
+	_ Monitor     = (*NativeMonitor)(nil)
+	_ EventSource = (*NativeMonitor)(nil)
+)
+
+func NewNative(watches []Watch, hashFiles bool, log *logger.Logger) (*NativeMonitor, error) {
+	if log == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+	return &NativeMonitor{
+		watches:       watches,
+		events:        newEventBuffer(defaultBufferSize),
+		log:           log,
+		hashFiles:     hashFiles,
+		retryInterval: time.Minute,
+		watched:       make(map[string]bool),
+		pending:       make(map[string]bool),
+	}, nil
+}
+
+func (n *NativeMonitor) Start(ctx context.Context) error {
+	n.log.Info("Started native file tracking...")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		n.log.Error("Failed to create inotify watcher", "error", err)
+		return fmt.Errorf("failed to create inotify watcher: %w", err)
+	}
+	n.watcher = watcher
+	n.hasher = newHasher(n.events.add)
+
+	n.roots = resolveWatchRoots(n.watches)
+	if len(n.roots) == 0 {
+		n.log.Warn("None of the monitored directories exist", "watches", n.watches)
+	}
+	for _, root := range n.roots {
+		n.addWatches(root.path, root.recursive, false)
+	}
+
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.done = make(chan struct{})
+	go n.run(ctx)
+	return nil
+}
+
+func (n *NativeMonitor) run(ctx context.Context) {
+	defer close(n.done)
+
+	retry := time.NewTicker(n.retryInterval)
+	defer retry.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-n.watcher.Events:
+			if !ok {
+				return
+			}
+			n.handleEvent(ev)
+		case err, ok := <-n.watcher.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				n.log.Warn("inotify queue overflowed, some file events were lost")
+				continue
+			}
+			n.log.Error("inotify watcher error", "error", err)
+		case <-retry.C:
+			n.retryPending()
+		}
+	}
+}
+
+func (n *NativeMonitor) handleEvent(ev fsnotify.Event) {
+	var action Action
+	switch {
+	case ev.Has(fsnotify.Create):
+		action = ActionCreated
+	case ev.Has(fsnotify.Write):
+		action = ActionUpdated
+	case ev.Has(fsnotify.Remove):
+		action = ActionDeleted
+	case ev.Has(fsnotify.Rename):
+		action = ActionMovedFrom
+	case ev.Has(fsnotify.Chmod):
+		action = ActionAttributesModified
+	default:
+		return
+	}
+
+	if action == ActionDeleted || action == ActionMovedFrom {
+		n.forget(ev.Name)
+	}
+
+	n.record(ev.Name, action, action != ActionDeleted)
+
+	if action == ActionCreated && n.isRecursive(ev.Name) {
+		if info, err := os.Lstat(ev.Name); err == nil && info.IsDir() {
+			// Files may have been written into the new directory before its
+			// watch was in place, so report whatever is already there.
+			n.addWatches(ev.Name, true, true)
+		}
+	}
+}
+
+// record adds an event for path. Files are hashed by the hasher, so reading
+// them never holds up the inotify event loop.
+func (n *NativeMonitor) record(path string, action Action, hash bool) {
+	root := rootOf(n.roots, path)
+	event, hashable := statFileEvent(path, root, action)
+	n.hasher.add(event, hash && hashable && root.hashes(n.hashFiles))
+}
+
+// addWatches adds a watch for dir and, when recursive, every directory below
+// it. With report set, entries found during the walk are emitted as CREATED.
+func (n *NativeMonitor) addWatches(dir string, recursive, report bool) {
+	if !recursive {
+		n.addWatch(dir)
+		return
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			n.log.Warn("Failed to walk monitored directory", "path", path, "error", err)
+			return nil
+		}
+		if report && path != dir {
+			n.record(path, ActionCreated, true)
+		}
+		if d.IsDir() {
+			if !n.addWatch(path) {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		n.log.Error("Failed to add watches", "path", dir, "error", err)
+	}
+}
+
+// addWatch returns false when the directory could not be watched. Directories
+// that fail because the inotify watch limit is exhausted are retried later.
+func (n *NativeMonitor) addWatch(path string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.watched[path] {
+		return true
+	}
+
+	if err := n.watcher.Add(path); err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			if len(n.pending) == 0 {
+				n.log.Warn("inotify watch limit reached, raise fs.inotify.max_user_watches to monitor every directory", "path", path)
+			}
+			n.pending[path] = true
+			return false
+		}
+		n.log.Warn("Failed to watch path", "path", path, "error", err)
+		return false
+	}
+
+	n.watched[path] = true
+	delete(n.pending, path)
+	return true
+}
+
+func (n *NativeMonitor) retryPending() {
+	n.mutex.Lock()
+	pending := make([]string, 0, len(n.pending))
+	for path := range n.pending {
+		pending = append(pending, path)
+	}
+	n.mutex.Unlock()
+
+	for _, path := range pending {
+		if _, err := os.Stat(path); err != nil {
+			n.mutex.Lock()
+			delete(n.pending, path)
+			n.mutex.Unlock()
+			continue
+		}
+		n.addWatches(path, n.isRecursive(path), false)
+	}
+}
+
+// forget drops bookkeeping for a removed path and everything below it. The
+// kernel removes the watches themselves.
+func (n *NativeMonitor) forget(path string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	prefix := path + string(filepath.Separator)
+	for watched := range n.watched {
+		if watched == path || strings.HasPrefix(watched, prefix) {
+			delete(n.watched, watched)
+		}
+	}
+	for pending := range n.pending {
+		if pending == path || strings.HasPrefix(pending, prefix) {
+			delete(n.pending, pending)
+		}
+	}
+}
+
+func (n *NativeMonitor) isRecursive(path string) bool {
+	for _, root := range n.roots {
+		if root.recursive && (path == root.path || strings.HasPrefix(path, root.path+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *NativeMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return n.events.all(), nil
+}
+
+func (n *NativeMonitor) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	events, next := n.events.since(cursor)
+	return events, next, nil
+}
+
+func (n *NativeMonitor) Head(ctx context.Context) (Cursor, error) {
+	return n.events.head(), nil
+}
+
+func (n *NativeMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	return n.events.byPath(path, since), nil
+}
+
+func (n *NativeMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	return n.events.summary(since), nil
+}
+
+func (n *NativeMonitor) Close() error {
+	n.log.Info("Closing native monitor")
+	if n.cancel != nil {
+		n.cancel()
+		<-n.done
+	}
+	if n.hasher != nil {
+		n.hasher.close()
+		n.hasher = nil
+	}
+	if n.watcher != nil {
+		if err := n.watcher.Close(); err != nil {
+			n.log.Error("Failed to close inotify watcher", "error", err)
+			return fmt.Errorf("failed to close inotify watcher: %w", err)
+		}
+	}
+	n.log.Info("Native monitor closed successfully")
+	return nil
+}
@@ -0,0 +1,50 @@
+//go:build !linux
+
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+// NativeMonitor is only implemented on Linux, where it is backed by inotify.
+type NativeMonitor struct{}
+
+var (
+	_ Monitor     = (*NativeMonitor)(nil)
+	_ EventSource = (*NativeMonitor)(nil)
+)
+
+var errNativeUnsupported = fmt.Errorf("native monitor is not supported on %s", runtime.GOOS)
+
+func NewNative(watches []Watch, hashFiles bool, log *logger.Logger) (*NativeMonitor, error) {
+	return nil, errNativeUnsupported
+}
+
+func (n *NativeMonitor) Start(ctx context.Context) error { return errNativeUnsupported }
+
+func (n *NativeMonitor) Close() error { return nil }
+
+func (n *NativeMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return nil, errNativeUnsupported
+}
+
+func (n *NativeMonitor) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	return nil, cursor, errNativeUnsupported
+}
+
+func (n *NativeMonitor) Head(ctx context.Context) (Cursor, error) {
+	return Cursor{}, errNativeUnsupported
+}
+
+func (n *NativeMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	return nil, errNativeUnsupported
+}
+
+func (n *NativeMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	return nil, errNativeUnsupported
+}
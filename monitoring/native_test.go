@@ -0,0 +1,77 @@
+//go:build linux
+
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func waitForEvent(t *testing.T, m Monitor, path string, action Action) FileEvent {
+	t.Helper()
+	var found FileEvent
+	require.Eventually(t, func() bool {
+		events, err := m.GetFileEvents(context.Background())
+		require.NoError(t, err)
+		for _, event := range events {
+			if event.TargetPath == path && event.Action == action {
+				found = event
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 20*time.Millisecond, "no %s event for %s", action, path)
+	return found
+}
+
+func TestNativeMonitor(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	monitor, err := NewNative(DirWatches([]string{dir + "/%%"}), true, mockLogger)
+	require.NoError(t, err)
+	require.NoError(t, monitor.Start(context.Background()))
+	defer monitor.Close()
+
+	file := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+	event := waitForEvent(t, monitor, file, ActionCreated)
+	assert.Equal(t, defaultCategory, event.Category)
+	assert.Equal(t, defaultSeverity, event.Severity)
+	assert.NotEmpty(t, event.ID)
+	assert.False(t, event.Time.IsZero())
+	require.NotNil(t, event.Size)
+	assert.Equal(t, int64(5), *event.Size)
+	assert.Equal(t, "0644", event.Mode)
+	assert.NotNil(t, event.Hashes)
+
+	// New subdirectories are picked up, including files written before the
+	// watch was added.
+	nested := filepath.Join(dir, "a", "b")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	nestedFile := filepath.Join(nested, "nested.txt")
+	require.NoError(t, os.WriteFile(nestedFile, []byte("nested"), 0644))
+	waitForEvent(t, monitor, nestedFile, ActionCreated)
+
+	require.NoError(t, os.Remove(file))
+	waitForEvent(t, monitor, file, ActionDeleted)
+
+	summary, err := monitor.GetFileChangesSummary(context.Background(), time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, summary)
+
+	byPath, err := monitor.GetFileEventsByPath(context.Background(), nested, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	for _, event := range byPath {
+		assert.Contains(t, event.TargetPath, nested)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,7 +14,13 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/metrics"
+	"github.com/tejiriaustin/savannah-assessment/tracing"
 )
 
 type (
@@ -29,18 +36,50 @@ type (
 		mutex         sync.Mutex
 		log           *logger.Logger
 		maxRetries    int
-	}
-
-	Config struct {
-		Options   map[string]interface{} `json:"options,omitempty"`
-		Schedule  map[string]interface{} `json:"schedule"`
-		FilePaths map[string][]string    `json:"file_paths"`
+		queryTimeout  time.Duration
+		session       *querySession
+		recycled      *querySession
+
+		// supervisor owns the osqueryi or osqueryd process and replaces it
+		// whenever it exits.
+		supervisor     *supervisor
+		restartPolicy  RestartPolicy
+		startupTimeout time.Duration
+
+		// The remaining osquery configuration written by createConfig and
+		// UpdateOrCreateJSONFile, next to monitorDirs in defaultCategory.
+		filePaths        map[string][]string
+		excludePaths     map[string][]string
+		fileAccesses     []string
+		scheduleInterval time.Duration
+		eventsExpiry     time.Duration
+		eventsMax        int
+		severities       map[string]string
+
+		// socketPath switches the client from driving its own osqueryi to
+		// querying an already running osqueryd over its extension socket.
+		socketPath    string
+		socketTimeout time.Duration
+		dialSocket    func(path string, timeout time.Duration) (extensionClient, error)
+		extClient     extensionClient
+
+		// managed runs osqueryd with the generated config and follows its
+		// results log instead of polling file_events through osqueryi.
+		managed      bool
+		daemonBinary string
+		loggerPath   string
+		tailInterval time.Duration
+		events       *eventBuffer
+		stopTail     context.CancelFunc
 	}
 
 	Options func(*OsQueryFIMClient) error
 )
 
-var _ Monitor = (*OsQueryFIMClient)(nil)
+var (
+	_ Monitor     = (*OsQueryFIMClient)(nil)
+	_ EventSource = (*OsQueryFIMClient)(nil)
+)
 
 func WithMonitorDirs(dirs []string) Options {
 	return func(o *OsQueryFIMClient) error {
@@ -77,12 +116,149 @@ func WithMaxRetries(maxRetries int) Options {
 	}
 }
 
+// WithExtensionSocket makes the client query the osqueryd listening on path
+// instead of starting osqueryi, reusing the host's event buffering settings.
+func WithExtensionSocket(path string) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.socketPath = path
+		return nil
+	}
+}
+
+// WithManagedDaemon makes the client launch osqueryd with the generated config
+// and a filesystem logger writing to loggerPath, and collect file events from
+// the scheduled query results it logs there.
+func WithManagedDaemon(loggerPath string) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.managed = true
+		o.loggerPath = loggerPath
+		return nil
+	}
+}
+
+func WithOsquerydBinary(path string) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.daemonBinary = path
+		return nil
+	}
+}
+
+// WithQueryTimeout bounds how long a query may take when the caller's context
+// has no deadline of its own.
+func WithQueryTimeout(timeout time.Duration) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.queryTimeout = timeout
+		return nil
+	}
+}
+
+func WithSocketTimeout(timeout time.Duration) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.socketTimeout = timeout
+		return nil
+	}
+}
+
+// WithFilePaths adds a file_paths category. osquery reports events for the
+// matching paths with category set to its name.
+func WithFilePaths(category string, patterns []string) Options {
+	return func(o *OsQueryFIMClient) error {
+		if category == "" {
+			return fmt.Errorf("file_paths category must not be empty")
+		}
+		if o.filePaths == nil {
+			o.filePaths = make(map[string][]string)
+		}
+		o.filePaths[category] = append(o.filePaths[category], patterns...)
+		return nil
+	}
+}
+
+// WithWatches adds each watch's pattern to the file_paths category named by
+// its label. osquery has no notion of severity, so it is added to the events
+// the client returns by category.
+func WithWatches(watches []Watch) Options {
+	return func(o *OsQueryFIMClient) error {
+		for _, watch := range watches {
+			label := watch.label()
+			if severity, ok := o.severities[label]; ok && severity != watch.severity() {
+				return fmt.Errorf("watches labelled %q have different severities %q and %q", label, severity, watch.severity())
+			}
+			if o.severities == nil {
+				o.severities = make(map[string]string)
+			}
+			o.severities[label] = watch.severity()
+
+			if err := WithFilePaths(label, []string{watch.pattern()})(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithExcludePaths excludes patterns from a file_paths category.
+func WithExcludePaths(category string, patterns []string) Options {
+	return func(o *OsQueryFIMClient) error {
+		if o.excludePaths == nil {
+			o.excludePaths = make(map[string][]string)
+		}
+		o.excludePaths[category] = append(o.excludePaths[category], patterns...)
+		return nil
+	}
+}
+
+// WithFileAccesses makes osquery also report reads of files in the given
+// categories, not only changes.
+func WithFileAccesses(categories []string) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.fileAccesses = append(o.fileAccesses, categories...)
+		return nil
+	}
+}
+
+// WithScheduleInterval sets how often osqueryd runs the file_events queries.
+func WithScheduleInterval(interval time.Duration) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.scheduleInterval = interval
+		return nil
+	}
+}
+
+// WithEventOptions sets how long osquery keeps buffered events and how many
+// it keeps per table. Zero values leave osquery's defaults.
+func WithEventOptions(expiry time.Duration, max int) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.eventsExpiry = expiry
+		o.eventsMax = max
+		return nil
+	}
+}
+
+// WithRestartPolicy sets how the osquery subprocess is restarted after it
+// exits and how many crashes are tolerated before giving up.
+func WithRestartPolicy(policy RestartPolicy) Options {
+	return func(o *OsQueryFIMClient) error {
+		o.restartPolicy = policy
+		return nil
+	}
+}
+
 func New(configPath string, opts ...Options) (*OsQueryFIMClient, error) {
 	client := &OsQueryFIMClient{
-		configPath:    configPath,
-		osqueryBinary: "osqueryi",
-		databasePath:  "/var/tmp/osquery_data/osquery.db",
-		maxRetries:    3,
+		configPath:       configPath,
+		osqueryBinary:    "osqueryi",
+		databasePath:     "/var/tmp/osquery_data/osquery.db",
+		maxRetries:       3,
+		queryTimeout:     30 * time.Second,
+		socketTimeout:    10 * time.Second,
+		dialSocket:       dialExtensionSocket,
+		daemonBinary:     "osqueryd",
+		tailInterval:     time.Second,
+		events:           newEventBuffer(defaultBufferSize),
+		restartPolicy:    DefaultRestartPolicy(),
+		startupTimeout:   5 * time.Second,
+		scheduleInterval: 5 * time.Minute,
 	}
 	for _, opt := range opts {
 		if err := opt(client); err != nil {
@@ -92,29 +268,76 @@ func New(configPath string, opts ...Options) (*OsQueryFIMClient, error) {
 	if client.log == nil {
 		return nil, fmt.Errorf("logger is required")
 	}
+	if client.managed && client.socketPath != "" {
+		return nil, fmt.Errorf("managed osqueryd and extension socket modes are mutually exclusive")
+	}
+	if client.managed && client.loggerPath == "" {
+		return nil, fmt.Errorf("logger path is required for managed osqueryd mode")
+	}
+	if client.restartPolicy.Multiplier < 1 {
+		return nil, fmt.Errorf("restart backoff multiplier must be at least 1")
+	}
+	if client.scheduleInterval < time.Second {
+		return nil, fmt.Errorf("schedule interval must be at least one second")
+	}
+
+	// osquery ignores excludes and accesses for categories it does not watch.
+	filePaths := client.osqueryConfig().FilePaths
+	for _, category := range sortedCategories(client.excludePaths) {
+		if _, ok := filePaths[category]; !ok {
+			return nil, fmt.Errorf("exclude_paths category %q is not a file_paths category", category)
+		}
+	}
+	for _, category := range client.fileAccesses {
+		if _, ok := filePaths[category]; !ok {
+			return nil, fmt.Errorf("file_accesses category %q is not a file_paths category", category)
+		}
+	}
 	return client, nil
 }
 
-func (c *OsQueryFIMClient) createConfig() error {
-	config := map[string]interface{}{
-		"schedule": map[string]interface{}{
-			"file_events": map[string]interface{}{
-				"query":    "SELECT * FROM file_events;",
-				"interval": 300,
-			},
-		},
-		"file_paths": map[string][]string{
-			"homes": c.monitorDirs,
-		},
-		"etc": []string{
-			"/etc/%%",
-		},
-		"tmp": []string{
-			"/tmp/%%",
+// osqueryConfig builds the osquery configuration for the client's settings:
+// one file_paths category per configured set of paths, each with its own
+// scheduled file_events query.
+func (c *OsQueryFIMClient) osqueryConfig() OsqueryConfig {
+	filePaths := make(map[string][]string, len(c.filePaths)+1)
+	if len(c.monitorDirs) > 0 {
+		filePaths[defaultCategory] = appendMissing(nil, c.monitorDirs...)
+	}
+	for category, patterns := range c.filePaths {
+		filePaths[category] = appendMissing(filePaths[category], patterns...)
+	}
+
+	removed := false
+	schedule := make(map[string]ScheduledQuery, len(filePaths))
+	for category := range filePaths {
+		schedule[scheduledQueryPrefix+category] = ScheduledQuery{
+			Query:    fileEventsQuery(category),
+			Interval: int(c.scheduleInterval / time.Second),
+			Removed:  &removed,
+		}
+	}
+
+	return OsqueryConfig{
+		Options: OsqueryOptions{
+			DisableEvents:    false,
+			EnableFileEvents: true,
+			EventsExpiry:     int(c.eventsExpiry / time.Second),
+			EventsMax:        c.eventsMax,
 		},
+		Schedule:     schedule,
+		FilePaths:    filePaths,
+		ExcludePaths: c.excludePaths,
+		FileAccesses: c.fileAccesses,
 	}
+}
+
+func (c *OsQueryFIMClient) scheduledQueries() map[string]ScheduledQuery {
+	return c.osqueryConfig().Schedule
+}
 
-	jsonConfig, err := json.MarshalIndent(config, "", "  ")
+func (c *OsQueryFIMClient) createConfig() error {
+	jsonConfig, err := json.MarshalIndent(c.osqueryConfig(), "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -124,12 +347,40 @@ func (c *OsQueryFIMClient) createConfig() error {
 func (c *OsQueryFIMClient) Start(ctx context.Context) error {
 	c.log.Info("Started file tracking...")
 
+	if c.socketPath != "" {
+		return c.connectSocket(ctx)
+	}
+
+	if c.managed {
+		tailCtx, cancel := context.WithCancel(ctx)
+		if err := c.startManaged(tailCtx); err != nil {
+			cancel()
+			return err
+		}
+		c.stopTail = cancel
+		return nil
+	}
+
 	if err := os.MkdirAll(filepath.Dir(c.databasePath), 0755); err != nil {
 		c.log.Error("Failed to create database directory", "error", err)
 		return fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	c.cmd = exec.Command(c.osqueryBinary,
+	sup := newSupervisor("osqueryi", c.restartPolicy, c.spawnOsqueryi, c.log)
+	if err := sup.start(ctx); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.supervisor = sup
+	c.mutex.Unlock()
+	return nil
+}
+
+// spawnOsqueryi starts osqueryi and, once it is up, hands its pipes to a new
+// query session. The supervisor calls it for the first start and every
+// restart.
+func (c *OsQueryFIMClient) spawnOsqueryi(ctx context.Context) (process, error) {
+	cmd := exec.Command(c.osqueryBinary,
 		"--config_path="+c.configPath,
 		"--database_path="+c.databasePath,
 		"--disable_events=false",
@@ -137,179 +388,299 @@ func (c *OsQueryFIMClient) Start(ctx context.Context) error {
 		"--force",
 		"--json")
 
-	var err error
-	c.stdin, err = c.cmd.StdinPipe()
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		c.log.Error("Failed to create stdin pipe", "error", err)
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
+		return process{}, fmt.Errorf("failed to create stdin pipe: %w", err)
 	}
 
-	c.stdout, err = c.cmd.StdoutPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		c.log.Error("Failed to create stdout pipe", "error", err)
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return process{}, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	c.stderr, err = c.cmd.StderrPipe()
+	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		c.log.Error("Failed to create stderr pipe", "error", err)
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return process{}, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
-	if err := c.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		c.log.Error("Failed to start osqueryi", "error", err)
-		return fmt.Errorf("failed to start osqueryi: %w", err)
+		return process{}, fmt.Errorf("failed to start osqueryi: %w", err)
 	}
 
-	readyChan := make(chan struct{})
-	go c.waitForStartup(ctx, readyChan)
+	ready := make(chan bool, 1)
+	readers := new(sync.WaitGroup)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		c.readStderr("osqueryi", cmd, stderr, ready)
+	}()
 
 	select {
-	case <-readyChan:
+	case started := <-ready:
+		if !started {
+			readers.Wait()
+			_ = cmd.Wait()
+			c.log.Error("Osquery exited during startup", "exit_code", exitCode(cmd, nil))
+			return process{}, fmt.Errorf("osqueryi exited during startup with code %d", exitCode(cmd, nil))
+		}
 		c.log.Info("Osquery started successfully")
+	case <-time.After(c.startupTimeout):
+		c.log.Info("Osquery did not report startup, assuming it is ready", "timeout", c.startupTimeout)
 	case <-ctx.Done():
-		if err := c.cmd.Process.Kill(); err != nil {
+		if err := cmd.Process.Kill(); err != nil {
 			c.log.Error("Failed to kill osquery process on context cancellation", "error", err)
 		}
-		return ctx.Err()
+		readers.Wait()
+		_ = cmd.Wait()
+		return process{}, ctx.Err()
 	}
 
-	go c.handleStderr(ctx)
-
-	if c.cmd.ProcessState != nil && c.cmd.ProcessState.Exited() {
-		c.log.Error("Osquery exited unexpectedly")
-		return fmt.Errorf("osquery exited unexpectedly")
-	}
+	session := newQuerySession(stdin, stdout)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		<-session.done
+	}()
 
-	return nil
+	c.mutex.Lock()
+	c.cmd, c.stdin, c.stdout, c.stderr = cmd, stdin, stdout, stderr
+	c.session = session
+	c.mutex.Unlock()
+	return process{cmd: cmd, readers: readers}, nil
 }
 
-func (c *OsQueryFIMClient) waitForStartup(ctx context.Context, readyChan chan struct{}) {
-	scanner := bufio.NewScanner(c.stderr)
+// readStderr is the only reader of an osquery process's stderr. It reports
+// on ready, when given, whether the startup banner appeared before the pipe
+// closed. A held database lock never clears by itself, so the process is
+// killed and left to the supervisor to restart with backoff.
+func (c *OsQueryFIMClient) readStderr(name string, cmd *exec.Cmd, stderr io.Reader, ready chan<- bool) {
+	started := false
+	scanner := bufio.NewScanner(stderr)
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if strings.Contains(line, "Osquery started successfully") {
-			close(readyChan)
-			return
+		if !started && strings.Contains(line, "Osquery started successfully") {
+			started = true
+			if ready != nil {
+				ready <- true
+			}
+			continue
 		}
+		c.log.Warn(name+" stderr output", "message", line)
 
-		if c.cmd.ProcessState != nil && c.cmd.ProcessState.Exited() {
-			break
+		if strings.Contains(line, "IO error: While lock file") {
+			c.log.Warn("Detected lock file error, killing " + name + " so it is restarted")
+			if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				c.log.Error("Failed to kill "+name, "error", err)
+			}
 		}
 	}
 
-	close(readyChan)
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		c.log.Error("Error reading from stderr", "error", err)
+	}
+	if !started && ready != nil {
+		ready <- false
+	}
 }
 
-func (c *OsQueryFIMClient) handleStderr(ctx context.Context) {
-	scanner := bufio.NewScanner(c.stderr)
-	retries := 0
-	backoffSchedule := []time.Duration{
-		1 * time.Second,
-		3 * time.Second,
-		5 * time.Second,
+// Query runs query and waits for its result until ctx is done or the
+// client's query timeout passes, whichever comes first. A query that times
+// out leaves osqueryi in an unknown state, so the session is marked unhealthy
+// and the subprocess is recycled in the background. Query latencies are
+// recorded in metrics and traced.
+func (c *OsQueryFIMClient) Query(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	transport := "osqueryi"
+	if c.socketPath != "" {
+		transport = "socket"
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "OsQueryFIMClient.Query", trace.WithAttributes(
+		attribute.String("db.system", "osquery"),
+		attribute.String("db.statement", query),
+		attribute.String("osquery.transport", transport),
+	))
+	defer span.End()
+
+	start := time.Now()
+	rows, err := c.query(ctx, query)
+	metrics.OsqueryQuery(transport, time.Since(start), err)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "query failed")
+	} else {
+		span.SetAttributes(attribute.Int("osquery.rows", len(rows)))
 	}
+	return rows, err
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		c.log.Warn("osqueryi stderr output", "message", line)
-
-		// Detect specific lock file error
-		if strings.Contains(line, "IO error: While lock file") {
-			c.log.Warn("Detected lock file error. Attempting to clear lock and restart...")
-
-			// Retry logic for restarting osquery
-			for retries < c.maxRetries {
-				if err := c.Restart(ctx); err != nil {
-					retries++
-					c.log.Warn("Failed to restart osquery", "error", err, "retry", retries)
-
-					// Determine appropriate backoff duration
-					backoff := backoffSchedule[min(retries-1, len(backoffSchedule)-1)]
-					c.log.Debug("Waiting before next retry", "backoff", backoff)
-
-					select {
-					case <-time.After(backoff):
-						// Continue retrying after backoff
-						continue
-					case <-ctx.Done():
-						c.log.Error("Context cancelled, stopping retry attempts", "retries", retries)
-						return
-					}
-				} else {
-					// Successfully restarted, reset retries and break the loop
-					c.log.Info("Successfully restarted osquery after detecting lock file error", "retries", retries)
-					retries = 0
-					break
-				}
-			}
-
-			// If maximum retries reached, log error and exit
-			if retries == c.maxRetries {
-				c.log.Error("Failed to restart osquery after maximum retries", "maxRetries", c.maxRetries)
-				return
-			}
-		}
+func (c *OsQueryFIMClient) query(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && c.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.queryTimeout)
+		defer cancel()
 	}
 
-	// Handle error from the scanner itself
-	if err := scanner.Err(); err != nil {
-		c.log.Error("Error reading from stderr", "error", err)
+	if c.socketPath != "" {
+		c.mutex.Lock()
+		results, err := c.querySocket(ctx, query)
+		c.mutex.Unlock()
+		if err != nil {
+			c.log.Error("Failed to execute query", "query", query, "error", err)
+			return nil, err
+		}
+		c.log.Info("Query executed successfully", "query", query, "results_count", len(results))
+		return results, nil
 	}
-}
 
-func (c *OsQueryFIMClient) Query(query string) ([]map[string]interface{}, error) {
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	if c.session == nil && c.stdin != nil && c.stdout != nil {
+		c.session = newQuerySession(c.stdin, c.stdout)
+	}
+	session := c.session
+	c.mutex.Unlock()
 
-	if c.stdin == nil {
+	if session == nil {
 		c.log.Error("stdin is nil, osquery may not be properly initialized")
 		return nil, fmt.Errorf("stdin is nil, osquery may not be properly initialized")
 	}
 
-	results, err := sendCommand(c.stdin, c.stdout, query)
+	id, result, err := session.send(query)
 	if err != nil {
 		c.log.Error("Failed to execute query", "query", query, "error", err)
+		c.recycle(session)
 		return nil, err
 	}
-	c.log.Info("Query executed successfully", "query", query, "results_count", len(results))
-	return results, nil
-}
 
-func sendCommand(stdin io.Writer, stdout io.Reader, command string) ([]map[string]interface{}, error) {
-	if _, err := fmt.Fprintln(stdin, command); err != nil {
-		return nil, fmt.Errorf("failed to write command: %w", err)
+	select {
+	case res := <-result:
+		if res.err != nil {
+			c.log.Error("Failed to execute query", "query", query, "error", res.err)
+			c.recycle(session)
+			return nil, res.err
+		}
+		c.log.Info("Query executed successfully", "query", query, "results_count", len(res.rows))
+		return res.rows, nil
+	case <-ctx.Done():
+		session.abandon(id)
+		c.log.Error("Query did not complete", "query", query, "error", ctx.Err())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			session.close(fmt.Errorf("osquery session unhealthy: query timed out"))
+			c.recycle(session)
+		}
+		return nil, fmt.Errorf("query did not complete: %w", ctx.Err())
 	}
+}
 
-	decoder := json.NewDecoder(stdout)
+// recycle restarts osqueryi once per unhealthy session. Queries issued while
+// the restart is in progress fail fast instead of queueing behind it.
+func (c *OsQueryFIMClient) recycle(session *querySession) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-	var results []map[string]interface{}
-	for {
-		var result []map[string]interface{}
-		if err := decoder.Decode(&result); err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
+	if c.recycled == session || c.session != session || c.cmd == nil {
+		return
+	}
+	c.recycled = session
+
+	go func() {
+		c.log.Warn("Recycling unhealthy osquery session")
+		if err := c.Restart(context.Background()); err != nil {
+			c.log.Error("Failed to recycle osquery session", "error", err)
 		}
+	}()
+}
 
-		results = append(results, result...)
-		break
+func (c *OsQueryFIMClient) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	if c.managed {
+		return c.events.all(), nil
 	}
+	rows, err := c.Query(ctx, selectFileEvents+";")
+	return c.fileEvents(rows), err
+}
 
-	return results, nil
+// EventsSince returns the events after cursor. file_events can only be
+// filtered by whole seconds, so the second the cursor is in is read again and
+// the events in it are told apart by eid.
+func (c *OsQueryFIMClient) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	if c.managed {
+		events, next := c.events.since(cursor)
+		return events, next, nil
+	}
+	var since int64
+	if cursor.Stream == osqueryStream && !cursor.Time.IsZero() {
+		since = cursor.Time.Unix()
+	}
+	rows, err := c.Query(ctx, fmt.Sprintf("%s WHERE time >= %d;", selectFileEvents, since))
+	if err != nil {
+		return nil, cursor, err
+	}
+	events, next := eventsAfter(c.fileEvents(rows), cursor)
+	return events, next, nil
+}
+
+// Head returns a cursor at the start of the current second, as finding the
+// newest eid would read the whole file_events table. Events of the current
+// second may therefore be returned after Head as well.
+func (c *OsQueryFIMClient) Head(ctx context.Context) (Cursor, error) {
+	if c.managed {
+		return c.events.head(), nil
+	}
+	return Cursor{Stream: osqueryStream, Time: time.Now().Truncate(time.Second)}, nil
+}
+
+func (c *OsQueryFIMClient) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	if c.managed {
+		return c.events.byPath(path, since), nil
+	}
+	query := fmt.Sprintf(`%s WHERE target_path LIKE %s ESCAPE '\' AND time > %d;`,
+		selectFileEvents, sqlString(likePrefix(path)), since.Unix())
+	rows, err := c.Query(ctx, query)
+	return c.fileEvents(rows), err
+}
+
+// sqlString quotes s as an SQL string literal. Line breaks are spliced in
+// with char() so that a statement always stays on one line for osqueryi.
+func sqlString(s string) string {
+	quoted := strings.NewReplacer(
+		"'", "''",
+		"\n", "' || char(10) || '",
+		"\r", "' || char(13) || '",
+	).Replace(s)
+	return "'" + quoted + "'"
 }
 
-func (c *OsQueryFIMClient) GetFileEvents() ([]map[string]interface{}, error) {
-	return c.Query("SELECT * FROM file_events;")
+// likePrefix turns s into a LIKE pattern matching every string that starts
+// with s, with wildcards in s escaped by a backslash.
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
 }
 
-func (c *OsQueryFIMClient) GetFileEventsByPath(path string, since time.Time) ([]map[string]interface{}, error) {
-	query := fmt.Sprintf("SELECT * FROM file_events WHERE path LIKE '%s%%' AND time > %d;", path, since.Unix())
-	return c.Query(query)
+// fileEvents normalises file_events rows, giving each event the severity of
+// its category, the label of the watch osquery matched it under.
+func (c *OsQueryFIMClient) fileEvents(rows []map[string]interface{}) []FileEvent {
+	if rows == nil {
+		return nil
+	}
+	events := make([]FileEvent, 0, len(rows))
+	for _, row := range rows {
+		event := eventFromRow(row)
+		event.Severity = defaultSeverity
+		if severity := c.severities[event.Category]; severity != "" {
+			event.Severity = severity
+		}
+		events = append(events, event)
+	}
+	return events
 }
 
-func (c *OsQueryFIMClient) GetFileChangesSummary(since time.Time) ([]map[string]interface{}, error) {
+func (c *OsQueryFIMClient) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	if c.managed {
+		return c.events.summary(since), nil
+	}
 	query := fmt.Sprintf(`
 		SELECT 
 			action, 
@@ -320,11 +691,30 @@ func (c *OsQueryFIMClient) GetFileChangesSummary(since time.Time) ([]map[string]
 		WHERE time > %d 
 		GROUP BY action;
 	`, since.Unix())
-	return c.Query(query)
+	rows, err := c.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]ActionSummary, 0, len(rows))
+	for _, row := range rows {
+		summaries = append(summaries, summaryFromRow(row))
+	}
+	return summaries, nil
 }
 
+// Restart replaces the osquery process. A running supervisor restarts it in
+// place; otherwise, for example after the crash breaker opened, the client is
+// stopped and started again with a fresh supervisor.
 func (c *OsQueryFIMClient) Restart(ctx context.Context) error {
 	c.log.Info("Restarting osquery")
+
+	c.mutex.Lock()
+	sup := c.supervisor
+	c.mutex.Unlock()
+	if sup != nil && sup.Status().State == StateRunning {
+		return sup.restart()
+	}
+
 	if err := c.Stop(); err != nil {
 		c.log.Error("Failed to stop osquery during restart", "error", err)
 		return fmt.Errorf("failed to stop osquery: %w", err)
@@ -335,7 +725,30 @@ func (c *OsQueryFIMClient) Restart(ctx context.Context) error {
 
 func (c *OsQueryFIMClient) Stop() error {
 	c.log.Info("Stopping osquery")
-	if c.cmd != nil && c.cmd.Process != nil {
+	if c.socketPath != "" {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.closeSocket()
+		return nil
+	}
+	if c.stopTail != nil {
+		c.stopTail()
+		c.stopTail = nil
+	}
+	c.mutex.Lock()
+	sup := c.supervisor
+	c.supervisor = nil
+	if c.session != nil {
+		c.session.close(errSessionClosed)
+		c.session = nil
+	}
+	c.mutex.Unlock()
+	if sup != nil {
+		if err := sup.stop(); err != nil {
+			c.log.Error("Failed to kill osquery process", "error", err)
+			return fmt.Errorf("failed to kill osquery process: %w", err)
+		}
+	} else if c.cmd != nil && c.cmd.Process != nil {
 		if err := c.cmd.Process.Kill(); err != nil {
 			c.log.Error("Failed to kill osquery process", "error", err)
 			return fmt.Errorf("failed to kill osquery process: %w", err)
@@ -345,13 +758,31 @@ func (c *OsQueryFIMClient) Stop() error {
 	return nil
 }
 
+// Status reports the state of the supervised osquery process. In socket mode
+// osqueryd is someone else's to supervise, so only the connection is reported.
+func (c *OsQueryFIMClient) Status() SupervisorStatus {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.socketPath != "" {
+		if c.extClient != nil {
+			return SupervisorStatus{State: StateRunning}
+		}
+		return SupervisorStatus{State: StateStopped}
+	}
+	if c.supervisor == nil {
+		return SupervisorStatus{State: StateStopped}
+	}
+	return c.supervisor.Status()
+}
+
 func (c *OsQueryFIMClient) UpdateOrCreateJSONFile(filePath string) error {
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("error creating directory: %v", err)
 	}
 
-	var config Config
+	var config OsqueryConfig
 
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
@@ -371,38 +802,9 @@ func (c *OsQueryFIMClient) UpdateOrCreateJSONFile(filePath string) error {
 		}
 	}
 
-	// Update or add the "schedule" key with "file_events"
-	if config.Schedule == nil {
-		config.Schedule = make(map[string]interface{})
-	}
-	config.Schedule["file_events"] = map[string]interface{}{
-		"query":    "SELECT * FROM file_events;",
-		"interval": 300,
-	}
-
-	// Update or add the "file_paths" key
-	if config.FilePaths == nil {
-		config.FilePaths = make(map[string][]string)
-	}
-
-	// Preserve existing "homes" entries and add new one if not present
-	existingHomes := config.FilePaths["homes"]
-	for _, newEntry := range c.monitorDirs {
-		entryExists := false
-		for _, existingEntry := range existingHomes {
-			if existingEntry == newEntry {
-				entryExists = true
-				break
-			}
-		}
-		if !entryExists {
-			existingHomes = append(existingHomes, newEntry)
-		}
-	}
-	config.FilePaths["homes"] = existingHomes
-
-	config.FilePaths["etc"] = []string{"/etc/%%"}
-	config.FilePaths["tmp"] = []string{"/tmp/%%"}
+	// Keep whatever else the file configures and only bring the tracker's
+	// categories, queries and event options up to date.
+	config.merge(c.osqueryConfig())
 
 	// Seek to the beginning of the file before writing
 	if _, err := file.Seek(0, 0); err != nil {
@@ -431,19 +833,19 @@ func (c *OsQueryFIMClient) Close() error {
 	}
 
 	if c.stdin != nil {
-		if err := c.stdin.Close(); err != nil {
+		if err := c.stdin.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 			c.log.Error("Failed to close stdin", "error", err)
 			return fmt.Errorf("failed to close stdin: %w", err)
 		}
 	}
 	if c.stdout != nil {
-		if err := c.stdout.Close(); err != nil {
+		if err := c.stdout.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 			c.log.Error("Failed to close stdout", "error", err)
 			return fmt.Errorf("failed to close stdout: %w", err)
 		}
 	}
 	if c.stderr != nil {
-		if err := c.stderr.Close(); err != nil {
+		if err := c.stderr.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
 			c.log.Error("Failed to close stderr", "error", err)
 			return fmt.Errorf("failed to close stderr: %w", err)
 		}
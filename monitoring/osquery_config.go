@@ -0,0 +1,281 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scheduledQueryPrefix names the per-category file_events queries the tracker
+// schedules, e.g. "file_events_homes".
+const scheduledQueryPrefix = "file_events_"
+
+// legacyScheduledQuery is the single query older versions scheduled for all
+// categories. It duplicates the per-category queries and is dropped on merge.
+const legacyScheduledQuery = "file_events"
+
+type (
+	// OsqueryConfig models the parts of osquery's JSON configuration the
+	// tracker manages. Top-level keys it does not know about, such as packs or
+	// decorators, are kept in Extra so rewriting an existing config does not
+	// lose them.
+	OsqueryConfig struct {
+		Options      OsqueryOptions             `json:"options"`
+		Schedule     map[string]ScheduledQuery  `json:"schedule,omitempty"`
+		FilePaths    map[string][]string        `json:"file_paths,omitempty"`
+		ExcludePaths map[string][]string        `json:"exclude_paths,omitempty"`
+		FileAccesses []string                   `json:"file_accesses,omitempty"`
+		Extra        map[string]json.RawMessage `json:"-"`
+	}
+
+	ScheduledQuery struct {
+		Query       string                     `json:"query"`
+		Interval    int                        `json:"interval"`
+		Removed     *bool                      `json:"removed,omitempty"`
+		Snapshot    bool                       `json:"snapshot,omitempty"`
+		Platform    string                     `json:"platform,omitempty"`
+		Description string                     `json:"description,omitempty"`
+		Extra       map[string]json.RawMessage `json:"-"`
+	}
+
+	// OsqueryOptions holds the event options file monitoring depends on.
+	// osquery also accepts booleans and numbers written as strings, so they
+	// are decoded leniently. Any other option is carried through Extra.
+	OsqueryOptions struct {
+		DisableEvents    bool
+		EnableFileEvents bool
+		EventsExpiry     int
+		EventsMax        int
+		Extra            map[string]json.RawMessage
+	}
+)
+
+func (c OsqueryConfig) MarshalJSON() ([]byte, error) {
+	type plain OsqueryConfig
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (c *OsqueryConfig) UnmarshalJSON(data []byte) error {
+	type plain OsqueryConfig
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, "options", "schedule", "file_paths", "exclude_paths", "file_accesses")
+	if err != nil {
+		return err
+	}
+	*c = OsqueryConfig(decoded)
+	c.Extra = extra
+	return nil
+}
+
+func (q ScheduledQuery) MarshalJSON() ([]byte, error) {
+	type plain ScheduledQuery
+	return marshalWithExtra(plain(q), q.Extra)
+}
+
+func (q *ScheduledQuery) UnmarshalJSON(data []byte) error {
+	type plain ScheduledQuery
+	var decoded plain
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, "query", "interval", "removed", "snapshot", "platform", "description")
+	if err != nil {
+		return err
+	}
+	*q = ScheduledQuery(decoded)
+	q.Extra = extra
+	return nil
+}
+
+func (o OsqueryOptions) MarshalJSON() ([]byte, error) {
+	options := map[string]interface{}{
+		"disable_events":     o.DisableEvents,
+		"enable_file_events": o.EnableFileEvents,
+	}
+	if o.EventsExpiry > 0 {
+		options["events_expiry"] = o.EventsExpiry
+	}
+	if o.EventsMax > 0 {
+		options["events_max"] = o.EventsMax
+	}
+	return marshalWithExtra(options, o.Extra)
+}
+
+func (o *OsqueryOptions) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var options OsqueryOptions
+	var err error
+	for name, raw := range fields {
+		switch name {
+		case "disable_events":
+			options.DisableEvents, err = optionBool(raw)
+		case "enable_file_events":
+			options.EnableFileEvents, err = optionBool(raw)
+		case "events_expiry":
+			options.EventsExpiry, err = optionInt(raw)
+		case "events_max":
+			options.EventsMax, err = optionInt(raw)
+		default:
+			if options.Extra == nil {
+				options.Extra = make(map[string]json.RawMessage)
+			}
+			options.Extra[name] = raw
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("invalid osquery option %s: %w", name, err)
+		}
+	}
+	*o = options
+	return nil
+}
+
+// merge folds the tracker's own settings into an existing config. Path
+// categories, excludes and accesses are extended, the tracker's scheduled
+// queries and event options replace older ones, and everything else is left
+// as it was.
+func (c *OsqueryConfig) merge(managed OsqueryConfig) {
+	c.Options.DisableEvents = managed.Options.DisableEvents
+	c.Options.EnableFileEvents = managed.Options.EnableFileEvents
+	if managed.Options.EventsExpiry > 0 {
+		c.Options.EventsExpiry = managed.Options.EventsExpiry
+	}
+	if managed.Options.EventsMax > 0 {
+		c.Options.EventsMax = managed.Options.EventsMax
+	}
+
+	if c.Schedule == nil {
+		c.Schedule = make(map[string]ScheduledQuery)
+	}
+	delete(c.Schedule, legacyScheduledQuery)
+	for name := range c.Schedule {
+		if _, ok := managed.Schedule[name]; !ok && strings.HasPrefix(name, scheduledQueryPrefix) {
+			delete(c.Schedule, name)
+		}
+	}
+	for name, query := range managed.Schedule {
+		c.Schedule[name] = query
+	}
+
+	c.FilePaths = mergePathCategories(c.FilePaths, managed.FilePaths)
+	c.ExcludePaths = mergePathCategories(c.ExcludePaths, managed.ExcludePaths)
+	c.FileAccesses = appendMissing(c.FileAccesses, managed.FileAccesses...)
+}
+
+// selectFileEvents reads file_events rows with their eid, a hidden column
+// that "SELECT *" leaves out.
+const selectFileEvents = "SELECT *, eid FROM file_events"
+
+// fileEventsQuery selects the events osquery reports for one file_paths
+// category.
+func fileEventsQuery(category string) string {
+	return fmt.Sprintf("%s WHERE category = '%s';", selectFileEvents, strings.ReplaceAll(category, "'", "''"))
+}
+
+func mergePathCategories(existing, added map[string][]string) map[string][]string {
+	if existing == nil && len(added) > 0 {
+		existing = make(map[string][]string, len(added))
+	}
+	for category, patterns := range added {
+		existing[category] = appendMissing(existing[category], patterns...)
+	}
+	return existing
+}
+
+func appendMissing(values []string, added ...string) []string {
+	for _, value := range added {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func sortedCategories(paths map[string][]string) []string {
+	categories := make([]string, 0, len(paths))
+	for category := range paths {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// marshalWithExtra marshals v as a JSON object and adds the fields of extra
+// that v does not set itself.
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// extraFields returns the fields of the JSON object in data that are not
+// named in known, or nil if there are none.
+func extraFields(data []byte, known ...string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range known {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+func optionBool(raw json.RawMessage) (bool, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, fmt.Errorf("expected a boolean, got %s", raw)
+}
+
+func optionInt(raw json.RawMessage) (int, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("expected a number, got %s", raw)
+}
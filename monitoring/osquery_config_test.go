@@ -0,0 +1,136 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+const existingOsqueryConfig = `{
+  "options": {
+    "disable_events": "true",
+    "enable_file_events": "false",
+    "events_expiry": "3600",
+    "host_identifier": "hostname"
+  },
+  "schedule": {
+    "file_events": {"query": "SELECT * FROM file_events;", "interval": 300},
+    "file_events_old": {"query": "SELECT * FROM file_events WHERE category = 'old';", "interval": 300},
+    "processes": {"query": "SELECT * FROM processes;", "interval": 60, "shard": 10}
+  },
+  "file_paths": {
+    "homes": ["/home/%%"],
+    "etc": ["/etc/%%"]
+  },
+  "exclude_paths": {
+    "homes": ["/home/%/.cache/%%"]
+  },
+  "packs": {
+    "incident-response": "/var/osquery/packs/incident-response.conf"
+  }
+}`
+
+func TestOsqueryConfigRoundTrip(t *testing.T) {
+	removed := false
+	original := OsqueryConfig{
+		Options: OsqueryOptions{
+			EnableFileEvents: true,
+			EventsExpiry:     3600,
+			EventsMax:        50000,
+			Extra:            map[string]json.RawMessage{"host_identifier": json.RawMessage(`"uuid"`)},
+		},
+		Schedule: map[string]ScheduledQuery{
+			"file_events_homes": {Query: fileEventsQuery("homes"), Interval: 60, Removed: &removed},
+			"processes": {
+				Query:    "SELECT * FROM processes;",
+				Interval: 10,
+				Extra:    map[string]json.RawMessage{"shard": json.RawMessage(`10`)},
+			},
+		},
+		FilePaths:    map[string][]string{"homes": {"/home/%%"}, "www": {"/var/www/%%"}},
+		ExcludePaths: map[string][]string{"www": {"/var/www/cache/%%"}},
+		FileAccesses: []string{"www"},
+		Extra:        map[string]json.RawMessage{"decorators": json.RawMessage(`{"load":["SELECT uuid FROM system_info;"]}`)},
+	}
+
+	data, err := json.Marshal(original)
+	require.NoError(t, err)
+
+	var decoded OsqueryConfig
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, original, decoded)
+
+	again, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestOsqueryConfigDecodesStringOptions(t *testing.T) {
+	var config OsqueryConfig
+	require.NoError(t, json.Unmarshal([]byte(existingOsqueryConfig), &config))
+
+	assert.True(t, config.Options.DisableEvents)
+	assert.False(t, config.Options.EnableFileEvents)
+	assert.Equal(t, 3600, config.Options.EventsExpiry)
+	assert.JSONEq(t, `"hostname"`, string(config.Options.Extra["host_identifier"]))
+	assert.JSONEq(t, `10`, string(config.Schedule["processes"].Extra["shard"]))
+	assert.Contains(t, config.Extra, "packs")
+
+	var invalid OsqueryOptions
+	assert.Error(t, json.Unmarshal([]byte(`{"disable_events": "maybe"}`), &invalid))
+}
+
+func TestUpdateOrCreateJSONFile(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "osquery.conf")
+	require.NoError(t, os.WriteFile(path, []byte(existingOsqueryConfig), 0644))
+
+	client, err := New(path,
+		WithLogger(mockLogger),
+		WithMonitorDirs([]string{"/home/%%", "/Users/%%"}),
+		WithFilePaths("www", []string{"/var/www/%%"}),
+		WithExcludePaths("homes", []string{"/home/%/build/%%"}),
+		WithEventOptions(0, 1000),
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.UpdateOrCreateJSONFile(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var config OsqueryConfig
+	require.NoError(t, json.Unmarshal(data, &config))
+
+	assert.False(t, config.Options.DisableEvents)
+	assert.True(t, config.Options.EnableFileEvents)
+	assert.Equal(t, 3600, config.Options.EventsExpiry)
+	assert.Equal(t, 1000, config.Options.EventsMax)
+	assert.Contains(t, config.Options.Extra, "host_identifier")
+
+	// The legacy query and the query for a category the tracker no longer
+	// manages are replaced; unrelated queries are kept.
+	assert.NotContains(t, config.Schedule, "file_events")
+	assert.NotContains(t, config.Schedule, "file_events_old")
+	assert.Contains(t, config.Schedule, "processes")
+	assert.Contains(t, config.Schedule, "file_events_homes")
+	assert.Contains(t, config.Schedule, "file_events_www")
+
+	assert.Equal(t, []string{"/home/%%", "/Users/%%"}, config.FilePaths["homes"])
+	assert.Equal(t, []string{"/etc/%%"}, config.FilePaths["etc"])
+	assert.Equal(t, []string{"/var/www/%%"}, config.FilePaths["www"])
+	assert.Equal(t, []string{"/home/%/.cache/%%", "/home/%/build/%%"}, config.ExcludePaths["homes"])
+	assert.Contains(t, config.Extra, "packs")
+
+	// Running configure again changes nothing.
+	require.NoError(t, client.UpdateOrCreateJSONFile(path))
+	rewritten, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(rewritten))
+}
@@ -0,0 +1,276 @@
+package monitoring
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// resultsLogName is the file osqueryd's filesystem logger writes scheduled
+// query results to inside --logger_path.
+const resultsLogName = "osqueryd.results.log"
+
+type (
+	// resultLine is one line of the results log. osqueryd writes either one
+	// line per row (the default event format) or one line per query run
+	// (--logger_event_type=false) with all rows under diffResults.
+	resultLine struct {
+		Name     string            `json:"name"`
+		Action   string            `json:"action"`
+		Columns  map[string]string `json:"columns"`
+		UnixTime int64             `json:"unixTime"`
+
+		DiffResults *struct {
+			Added   []map[string]string `json:"added"`
+			Removed []map[string]string `json:"removed"`
+		} `json:"diffResults"`
+	}
+
+	// resultsTailer follows the results log across osqueryd's log rotation.
+	resultsTailer struct {
+		path    string
+		file    *os.File
+		reader  *bufio.Reader
+		partial []byte
+	}
+)
+
+// startManaged launches osqueryd with the generated config and streams the
+// differential results of its scheduled file_events query into the client's
+// event buffer. Events reach the tracker as soon as osqueryd logs them instead
+// of depending on file_events still holding them at the next poll.
+func (c *OsQueryFIMClient) startManaged(ctx context.Context) error {
+	if err := c.createConfig(); err != nil {
+		c.log.Error("Failed to write osquery config", "error", err)
+		return fmt.Errorf("failed to write osquery config: %w", err)
+	}
+
+	for _, dir := range []string{filepath.Dir(c.databasePath), c.loggerPath} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			c.log.Error("Failed to create osquery directory", "path", dir, "error", err)
+			return fmt.Errorf("failed to create osquery directory: %w", err)
+		}
+	}
+
+	// Start from the end of an existing log so results from a previous run
+	// are not reported a second time.
+	tailer := newResultsTailer(filepath.Join(c.loggerPath, resultsLogName))
+	if err := tailer.seekEnd(); err != nil {
+		c.log.Error("Failed to open osquery results log", "error", err)
+		return fmt.Errorf("failed to open osquery results log: %w", err)
+	}
+
+	sup := newSupervisor("osqueryd", c.restartPolicy, c.spawnOsqueryd, c.log)
+	if err := sup.start(ctx); err != nil {
+		tailer.close()
+		return err
+	}
+	c.mutex.Lock()
+	c.supervisor = sup
+	c.mutex.Unlock()
+
+	go c.tailResults(ctx, tailer)
+	return nil
+}
+
+// spawnOsqueryd starts one osqueryd process. The results log outlives it, so
+// the tailer keeps following the same file across restarts.
+func (c *OsQueryFIMClient) spawnOsqueryd(ctx context.Context) (process, error) {
+	cmd := exec.Command(c.daemonBinary,
+		"--config_path="+c.configPath,
+		"--database_path="+c.databasePath,
+		"--pidfile="+filepath.Join(c.loggerPath, "osqueryd.pid"),
+		"--logger_plugin=filesystem",
+		"--logger_path="+c.loggerPath,
+		"--disable_extensions=true",
+		"--disable_events=false",
+		"--enable_file_events=true",
+		"--force")
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		c.log.Error("Failed to create stderr pipe", "error", err)
+		return process{}, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		c.log.Error("Failed to start osqueryd", "error", err)
+		return process{}, fmt.Errorf("failed to start osqueryd: %w", err)
+	}
+	c.log.Info("Osqueryd started", "pid", cmd.Process.Pid, "logger_path", c.loggerPath)
+	readers := new(sync.WaitGroup)
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		c.readStderr("osqueryd", cmd, stderr, nil)
+	}()
+
+	c.mutex.Lock()
+	c.cmd, c.stderr = cmd, stderr
+	c.mutex.Unlock()
+	return process{cmd: cmd, readers: readers}, nil
+}
+
+func (c *OsQueryFIMClient) tailResults(ctx context.Context, tailer *resultsTailer) {
+	defer tailer.close()
+
+	ticker := time.NewTicker(c.tailInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lines, err := tailer.poll()
+			if err != nil {
+				c.log.Error("Failed to read osquery results log", "error", err)
+			}
+			for _, line := range lines {
+				c.recordResult(line)
+			}
+		}
+	}
+}
+
+// recordResult keeps the rows osqueryd reports as added. For an evented table
+// like file_events, removed rows only mean osquery expired an old event.
+func (c *OsQueryFIMClient) recordResult(line resultLine) {
+	if _, ok := c.scheduledQueries()[line.Name]; !ok {
+		return
+	}
+
+	var rows []map[string]string
+	switch {
+	case line.DiffResults != nil:
+		rows = line.DiffResults.Added
+	case line.Action == "added":
+		rows = []map[string]string{line.Columns}
+	}
+
+	columns := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		event := make(map[string]interface{}, len(row))
+		for column, value := range row {
+			event[column] = value
+		}
+		columns = append(columns, event)
+	}
+	for _, event := range c.fileEvents(columns) {
+		c.events.add(event)
+	}
+}
+
+func newResultsTailer(path string) *resultsTailer {
+	return &resultsTailer{path: path}
+}
+
+func (t *resultsTailer) seekEnd() error {
+	if err := t.open(); err != nil || t.file == nil {
+		return err
+	}
+	_, err := t.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// open opens the log if it exists. A missing log is not an error, osqueryd
+// only creates it when the first results are written.
+func (t *resultsTailer) open() error {
+	f, err := os.Open(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	t.file = f
+	t.reader = bufio.NewReader(f)
+	t.partial = nil
+	return nil
+}
+
+func (t *resultsTailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// poll returns every complete line written since the last call. When the log
+// was rotated or truncated, the rest of the old file is drained first and the
+// new file is read from the beginning.
+func (t *resultsTailer) poll() ([]resultLine, error) {
+	if t.file == nil {
+		if err := t.open(); err != nil || t.file == nil {
+			return nil, err
+		}
+	}
+
+	lines, err := t.readLines()
+	if err != nil {
+		return lines, err
+	}
+
+	rotated, err := t.rotated()
+	if err != nil || !rotated {
+		return lines, err
+	}
+
+	t.close()
+	if err := t.open(); err != nil || t.file == nil {
+		return lines, err
+	}
+	more, err := t.readLines()
+	return append(lines, more...), err
+}
+
+func (t *resultsTailer) readLines() ([]resultLine, error) {
+	var lines []resultLine
+	for {
+		chunk, err := t.reader.ReadBytes('\n')
+		t.partial = append(t.partial, chunk...)
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+
+		var line resultLine
+		if err := json.Unmarshal(t.partial, &line); err == nil {
+			lines = append(lines, line)
+		}
+		t.partial = nil
+	}
+}
+
+func (t *resultsTailer) rotated() (bool, error) {
+	current, err := os.Stat(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	open, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(open, current) {
+		return true, nil
+	}
+
+	offset, err := t.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	return current.Size() < offset, nil
+}
@@ -0,0 +1,97 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func appendLines(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	for _, line := range lines {
+		_, err := f.WriteString(line)
+		require.NoError(t, err)
+	}
+}
+
+func TestResultsTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), resultsLogName)
+
+	// Results logged before the tracker started are skipped.
+	appendLines(t, path, `{"name":"file_events_homes","action":"added","columns":{"target_path":"/old"}}`+"\n")
+	tailer := newResultsTailer(path)
+	require.NoError(t, tailer.seekEnd())
+	defer tailer.close()
+
+	lines, err := tailer.poll()
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+
+	// A line is only returned once it is complete.
+	appendLines(t, path, `{"name":"file_events_homes","action":"added",`)
+	lines, err = tailer.poll()
+	assert.NoError(t, err)
+	assert.Empty(t, lines)
+
+	appendLines(t, path, `"columns":{"target_path":"/new"}}`+"\n")
+	lines, err = tailer.poll()
+	assert.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "/new", lines[0].Columns["target_path"])
+
+	// After rotation the new file is read from the start.
+	require.NoError(t, os.Rename(path, path+".1"))
+	appendLines(t, path, `{"name":"file_events_homes","action":"added","columns":{"target_path":"/rotated"}}`+"\n")
+	lines, err = tailer.poll()
+	assert.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "/rotated", lines[0].Columns["target_path"])
+}
+
+func TestRecordResult(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json",
+		WithLogger(mockLogger),
+		WithManagedDaemon(t.TempDir()),
+		WithMonitorDirs([]string{"/home/%%"}))
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), resultsLogName)
+	appendLines(t, path,
+		`{"name":"file_events_homes","action":"added","columns":{"target_path":"/a","action":"CREATED","time":"100"}}`+"\n",
+		`{"name":"file_events_homes","action":"removed","columns":{"target_path":"/expired","action":"CREATED","time":"50"}}`+"\n",
+		`{"name":"file_events_homes","diffResults":{"added":[{"target_path":"/b","action":"UPDATED","time":"200"}],"removed":[]}}`+"\n",
+		`{"name":"processes","action":"added","columns":{"pid":"1"}}`+"\n",
+	)
+	tailer := newResultsTailer(path)
+	defer tailer.close()
+
+	lines, err := tailer.poll()
+	require.NoError(t, err)
+	for _, line := range lines {
+		client.recordResult(line)
+	}
+
+	events, err := client.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "/a", events[0].TargetPath)
+	assert.Equal(t, time.Unix(100, 0).UTC(), events[0].Time)
+	assert.Equal(t, "/b", events[1].TargetPath)
+
+	summary, err := client.GetFileChangesSummary(context.Background(), time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Len(t, summary, 2)
+}
@@ -0,0 +1,139 @@
+package monitoring
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sequenceColumn names the marker row written after every query. osqueryi
+// prints nothing for a failed statement and may print nothing for an empty
+// result, so responses are framed by the marker rather than counted.
+const sequenceColumn = "__filemodtracker_seq"
+
+var errSessionClosed = errors.New("osquery session closed")
+
+type (
+	queryResult struct {
+		rows []map[string]interface{}
+		err  error
+	}
+
+	// querySession multiplexes queries over one osqueryi stdin/stdout pair.
+	// A single reader decodes everything osqueryi prints and hands each
+	// response to the request whose sequence marker follows it.
+	querySession struct {
+		stdin   io.Writer
+		mutex   sync.Mutex
+		seq     uint64
+		pending map[string]chan queryResult
+		err     error
+		// done is closed once the reader has stopped reading stdout.
+		done chan struct{}
+	}
+)
+
+func newQuerySession(stdin io.Writer, stdout io.Reader) *querySession {
+	s := &querySession{
+		stdin:   stdin,
+		pending: make(map[string]chan queryResult),
+		done:    make(chan struct{}),
+	}
+	go s.read(stdout)
+	return s
+}
+
+// send writes query followed by its sequence marker and returns the id and
+// the channel its result will be delivered on.
+func (s *querySession) send(query string) (string, <-chan queryResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err != nil {
+		return "", nil, s.err
+	}
+
+	query = strings.TrimSpace(query)
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+
+	s.seq++
+	id := strconv.FormatUint(s.seq, 10)
+	if _, err := fmt.Fprintf(s.stdin, "%s\nSELECT '%s' AS %s;\n", query, id, sequenceColumn); err != nil {
+		return "", nil, fmt.Errorf("failed to write command: %w", err)
+	}
+
+	result := make(chan queryResult, 1)
+	s.pending[id] = result
+	return id, result, nil
+}
+
+// abandon forgets a request whose caller stopped waiting, so a late response
+// is dropped instead of being delivered to nobody.
+func (s *querySession) abandon(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.pending, id)
+}
+
+// close fails every outstanding request and rejects new ones.
+func (s *querySession) close(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+	for id, result := range s.pending {
+		result <- queryResult{err: s.err}
+		delete(s.pending, id)
+	}
+}
+
+func (s *querySession) healthy() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err == nil
+}
+
+func (s *querySession) read(stdout io.Reader) {
+	defer close(s.done)
+	decoder := json.NewDecoder(bufio.NewReader(stdout))
+
+	var rows []map[string]interface{}
+	for {
+		var response []map[string]interface{}
+		if err := decoder.Decode(&response); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errSessionClosed
+			}
+			s.close(fmt.Errorf("failed to read osquery response: %w", err))
+			return
+		}
+
+		if len(response) == 1 {
+			if id, ok := response[0][sequenceColumn].(string); ok {
+				s.deliver(id, rows)
+				rows = nil
+				continue
+			}
+		}
+		rows = append(rows, response...)
+	}
+}
+
+func (s *querySession) deliver(id string, rows []map[string]interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if result, ok := s.pending[id]; ok {
+		result <- queryResult{rows: rows}
+		delete(s.pending, id)
+	}
+}
@@ -0,0 +1,120 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+)
+
+// extensionClient is the part of osquery.ExtensionManagerClient the socket
+// mode uses, so tests can stand in for a running osqueryd.
+type extensionClient interface {
+	QueryContext(ctx context.Context, sql string) (*gen.ExtensionResponse, error)
+	Close()
+}
+
+func dialExtensionSocket(path string, timeout time.Duration) (extensionClient, error) {
+	return osquery.NewClient(path, timeout)
+}
+
+// connectSocket opens the extension socket of an already running osqueryd.
+func (c *OsQueryFIMClient) connectSocket(ctx context.Context) error {
+	client, err := c.openSocket(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closeSocket()
+	c.extClient = client
+	return nil
+}
+
+// openSocket dials the extension socket, retrying with the same backoff
+// schedule used for restarting osqueryi.
+func (c *OsQueryFIMClient) openSocket(ctx context.Context) (extensionClient, error) {
+	backoffSchedule := []time.Duration{
+		1 * time.Second,
+		3 * time.Second,
+		5 * time.Second,
+	}
+
+	var err error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := backoffSchedule[min(attempt-1, len(backoffSchedule)-1)]
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		var client extensionClient
+		client, err = c.dialSocket(c.socketPath, c.socketTimeout)
+		if err == nil {
+			c.log.Info("Connected to osquery extension socket", "socket", c.socketPath)
+			return client, nil
+		}
+		c.log.Warn("Failed to connect to osquery extension socket", "socket", c.socketPath, "error", err, "attempt", attempt+1)
+	}
+	return nil, fmt.Errorf("failed to connect to osquery extension socket %s: %w", c.socketPath, err)
+}
+
+// closeSocket must be called with c.mutex held.
+func (c *OsQueryFIMClient) closeSocket() {
+	if c.extClient != nil {
+		c.extClient.Close()
+		c.extClient = nil
+	}
+}
+
+// querySocket runs query through osqueryd. A transport failure usually means
+// osqueryd restarted and removed its socket, so the connection is re-opened
+// and the query retried once before giving up. It must be called with
+// c.mutex held, which also keeps queries on the socket one at a time.
+func (c *OsQueryFIMClient) querySocket(ctx context.Context, query string) ([]map[string]interface{}, error) {
+	if c.extClient == nil {
+		client, err := c.openSocket(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.extClient = client
+	}
+
+	resp, err := c.extClient.QueryContext(ctx, query)
+	if err != nil {
+		c.log.Warn("Lost connection to osquery extension socket, reconnecting", "error", err)
+		c.closeSocket()
+		client, err := c.openSocket(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.extClient = client
+		if resp, err = c.extClient.QueryContext(ctx, query); err != nil {
+			c.closeSocket()
+			return nil, fmt.Errorf("transport error in query: %w", err)
+		}
+	}
+
+	if resp.Status == nil {
+		return nil, fmt.Errorf("query returned nil status")
+	}
+	if resp.Status.Code != 0 {
+		return nil, fmt.Errorf("query returned error: %s", resp.Status.Message)
+	}
+
+	results := make([]map[string]interface{}, 0, len(resp.Response))
+	for _, row := range resp.Response {
+		result := make(map[string]interface{}, len(row))
+		for column, value := range row {
+			result[column] = value
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -1,16 +1,25 @@
 package monitoring
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	gen "github.com/osquery/osquery-go/gen/osquery"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"github.com/tejiriaustin/savannah-assessment/logger"
 )
@@ -66,7 +75,14 @@ func TestCreateConfig(t *testing.T) {
 	configPath := filepath.Join(os.TempDir(), "test_config.json")
 	defer os.Remove(configPath)
 
-	client, err := New(configPath, WithLogger(mockLogger), WithMonitorDirs([]string{"/home/user"}))
+	client, err := New(configPath,
+		WithLogger(mockLogger),
+		WithMonitorDirs([]string{"/home/user"}),
+		WithFilePaths("www", []string{"/var/www/%%"}),
+		WithExcludePaths("www", []string{"/var/www/node_modules/%%"}),
+		WithFileAccesses([]string{"www"}),
+		WithScheduleInterval(time.Minute),
+	)
 	assert.NoError(t, err)
 
 	err = client.createConfig()
@@ -83,6 +99,85 @@ func TestCreateConfig(t *testing.T) {
 	// Check if the config contains expected keys
 	assert.Contains(t, config, "schedule")
 	assert.Contains(t, config, "file_paths")
+	assert.NotContains(t, config, "etc")
+
+	schedule := config["schedule"].(map[string]interface{})
+	assert.Len(t, schedule, 2)
+	www := schedule["file_events_www"].(map[string]interface{})
+	assert.Equal(t, "SELECT *, eid FROM file_events WHERE category = 'www';", www["query"])
+	assert.Equal(t, float64(60), www["interval"])
+
+	assert.Equal(t, map[string]interface{}{
+		"homes": []interface{}{"/home/user"},
+		"www":   []interface{}{"/var/www/%%"},
+	}, config["file_paths"])
+	assert.Equal(t, map[string]interface{}{"www": []interface{}{"/var/www/node_modules/%%"}}, config["exclude_paths"])
+	assert.Equal(t, []interface{}{"www"}, config["file_accesses"])
+}
+
+func TestNewRejectsUnknownCategories(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	_, err = New("/tmp/test_config.json", WithLogger(mockLogger), WithExcludePaths("build", []string{"/build/%%"}))
+	assert.ErrorContains(t, err, `exclude_paths category "build"`)
+
+	_, err = New("/tmp/test_config.json", WithLogger(mockLogger), WithFileAccesses([]string{"homes"}))
+	assert.ErrorContains(t, err, `file_accesses category "homes"`)
+
+	_, err = New("/tmp/test_config.json", WithLogger(mockLogger), WithScheduleInterval(time.Millisecond))
+	assert.Error(t, err)
+}
+
+// fakeOsqueryi answers every statement written to the returned stdin with
+// respond's output, echoing sequence markers the way osqueryi --json would.
+func fakeOsqueryi(t *testing.T, respond func(query string) string) (io.WriteCloser, io.ReadCloser) {
+	stdinReader, stdin := io.Pipe()
+	stdout, stdoutWriter := io.Pipe()
+	marker := regexp.MustCompile(`^SELECT '(\d+)' AS ` + sequenceColumn + `;$`)
+
+	go func() {
+		defer stdoutWriter.Close()
+		scanner := bufio.NewScanner(stdinReader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if m := marker.FindStringSubmatch(line); m != nil {
+				fmt.Fprintf(stdoutWriter, "[\n  {\"%s\":\"%s\"}\n]\n", sequenceColumn, m[1])
+				continue
+			}
+			if response := respond(line); response != "" {
+				fmt.Fprintln(stdoutWriter, response)
+			}
+		}
+	}()
+	t.Cleanup(func() { stdin.Close() })
+	return stdin, stdout
+}
+
+// fileEventRows encodes rows the way osquery answers query. eid is a hidden
+// column of file_events, so rows only carry it when query selects it.
+func fileEventRows(query string, rows ...map[string]string) string {
+	selectsEID := regexp.MustCompile(`\beid\b`).MatchString(query)
+	result := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		copied := make(map[string]string, len(row))
+		for column, value := range row {
+			if column != "eid" || selectsEID {
+				copied[column] = value
+			}
+		}
+		result = append(result, copied)
+	}
+	data, _ := json.Marshal(result)
+	return string(data)
+}
+
+// requireEventIDs fails the test if an event read from osquery has no ID.
+func requireEventIDs(t *testing.T, events []FileEvent) {
+	t.Helper()
+	for _, event := range events {
+		require.NotEmpty(t, event.ID, "event for %s has no ID", event.TargetPath)
+	}
 }
 
 // TestQuery tests the Query method
@@ -96,23 +191,72 @@ func TestQuery(t *testing.T) {
 	client, err := New(configPath, WithLogger(mockLogger), WithOsqueryBinary("echo"))
 	assert.NoError(t, err)
 
-	// Mock stdin
-	mockStdin := new(MockWriter)
-	mockStdin.On("Write", mock.Anything).Return(len("SELECT * FROM users LIMIT 1"), nil)
-	client.stdin = mockStdin
+	var queries []string
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		queries = append(queries, query)
+		return `[{"username":"test_user","uid":"1000"}]`
+	})
 
-	// Mock stdout
-	mockResult := `[{"username":"test_user","uid":"1000"}]`
-	mockStdout := NewMockReader([]byte(mockResult))
-	client.stdout = mockStdout
-
-	results, err := client.Query("SELECT * FROM users LIMIT 1")
+	results, err := client.Query(context.Background(), "SELECT * FROM users LIMIT 1")
 	assert.NoError(t, err)
 	assert.NotNil(t, results)
 	assert.Len(t, results, 1)
 	assert.Equal(t, "test_user", results[0]["username"])
+	assert.Equal(t, []string{"SELECT * FROM users LIMIT 1;"}, queries)
+}
 
-	mockStdin.AssertExpectations(t)
+// TestQueryEmptyResult checks that a query osqueryi prints nothing for does
+// not take the next query's response
+func TestQueryEmptyResult(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger))
+	assert.NoError(t, err)
+
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		if strings.Contains(query, "users") {
+			return `[{"username":"test_user"}]`
+		}
+		return ""
+	})
+
+	results, err := client.Query(context.Background(), "SELECT * FROM no_such_table;")
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = client.Query(context.Background(), "SELECT * FROM users;")
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+// TestQueryTimeout checks that a query osqueryi never answers returns at the
+// deadline and marks the session unhealthy
+func TestQueryTimeout(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger), WithQueryTimeout(50*time.Millisecond))
+	assert.NoError(t, err)
+
+	stdinReader, stdin := io.Pipe()
+	go io.Copy(io.Discard, stdinReader)
+	stdout, _ := io.Pipe()
+	client.stdin, client.stdout = stdin, stdout
+
+	start := time.Now()
+	_, err = client.Query(context.Background(), "SELECT * FROM file_events;")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, client.session.healthy())
+
+	_, err = client.Query(context.Background(), "SELECT * FROM file_events;")
+	assert.ErrorContains(t, err, "unhealthy")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Query(ctx, "SELECT * FROM file_events;")
+	assert.Error(t, err)
 }
 
 // TestGetFileEvents tests the GetFileEvents method
@@ -126,23 +270,55 @@ func TestGetFileEvents(t *testing.T) {
 	client, err := New(configPath, WithLogger(mockLogger), WithOsqueryBinary("echo"))
 	assert.NoError(t, err)
 
-	// Mock stdin
-	mockStdin := new(MockWriter)
-	mockStdin.On("Write", mock.Anything).Return(len("SELECT * FROM file_events;"), nil)
-	client.stdin = mockStdin
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		return fileEventRows(query, map[string]string{"eid": "1", "path": "/test/file", "action": "CREATED"})
+	})
 
-	// Mock stdout
-	mockResult := `[{"path":"/test/file","action":"CREATED"}]`
-	mockStdout := NewMockReader([]byte(mockResult))
-	client.stdout = mockStdout
-
-	events, err := client.GetFileEvents()
+	events, err := client.GetFileEvents(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, events)
 	assert.Len(t, events, 1)
-	assert.Equal(t, "/test/file", events[0]["path"])
+	requireEventIDs(t, events)
+	assert.Equal(t, "/test/file", events[0].Path)
+	assert.Equal(t, ActionCreated, events[0].Action)
+	assert.Equal(t, defaultSeverity, events[0].Severity)
+}
 
-	mockStdin.AssertExpectations(t)
+func TestWithWatches(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger), WithWatches([]Watch{
+		{Path: "/etc", Recursive: true, Label: "etc", Severity: "critical"},
+		{Path: "/home/*", Label: "homes"},
+		{Path: "/Users/*", Label: "homes"},
+	}))
+	require.NoError(t, err)
+
+	config := client.osqueryConfig()
+	assert.Equal(t, map[string][]string{
+		"etc":   {"/etc/%%"},
+		"homes": {"/home/%/%", "/Users/%/%"},
+	}, config.FilePaths)
+	assert.Contains(t, config.Schedule, "file_events_etc")
+
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		return fileEventRows(query,
+			map[string]string{"eid": "1", "target_path": "/etc/passwd", "category": "etc"},
+			map[string]string{"eid": "2", "target_path": "/home/a/b", "category": "homes"})
+	})
+	events, err := client.GetFileEvents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	requireEventIDs(t, events)
+	assert.Equal(t, "critical", events[0].Severity)
+	assert.Equal(t, defaultSeverity, events[1].Severity)
+
+	_, err = New("/tmp/test_config.json", WithLogger(mockLogger), WithWatches([]Watch{
+		{Path: "/etc", Label: "etc", Severity: "critical"},
+		{Path: "/usr/local/etc", Label: "etc", Severity: "low"},
+	}))
+	assert.ErrorContains(t, err, "different severities")
 }
 
 // TestGetFileEventsByPath tests the GetFileEventsByPath method
@@ -156,25 +332,38 @@ func TestGetFileEventsByPath(t *testing.T) {
 	client, err := New(configPath, WithLogger(mockLogger), WithOsqueryBinary("echo"))
 	assert.NoError(t, err)
 
-	// Mock stdin
-	mockStdin := new(MockWriter)
-	mockStdin.On("Write", mock.Anything).Return(100, nil) // Assume query length is 100
-	client.stdin = mockStdin
-
-	// Mock stdout
-	mockResult := `[{"path":"/test/path/file","action":"MODIFIED"}]`
-	mockStdout := NewMockReader([]byte(mockResult))
-	client.stdout = mockStdout
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		return fileEventRows(query, map[string]string{"eid": "1", "path": "/test/path/file", "action": "MODIFIED"})
+	})
 
 	path := "/test/path"
 	since := time.Now().Add(-1 * time.Hour)
-	events, err := client.GetFileEventsByPath(path, since)
+	events, err := client.GetFileEventsByPath(context.Background(), path, since)
 	assert.NoError(t, err)
 	assert.NotNil(t, events)
 	assert.Len(t, events, 1)
-	assert.Equal(t, "/test/path/file", events[0]["path"])
+	requireEventIDs(t, events)
+	assert.Equal(t, "/test/path/file", events[0].Path)
+}
 
-	mockStdin.AssertExpectations(t)
+// TestGetFileEventsByPathEscaping checks that a path cannot change the
+// query it is placed in.
+func TestGetFileEventsByPathEscaping(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New(filepath.Join(t.TempDir(), "test_config.json"), WithLogger(mockLogger), WithOsqueryBinary("echo"))
+	require.NoError(t, err)
+
+	var query string
+	client.stdin, client.stdout = fakeOsqueryi(t, func(q string) string {
+		query = q
+		return "[]"
+	})
+
+	_, err = client.GetFileEventsByPath(context.Background(), "/tmp/it's_100%\\\n';", time.Unix(100, 0))
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT *, eid FROM file_events WHERE target_path LIKE '/tmp/it''s\_100\%\\' || char(10) || ''';%' ESCAPE '\' AND time > 100;`, query)
 }
 
 // TestGetFileChangesSummary tests the GetFileChangesSummary method
@@ -188,23 +377,24 @@ func TestGetFileChangesSummary(t *testing.T) {
 	client, err := New(configPath, WithLogger(mockLogger), WithOsqueryBinary("echo"))
 	assert.NoError(t, err)
 
-	// Mock stdin
-	mockStdin := new(MockWriter)
-	mockStdin.On("Write", mock.Anything).Return(100, nil) // Assume query length is 100
-	client.stdin = mockStdin
-
-	mockResult := `[{"action":"CREATED","count":10}]`
-	mockStdout := NewMockReader([]byte(mockResult))
-	client.stdout = mockStdout
+	client.stdin, client.stdout = fakeOsqueryi(t, func(query string) string {
+		if strings.Contains(query, "GROUP BY action") {
+			return `[{"action":"CREATED","count":10,"first_occurrence":"100","last_occurrence":"200"}]`
+		}
+		return ""
+	})
 
 	since := time.Now().Add(-24 * time.Hour)
-	summary, err := client.GetFileChangesSummary(since)
+	summary, err := client.GetFileChangesSummary(context.Background(), since)
 	assert.NoError(t, err)
 	assert.NotNil(t, summary)
 	assert.Len(t, summary, 1)
-	assert.Equal(t, float64(10), summary[0]["count"])
-
-	mockStdin.AssertExpectations(t)
+	assert.Equal(t, ActionSummary{
+		Action:          ActionCreated,
+		Count:           10,
+		FirstOccurrence: time.Unix(100, 0).UTC(),
+		LastOccurrence:  time.Unix(200, 0).UTC(),
+	}, summary[0])
 }
 
 func TestClose(t *testing.T) {
@@ -245,3 +435,100 @@ func NewMockReader(data []byte) *MockReader {
 		Reader: bytes.NewReader(data),
 	}
 }
+
+// MockExtensionClient is a mock implementation of extensionClient
+type MockExtensionClient struct {
+	mock.Mock
+}
+
+func (m *MockExtensionClient) QueryContext(ctx context.Context, sql string) (*gen.ExtensionResponse, error) {
+	args := m.Called(sql)
+	resp, _ := args.Get(0).(*gen.ExtensionResponse)
+	return resp, args.Error(1)
+}
+
+func (m *MockExtensionClient) Close() {
+	m.Called()
+}
+
+func TestQuerySocket(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger), WithExtensionSocket("/tmp/osquery.em"))
+	assert.NoError(t, err)
+
+	// The first connection drops mid-query, the reconnected one answers.
+	lost := new(MockExtensionClient)
+	lost.On("QueryContext", "SELECT *, eid FROM file_events;").Return(nil, errors.New("broken pipe"))
+	lost.On("Close").Return()
+
+	healthy := new(MockExtensionClient)
+	healthy.On("QueryContext", "SELECT *, eid FROM file_events;").Return(&gen.ExtensionResponse{
+		Status:   &gen.ExtensionStatus{Code: 0},
+		Response: gen.ExtensionPluginResponse{{"eid": "1", "target_path": "/test/file", "action": "CREATED"}},
+	}, nil)
+
+	dials := []extensionClient{lost, healthy}
+	client.dialSocket = func(path string, timeout time.Duration) (extensionClient, error) {
+		assert.Equal(t, "/tmp/osquery.em", path)
+		next := dials[0]
+		dials = dials[1:]
+		return next, nil
+	}
+
+	assert.NoError(t, client.Start(context.Background()))
+
+	events, err := client.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	requireEventIDs(t, events)
+	assert.Equal(t, "/test/file", events[0].TargetPath)
+
+	lost.AssertExpectations(t)
+	healthy.AssertExpectations(t)
+}
+
+func TestQuerySocketError(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger), WithExtensionSocket("/tmp/osquery.em"))
+	assert.NoError(t, err)
+
+	mockClient := new(MockExtensionClient)
+	mockClient.On("QueryContext", mock.Anything).Return(&gen.ExtensionResponse{
+		Status: &gen.ExtensionStatus{Code: 1, Message: "no such table: file_event"},
+	}, nil)
+	client.dialSocket = func(path string, timeout time.Duration) (extensionClient, error) {
+		return mockClient, nil
+	}
+
+	_, err = client.Query(context.Background(), "SELECT * FROM file_event;")
+	assert.EqualError(t, err, "query returned error: no such table: file_event")
+}
+
+func TestConnectSocketWhileReportingStatus(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New("/tmp/test_config.json", WithLogger(mockLogger), WithExtensionSocket("/tmp/osquery.em"))
+	assert.NoError(t, err)
+
+	client.dialSocket = func(path string, timeout time.Duration) (extensionClient, error) {
+		return new(MockExtensionClient), nil
+	}
+
+	// Run with -race: the connection is set while Status reads it.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			client.Status()
+		}
+	}()
+	assert.NoError(t, client.Start(context.Background()))
+	<-done
+
+	assert.Equal(t, StateRunning, client.Status().State)
+}
@@ -0,0 +1,218 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+type (
+	// PollingMonitor periodically walks the monitored directories and diffs
+	// each scan against the previous one. It needs neither osquery nor kernel
+	// notifications, so it also works on NFS, bind mounts and in containers.
+	PollingMonitor struct {
+		watches   []Watch
+		interval  time.Duration
+		hashFiles bool
+		events    *eventBuffer
+		log       *logger.Logger
+
+		mutex    sync.Mutex
+		snapshot map[string]fileState
+		cancel   context.CancelFunc
+		done     chan struct{}
+	}
+
+	fileState struct {
+		size   int64
+		mtime  time.Time
+		mode   fs.FileMode
+		inode  uint64
+		sha256 string
+		root   watchRoot
+	}
+)
+
+var (
+	_ Monitor     = (*PollingMonitor)(nil)
+	_ EventSource = (*PollingMonitor)(nil)
+)
+
+func NewPolling(watches []Watch, interval time.Duration, hashFiles bool, log *logger.Logger) (*PollingMonitor, error) {
+	if log == nil {
+		return nil, fmt.Errorf("logger is required")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("scan interval must be positive, got %s", interval)
+	}
+	return &PollingMonitor{
+		watches:   watches,
+		interval:  interval,
+		hashFiles: hashFiles,
+		events:    newEventBuffer(defaultBufferSize),
+		log:       log,
+	}, nil
+}
+
+// Start takes the baseline snapshot synchronously, so changes made after Start
+// returns are reported by the next scan.
+func (p *PollingMonitor) Start(ctx context.Context) error {
+	p.log.Info("Started polling file tracking...", "interval", p.interval)
+	p.scan()
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+	go p.run(ctx)
+	return nil
+}
+
+func (p *PollingMonitor) run(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.scan()
+		}
+	}
+}
+
+// scan walks every watch root and records the differences from the previous
+// snapshot. The first scan only establishes the baseline.
+func (p *PollingMonitor) scan() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	current := make(map[string]fileState)
+	for _, root := range resolveWatchRoots(p.watches) {
+		p.walk(root, current)
+	}
+
+	if p.snapshot == nil {
+		p.snapshot = current
+		return
+	}
+
+	var created, updated, modified, deleted []string
+	for path, state := range current {
+		previous, ok := p.snapshot[path]
+		switch {
+		case !ok:
+			created = append(created, path)
+		case state.size != previous.size || !state.mtime.Equal(previous.mtime) ||
+			state.inode != previous.inode || state.sha256 != previous.sha256:
+			updated = append(updated, path)
+		case state.mode != previous.mode:
+			modified = append(modified, path)
+		}
+	}
+	for path := range p.snapshot {
+		if _, ok := current[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+	last := p.snapshot
+	p.snapshot = current
+
+	p.record(created, ActionCreated, current)
+	p.record(updated, ActionUpdated, current)
+	p.record(modified, ActionAttributesModified, current)
+	p.record(deleted, ActionDeleted, last)
+}
+
+// record adds an event for each path, labelled by the root it was found
+// under in states.
+func (p *PollingMonitor) record(paths []string, action Action, states map[string]fileState) {
+	sort.Strings(paths)
+	for _, path := range paths {
+		root := states[path].root
+		p.events.add(newFileEvent(path, root, action, root.hashes(p.hashFiles) && action != ActionDeleted))
+	}
+}
+
+func (p *PollingMonitor) walk(root watchRoot, snapshot map[string]fileState) {
+	err := filepath.WalkDir(root.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			p.log.Warn("Failed to scan path", "path", path, "error", err)
+			return nil
+		}
+		if path == root.path {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// The entry vanished between listing and stat; the next scan
+			// reports it as deleted if it was known.
+			return nil
+		}
+		snapshot[path] = p.stateOf(path, info, root)
+
+		if d.IsDir() && !root.recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		p.log.Error("Failed to scan monitored directory", "path", root.path, "error", err)
+	}
+}
+
+func (p *PollingMonitor) stateOf(path string, info fs.FileInfo, root watchRoot) fileState {
+	state := fileState{
+		size:  info.Size(),
+		mtime: info.ModTime(),
+		mode:  info.Mode(),
+		root:  root,
+	}
+	if st, ok := statFileInfo(info); ok {
+		state.inode = st.inode
+	}
+	if root.hashes(p.hashFiles) && info.Mode().IsRegular() && info.Size() <= maxHashSize {
+		if _, _, sha256Sum, err := fileHashes(path); err == nil {
+			state.sha256 = sha256Sum
+		}
+	}
+	return state
+}
+
+func (p *PollingMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return p.events.all(), nil
+}
+
+func (p *PollingMonitor) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	events, next := p.events.since(cursor)
+	return events, next, nil
+}
+
+func (p *PollingMonitor) Head(ctx context.Context) (Cursor, error) {
+	return p.events.head(), nil
+}
+
+func (p *PollingMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	return p.events.byPath(path, since), nil
+}
+
+func (p *PollingMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	return p.events.summary(since), nil
+}
+
+func (p *PollingMonitor) Close() error {
+	p.log.Info("Closing polling monitor")
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func actionsByPath(events []FileEvent) map[string]Action {
+	actions := make(map[string]Action)
+	for _, event := range events {
+		actions[event.TargetPath] = event.Action
+	}
+	return actions
+}
+
+func TestNewPolling(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	_, err = NewPolling(DirWatches([]string{"/tmp"}), 0, false, mockLogger)
+	assert.Error(t, err)
+
+	_, err = NewPolling(DirWatches([]string{"/tmp"}), time.Minute, false, nil)
+	assert.Error(t, err)
+}
+
+func TestPollingMonitorScan(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	removed := filepath.Join(dir, "removed.txt")
+	chmodded := filepath.Join(dir, "chmodded.txt")
+	for _, path := range []string{existing, removed, chmodded} {
+		require.NoError(t, os.WriteFile(path, []byte("original"), 0644))
+	}
+
+	monitor, err := NewPolling(DirWatches([]string{dir + "/%%"}), time.Hour, true, mockLogger)
+	require.NoError(t, err)
+
+	// The baseline scan does not report pre-existing files.
+	monitor.scan()
+	events, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	created := filepath.Join(dir, "sub", "created.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(created), 0755))
+	require.NoError(t, os.WriteFile(created, []byte("new"), 0644))
+	require.NoError(t, os.Remove(removed))
+	require.NoError(t, os.Chmod(chmodded, 0600))
+
+	// Same size and mtime: only the content hash reveals the change.
+	info, err := os.Stat(existing)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(existing, []byte("modified"), 0644))
+	require.NoError(t, os.Chtimes(existing, info.ModTime(), info.ModTime()))
+
+	monitor.scan()
+	events, err = monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+
+	actions := actionsByPath(events)
+	assert.Equal(t, ActionCreated, actions[created])
+	assert.Equal(t, ActionCreated, actions[filepath.Dir(created)])
+	assert.Equal(t, ActionDeleted, actions[removed])
+	assert.Equal(t, ActionAttributesModified, actions[chmodded])
+	assert.Equal(t, ActionUpdated, actions[existing])
+
+	for _, event := range events {
+		if event.TargetPath == created {
+			require.NotNil(t, event.Hashes)
+			assert.NotEmpty(t, event.Hashes.SHA256)
+		}
+	}
+
+	// Nothing changed since the last scan.
+	monitor.scan()
+	again, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, again, len(events))
+}
+
+func TestPollingMonitorNonRecursive(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	monitor, err := NewPolling(DirWatches([]string{dir + "/%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	monitor.scan()
+
+	nested := filepath.Join(dir, "sub", "nested.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(nested), 0755))
+	require.NoError(t, os.WriteFile(nested, []byte("nested"), 0644))
+
+	monitor.scan()
+	events, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+
+	actions := actionsByPath(events)
+	assert.Equal(t, ActionCreated, actions[filepath.Dir(nested)])
+	assert.NotContains(t, actions, nested)
+}
+
+func TestPollingMonitorWatchLabels(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	etc := t.TempDir()
+	home := t.TempDir()
+	noHash := false
+	monitor, err := NewPolling([]Watch{
+		{Path: etc, Recursive: true, Label: "etc", Severity: "critical"},
+		{Path: home, Label: "homes", Hash: &noHash},
+	}, time.Hour, true, mockLogger)
+	require.NoError(t, err)
+	monitor.scan()
+
+	config := filepath.Join(etc, "passwd")
+	notes := filepath.Join(home, "notes.txt")
+	require.NoError(t, os.WriteFile(config, []byte("root"), 0644))
+	require.NoError(t, os.WriteFile(notes, []byte("notes"), 0644))
+	monitor.scan()
+
+	events, err := monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, event := range events {
+		switch event.TargetPath {
+		case config:
+			assert.Equal(t, "etc", event.Category)
+			assert.Equal(t, "critical", event.Severity)
+			assert.NotNil(t, event.Hashes)
+		case notes:
+			assert.Equal(t, "homes", event.Category)
+			assert.Equal(t, defaultSeverity, event.Severity)
+			assert.Nil(t, event.Hashes)
+		}
+	}
+
+	require.NoError(t, os.Remove(config))
+	monitor.scan()
+	events, err = monitor.GetFileEvents(context.Background())
+	assert.NoError(t, err)
+	deleted := events[len(events)-1]
+	assert.Equal(t, ActionDeleted, deleted.Action)
+	assert.Equal(t, "etc", deleted.Category)
+	assert.Nil(t, deleted.Size)
+}
@@ -0,0 +1,449 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/ignore"
+)
+
+const (
+	// DefaultQueryLimit is the page size of a query that sets no limit.
+	DefaultQueryLimit = 1000
+	// MaxQueryLimit is the largest page a query may ask for.
+	MaxQueryLimit = 10000
+)
+
+const (
+	OrderAscending  Order = "asc"
+	OrderDescending Order = "desc"
+)
+
+var (
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrScanUnsupported = errors.New("monitor cannot scan events")
+)
+
+type (
+	// Order is the order events are returned in, by time and then ID.
+	Order string
+
+	// EventQuery selects a page of events. Zero fields match every event.
+	EventQuery struct {
+		// Path matches the target path by prefix or, if it contains "*", "?"
+		// or "[", as a glob with the syntax of ignore rules.
+		Path       string
+		Actions    []Action
+		Categories []string
+		// Since excludes events at or before it, like GetFileEventsByPath;
+		// Until excludes events after it.
+		Since time.Time
+		Until time.Time
+		UID   *uint32
+		// Hash matches any of an event's MD5, SHA-1 or SHA-256 hashes.
+		Hash  string
+		Order Order
+		Limit int
+		// Cursor is the NextCursor of the previous page.
+		Cursor string
+	}
+
+	// EventPage is one page of query results. NextCursor is empty on the
+	// last page.
+	EventPage struct {
+		Events     []FileEvent
+		NextCursor string
+	}
+
+	// EventScanner is implemented by monitors that can read just the events
+	// within bounds, so a query stops reading once its page is full.
+	EventScanner interface {
+		// ScanEvents calls fn with the events within bounds in the order
+		// they were recorded, or the reverse if bounds.Descending, until fn
+		// returns false. fn may narrow the time bounds as it goes.
+		ScanEvents(ctx context.Context, bounds *ScanBounds, fn func(FileEvent) bool) error
+	}
+
+	// ScanBounds limits an EventScanner's scan. Zero fields do not limit it.
+	ScanBounds struct {
+		// Path is a prefix of the target path.
+		Path string
+		// Since excludes events at or before it; Until excludes events after
+		// it.
+		Since      time.Time
+		Until      time.Time
+		Descending bool
+	}
+
+	// position is what a cursor encodes: the last event of a page.
+	position struct {
+		Time time.Time `json:"t"`
+		ID   string    `json:"id"`
+	}
+)
+
+// QueryEvents returns the page of m's events selected by query. Monitors that
+// scan events are read only until no further event can make the page.
+func QueryEvents(ctx context.Context, m Monitor, query EventQuery) (EventPage, error) {
+	scanner, ok := scannerOf(m)
+	if !ok {
+		events, err := fetchEvents(ctx, m, query)
+		if err != nil {
+			return EventPage{}, err
+		}
+		return query.Page(events)
+	}
+
+	glob, after, err := query.prepare()
+	if err != nil {
+		return EventPage{}, err
+	}
+	limit := query.limit()
+	descending := query.Order == OrderDescending
+
+	bounds := query.bounds()
+	if after != nil {
+		// Only events from the time of the cursor on can follow it.
+		if descending && (bounds.Until.IsZero() || after.Time.Before(bounds.Until)) {
+			bounds.Until = after.Time
+		}
+		if since := after.Time.Add(-time.Nanosecond); !descending && since.After(bounds.Since) {
+			bounds.Since = since
+		}
+	}
+
+	var matched []FileEvent
+	err = scanner.ScanEvents(ctx, &bounds, func(event FileEvent) bool {
+		if !query.match(event, glob) || (after != nil && !query.precedes(*after, positionOf(event))) {
+			return true
+		}
+		matched = append(matched, event)
+		if len(matched) == 2*(limit+1) {
+			// Keep the page and the event showing there is another; only
+			// events from the time of the last one kept can still replace
+			// them.
+			query.sort(matched)
+			matched = matched[:limit+1]
+			if last := matched[limit].Time; descending {
+				bounds.Since = last.Add(-time.Nanosecond)
+			} else {
+				bounds.Until = last
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return EventPage{}, err
+	}
+	return query.Page(matched)
+}
+
+// QueryRawEvents is QueryEvents over the raw events behind m's logical ones.
+func QueryRawEvents(ctx context.Context, m RawEventSource, query EventQuery) (EventPage, error) {
+	if wrapper, ok := m.(interface{ Unwrap() Monitor }); ok {
+		if _, ok := scannerOf(wrapper.Unwrap()); ok {
+			return QueryEvents(ctx, wrapper.Unwrap(), query)
+		}
+	}
+	events, err := m.GetRawFileEvents(ctx)
+	if err != nil {
+		return EventPage{}, err
+	}
+	return query.Page(events)
+}
+
+// MatchingEvents returns all of m's events selected by query, ignoring its
+// order, limit and cursor.
+func MatchingEvents(ctx context.Context, m Monitor, query EventQuery) ([]FileEvent, error) {
+	glob, _, err := query.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []FileEvent
+	if scanner, ok := scannerOf(m); ok {
+		bounds := query.bounds()
+		err := scanner.ScanEvents(ctx, &bounds, func(event FileEvent) bool {
+			if query.match(event, glob) {
+				matched = append(matched, event)
+			}
+			return true
+		})
+		return matched, err
+	}
+
+	events, err := fetchEvents(ctx, m, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if query.match(event, glob) {
+			matched = append(matched, event)
+		}
+	}
+	return matched, nil
+}
+
+// EachEvent calls fn for every one of m's events selected by query, ignoring
+// its order, limit and cursor, until fn returns an error. Monitors that hand
+// out events incrementally are read a batch at a time in the order events
+// happened, so the events never all have to be in memory; others are read
+// at once and sorted. Events are raw, not coalesced.
+func EachEvent(ctx context.Context, m Monitor, query EventQuery, fn func(FileEvent) error) error {
+	glob, _, err := query.prepare()
+	if err != nil {
+		return err
+	}
+
+	source, ok := EventSourceOf(m)
+	if !ok {
+		events, err := MatchingEvents(ctx, m, query)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return positionOf(events[i]).before(positionOf(events[j]))
+		})
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var cursor Cursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		events, next, err := source.EventsSince(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if !query.match(event, glob) {
+				continue
+			}
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		if next.equal(cursor) {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// SummarizeEvents returns per action counts of m's events selected by
+// query. A query that only sets Since is answered by the backend's own
+// GetFileChangesSummary, unless m can scan just the events after it.
+func SummarizeEvents(ctx context.Context, m Monitor, query EventQuery) ([]ActionSummary, error) {
+	_, scans := scannerOf(m)
+	if !scans && query.Path == "" && len(query.Actions) == 0 && len(query.Categories) == 0 &&
+		query.Until.IsZero() && query.UID == nil && query.Hash == "" {
+		return m.GetFileChangesSummary(ctx, query.Since)
+	}
+	events, err := MatchingEvents(ctx, m, query)
+	if err != nil {
+		return nil, err
+	}
+	return summarize(events, query.Since), nil
+}
+
+// scannerOf returns m if it can scan events, which a CoalescingMonitor only
+// can if the monitor it wraps does.
+func scannerOf(m Monitor) (EventScanner, bool) {
+	if c, ok := m.(*CoalescingMonitor); ok {
+		if _, ok := c.Monitor.(EventScanner); !ok {
+			return nil, false
+		}
+	}
+	scanner, ok := m.(EventScanner)
+	return scanner, ok
+}
+
+// fetchEvents passes the path prefix and start time of query to
+// GetFileEventsByPath, so backends that can narrow their results do.
+func fetchEvents(ctx context.Context, m Monitor, query EventQuery) ([]FileEvent, error) {
+	prefix := query.prefix()
+	if prefix == "" && query.Since.IsZero() {
+		return m.GetFileEvents(ctx)
+	}
+	return m.GetFileEventsByPath(ctx, prefix, query.Since)
+}
+
+// prefix returns the part of the path before any glob characters.
+func (q EventQuery) prefix() string {
+	if i := strings.IndexAny(q.Path, "*?["); i >= 0 {
+		return q.Path[:i]
+	}
+	return q.Path
+}
+
+// bounds returns the scan bounds of the query's filters.
+func (q EventQuery) bounds() ScanBounds {
+	return ScanBounds{Path: q.prefix(), Since: q.Since, Until: q.Until, Descending: q.Order == OrderDescending}
+}
+
+func (q EventQuery) limit() int {
+	if q.Limit == 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+// Validate checks the order, limit, path glob and cursor of the query.
+func (q EventQuery) Validate() error {
+	_, _, err := q.prepare()
+	return err
+}
+
+// prepare validates the query, compiling the path if it is a glob and
+// decoding the cursor.
+func (q EventQuery) prepare() (*regexp.Regexp, *position, error) {
+	if q.Order != "" && q.Order != OrderAscending && q.Order != OrderDescending {
+		return nil, nil, fmt.Errorf("invalid order %q", q.Order)
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return nil, nil, fmt.Errorf("limit must be between 1 and %d", MaxQueryLimit)
+	}
+
+	var glob *regexp.Regexp
+	if strings.ContainsAny(q.Path, "*?[") {
+		var err error
+		if glob, err = ignore.Glob(q.Path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var after *position
+	if q.Cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+		after = &position{}
+		if err := json.Unmarshal(data, after); err != nil {
+			return nil, nil, ErrInvalidCursor
+		}
+	}
+	return glob, after, nil
+}
+
+// Matcher returns a function reporting whether an event is selected by the
+// query's filters.
+func (q EventQuery) Matcher() (func(FileEvent) bool, error) {
+	glob, _, err := q.prepare()
+	if err != nil {
+		return nil, err
+	}
+	return func(event FileEvent) bool {
+		return q.match(event, glob)
+	}, nil
+}
+
+// Page applies the query to events, which may be in any order.
+func (q EventQuery) Page(events []FileEvent) (EventPage, error) {
+	glob, after, err := q.prepare()
+	if err != nil {
+		return EventPage{}, err
+	}
+	limit := q.limit()
+
+	matched := make([]FileEvent, 0, min(len(events), limit+1))
+	for _, event := range events {
+		if q.match(event, glob) && (after == nil || q.precedes(*after, positionOf(event))) {
+			matched = append(matched, event)
+		}
+	}
+	q.sort(matched)
+
+	page := EventPage{Events: matched}
+	if len(matched) > limit {
+		page.Events = matched[:limit]
+		data, err := json.Marshal(positionOf(page.Events[limit-1]))
+		if err != nil {
+			return EventPage{}, err
+		}
+		page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, nil
+}
+
+// precedes reports whether a comes before b in the query's order.
+func (q EventQuery) precedes(a, b position) bool {
+	if q.Order == OrderDescending {
+		a, b = b, a
+	}
+	return a.before(b)
+}
+
+func (q EventQuery) sort(events []FileEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return q.precedes(positionOf(events[i]), positionOf(events[j]))
+	})
+}
+
+func (q EventQuery) match(event FileEvent, glob *regexp.Regexp) bool {
+	switch {
+	case glob != nil && !glob.MatchString(event.TargetPath):
+		return false
+	case glob == nil && !strings.HasPrefix(event.TargetPath, q.Path):
+		return false
+	case len(q.Actions) > 0 && !slices.Contains(q.Actions, event.Action):
+		return false
+	case len(q.Categories) > 0 && !slices.Contains(q.Categories, event.Category):
+		return false
+	case !q.Since.IsZero() && !event.Time.After(q.Since):
+		return false
+	case !q.Until.IsZero() && event.Time.After(q.Until):
+		return false
+	case q.UID != nil && (event.UID == nil || *event.UID != *q.UID):
+		return false
+	case q.Hash != "" && !hasHash(event.Hashes, q.Hash):
+		return false
+	}
+	return true
+}
+
+// contains reports whether event lies within the bounds.
+func (b *ScanBounds) contains(event FileEvent) bool {
+	return strings.HasPrefix(event.TargetPath, b.Path) &&
+		(b.Since.IsZero() || event.Time.After(b.Since)) &&
+		(b.Until.IsZero() || !event.Time.After(b.Until))
+}
+
+func hasHash(hashes *Hashes, hash string) bool {
+	if hashes == nil {
+		return false
+	}
+	return strings.EqualFold(hashes.MD5, hash) ||
+		strings.EqualFold(hashes.SHA1, hash) ||
+		strings.EqualFold(hashes.SHA256, hash)
+}
+
+func positionOf(event FileEvent) position {
+	return position{Time: event.Time, ID: event.ID}
+}
+
+// before orders events by time, then by numeric ID, then by ID.
+func (p position) before(other position) bool {
+	if !p.Time.Equal(other.Time) {
+		return p.Time.Before(other.Time)
+	}
+	a, b := eventSeq(FileEvent{ID: p.ID}), eventSeq(FileEvent{ID: other.ID})
+	if a != b {
+		return a < b
+	}
+	return p.ID < other.ID
+}
@@ -0,0 +1,209 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventQueryPage(t *testing.T) {
+	hashed := withHash(rawEvent("4", "/etc/ssh/sshd_config", ActionUpdated, 0, 103), "ABC123")
+	hashed.UID = ptr(uint32(0))
+	hashed.Category = "etc"
+	events := []FileEvent{
+		hashed,
+		rawEvent("1", "/etc/hosts", ActionCreated, 0, 100),
+		rawEvent("3", "/home/alice/.ssh/id_rsa", ActionDeleted, 0, 102),
+		rawEvent("2", "/etc/passwd", ActionUpdated, 0, 100),
+	}
+
+	tests := []struct {
+		name     string
+		query    EventQuery
+		expected []string
+	}{
+		{name: "everything in order", query: EventQuery{}, expected: []string{"1", "2", "3", "4"}},
+		{name: "descending", query: EventQuery{Order: OrderDescending}, expected: []string{"4", "3", "2", "1"}},
+		{name: "prefix", query: EventQuery{Path: "/etc/"}, expected: []string{"1", "2", "4"}},
+		{name: "glob", query: EventQuery{Path: "/**/.ssh/*"}, expected: []string{"3"}},
+		{name: "glob within segment", query: EventQuery{Path: "/etc/*"}, expected: []string{"1", "2"}},
+		{name: "actions", query: EventQuery{Actions: []Action{ActionCreated, ActionDeleted}}, expected: []string{"1", "3"}},
+		{name: "category", query: EventQuery{Categories: []string{"etc"}}, expected: []string{"4"}},
+		{
+			name:     "time range",
+			query:    EventQuery{Since: time.Unix(100, 0), Until: time.Unix(102, 0)},
+			expected: []string{"3"},
+		},
+		{name: "uid", query: EventQuery{UID: ptr(uint32(0))}, expected: []string{"4"}},
+		{name: "hash", query: EventQuery{Hash: "abc123"}, expected: []string{"4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tt.query.Page(events)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ids(page.Events))
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		for _, order := range []Order{OrderAscending, OrderDescending} {
+			var seen []string
+			query := EventQuery{Order: order, Limit: 3}
+			for {
+				page, err := query.Page(events)
+				require.NoError(t, err)
+				seen = append(seen, ids(page.Events)...)
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			all, err := EventQuery{Order: order}.Page(events)
+			require.NoError(t, err)
+			assert.Equal(t, ids(all.Events), seen)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := EventQuery{Cursor: "not a cursor"}.Page(events)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+		assert.Error(t, EventQuery{Order: "sideways"}.Validate())
+		assert.Error(t, EventQuery{Limit: MaxQueryLimit + 1}.Validate())
+		assert.Error(t, EventQuery{Path: "/etc/[z-a]"}.Validate())
+	})
+}
+
+func TestQueryEvents(t *testing.T) {
+	buffer := newEventBuffer(0)
+	for _, path := range []string{"/etc/hosts", "/var/log/syslog", "/etc/passwd"} {
+		buffer.add(rawEvent("", path, ActionUpdated, 0, 100))
+	}
+	monitor := &PollingMonitor{events: buffer}
+
+	page, err := QueryEvents(context.Background(), monitor, EventQuery{Path: "/etc/*", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1"}, ids(page.Events))
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = QueryEvents(context.Background(), monitor, EventQuery{Path: "/etc/*", Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3"}, ids(page.Events))
+	assert.Empty(t, page.NextCursor)
+}
+
+// sliceMonitor serves a fixed slice of events and cannot hand them out
+// incrementally.
+type sliceMonitor struct {
+	events []FileEvent
+}
+
+func (m sliceMonitor) Start(ctx context.Context) error { return nil }
+func (m sliceMonitor) Close() error                    { return nil }
+
+func (m sliceMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return m.events, nil
+}
+
+func (m sliceMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	return m.events, nil
+}
+
+func (m sliceMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	return summarize(m.events, since), nil
+}
+
+func TestEachEvent(t *testing.T) {
+	buffer := newEventBuffer(0)
+	buffer.add(rawEvent("", "/etc/passwd", ActionUpdated, 0, 120))
+	buffer.add(rawEvent("", "/var/log/syslog", ActionUpdated, 0, 100))
+	buffer.add(rawEvent("", "/etc/hosts", ActionCreated, 0, 110))
+	buffer.add(rawEvent("", "/etc/hosts", ActionDeleted, 0, 130))
+	query := EventQuery{Path: "/etc/", Until: time.Unix(125, 0), Limit: 1}
+
+	collect := func(monitor Monitor) []string {
+		var paths []string
+		require.NoError(t, EachEvent(context.Background(), monitor, query, func(event FileEvent) error {
+			paths = append(paths, event.TargetPath)
+			return nil
+		}))
+		return paths
+	}
+	// Read incrementally, in the order events were recorded.
+	assert.Equal(t, []string{"/etc/passwd", "/etc/hosts"}, collect(&PollingMonitor{events: buffer}))
+	// Read at once and sorted by time; the limit does not apply.
+	assert.Equal(t, []string{"/etc/hosts", "/etc/passwd"}, collect(sliceMonitor{buffer.all()}))
+
+	stop := errors.New("stop")
+	var calls int
+	err := EachEvent(context.Background(), &PollingMonitor{events: buffer}, EventQuery{}, func(FileEvent) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestSummarizeEvents(t *testing.T) {
+	buffer := newEventBuffer(0)
+	buffer.add(rawEvent("", "/etc/hosts", ActionUpdated, 0, 100))
+	buffer.add(rawEvent("", "/etc/hosts", ActionUpdated, 0, 105))
+	buffer.add(rawEvent("", "/var/log/syslog", ActionUpdated, 0, 110))
+	buffer.add(rawEvent("", "/etc/passwd", ActionDeleted, 0, 120))
+	monitor := &PollingMonitor{events: buffer}
+
+	summary, err := SummarizeEvents(context.Background(), monitor, EventQuery{Path: "/etc/", Until: time.Unix(110, 0)})
+	require.NoError(t, err)
+	assert.Equal(t, []ActionSummary{{
+		Action:          ActionUpdated,
+		Count:           2,
+		FirstOccurrence: time.Unix(100, 0),
+		LastOccurrence:  time.Unix(105, 0),
+	}}, summary)
+
+	summary, err = SummarizeEvents(context.Background(), monitor, EventQuery{Since: time.Unix(100, 0)})
+	require.NoError(t, err)
+	require.Len(t, summary, 2)
+	assert.Equal(t, 2, summary[1].Count)
+}
+
+func TestEventHistogram(t *testing.T) {
+	buffer := newEventBuffer(0)
+	buffer.add(rawEvent("", "/etc/hosts", ActionUpdated, 0, 600))
+	buffer.add(rawEvent("", "/etc/passwd", ActionUpdated, 0, 650))
+	buffer.add(rawEvent("", "/var/log/syslog", ActionCreated, 0, 1250))
+	monitor := &PollingMonitor{events: buffer}
+	query := EventQuery{Until: time.Unix(1300, 0)}
+
+	series, err := EventHistogram(context.Background(), monitor, query, 5*time.Minute, GroupByDirectory)
+	require.NoError(t, err)
+	require.Len(t, series, 2)
+	assert.Equal(t, "/etc", series[0].Group)
+	assert.Equal(t, "/var/log", series[1].Group)
+	assert.Equal(t, []HistogramBucket{
+		{Time: time.Unix(600, 0).UTC(), Count: 2},
+		{Time: time.Unix(900, 0).UTC(), Count: 0},
+		{Time: time.Unix(1200, 0).UTC(), Count: 0},
+	}, series[0].Buckets)
+	assert.Equal(t, 1, series[1].Buckets[2].Count)
+
+	query.Since = time.Unix(0, 0)
+	series, err = EventHistogram(context.Background(), monitor, query, 10*time.Minute, GroupByNone)
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, "all", series[0].Group)
+	assert.Len(t, series[0].Buckets, 3)
+	assert.Equal(t, 2, series[0].Buckets[1].Count)
+
+	_, err = EventHistogram(context.Background(), monitor, query, time.Millisecond, GroupByAction)
+	assert.ErrorIs(t, err, ErrInvalidHistogram)
+	_, err = EventHistogram(context.Background(), monitor, query, time.Minute, "owner")
+	assert.ErrorIs(t, err, ErrInvalidHistogram)
+	query.Since = time.Unix(1300, 0).Add(-MaxHistogramBuckets * time.Second)
+	_, err = EventHistogram(context.Background(), monitor, query, time.Second, GroupByAction)
+	assert.ErrorIs(t, err, ErrInvalidHistogram)
+}
@@ -0,0 +1,180 @@
+package monitoring
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/ignore"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+// Factory builds a Monitor from the application config. Each backend reads
+// its own option block from cfg.
+type Factory func(cfg *config.Config, log *logger.Logger) (Monitor, error)
+
+var (
+	registry      = make(map[string]Factory)
+	registryMutex sync.RWMutex
+)
+
+func init() {
+	Register("osquery", newOsqueryBackend)
+	Register("native", newNativeBackend)
+	Register("polling", newPollingBackend)
+}
+
+// Register makes a backend available under name for the monitor_backend
+// setting. Registering the same name twice replaces the earlier factory.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = factory
+}
+
+// Backends returns the names of all registered backends in sorted order.
+func Backends() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewFromConfig builds the backend named by cfg.MonitorBackend, filtered by
+// the configured ignore rules, persisted in the event store and with its
+// events coalesced into logical changes.
+func NewFromConfig(cfg *config.Config, log *logger.Logger) (Monitor, error) {
+	registryMutex.RLock()
+	factory, ok := registry[cfg.MonitorBackend]
+	registryMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown monitor backend %q, available backends: %s",
+			cfg.MonitorBackend, strings.Join(Backends(), ", "))
+	}
+
+	matcher, err := ignore.New(cfg.Ignore.Patterns, cfg.Ignore.File)
+	if err != nil {
+		return nil, err
+	}
+
+	monitor, err := factory(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	if !matcher.Empty() {
+		monitor = NewFiltered(monitor, matcher)
+	}
+	if cfg.Store.Path != "" {
+		s, err := store.Open(cfg.Store.Path,
+			store.WithMaxAge(cfg.Store.MaxAge),
+			store.WithMaxSize(cfg.Store.MaxSizeMB<<20),
+			store.WithMaxRecords(cfg.Store.MaxEvents),
+			store.WithCompactInterval(cfg.Store.CompactInterval),
+			store.WithLogger(log))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open event store: %w", err)
+		}
+		monitor = NewStored(monitor, s)
+	}
+	if cfg.Coalesce.Window > 0 {
+		monitor = NewCoalescing(monitor, cfg.Coalesce.Window)
+	}
+	return monitor, nil
+}
+
+func newOsqueryBackend(cfg *config.Config, log *logger.Logger) (Monitor, error) {
+	client, err := NewOsqueryFromConfig(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewOsqueryFromConfig builds the osquery client described by the osquery
+// block of cfg, whichever backend is selected, so the configure command
+// writes the same osquery config the daemon runs with.
+func NewOsqueryFromConfig(cfg *config.Config, log *logger.Logger) (*OsQueryFIMClient, error) {
+	opts := []Options{
+		WithLogger(log),
+		WithWatches(watchesFromConfig(cfg)),
+		WithMaxRetries(cfg.Osquery.MaxRetries),
+		WithFileAccesses(cfg.Osquery.FileAccesses),
+		WithEventOptions(cfg.Osquery.EventsExpiry, cfg.Osquery.EventsMax),
+	}
+	for category, patterns := range cfg.Osquery.FilePaths {
+		opts = append(opts, WithFilePaths(category, patterns))
+	}
+	for category, patterns := range cfg.Osquery.ExcludePaths {
+		opts = append(opts, WithExcludePaths(category, patterns))
+	}
+	if cfg.CheckFrequency >= time.Second {
+		opts = append(opts, WithScheduleInterval(cfg.CheckFrequency))
+	}
+	if sup := cfg.Osquery.Supervisor; sup.InitialBackoff > 0 {
+		opts = append(opts, WithRestartPolicy(RestartPolicy{
+			InitialBackoff: sup.InitialBackoff,
+			MaxBackoff:     sup.MaxBackoff,
+			Multiplier:     sup.Multiplier,
+			Jitter:         sup.Jitter,
+			MaxCrashes:     sup.MaxCrashes,
+			CrashWindow:    sup.CrashWindow,
+		}))
+	}
+	// Unset paths keep the client's defaults.
+	if cfg.Osquery.Binary != "" {
+		opts = append(opts, WithOsqueryBinary(cfg.Osquery.Binary))
+	}
+	if cfg.Osquery.DaemonBinary != "" {
+		opts = append(opts, WithOsquerydBinary(cfg.Osquery.DaemonBinary))
+	}
+	if cfg.Osquery.DatabasePath != "" {
+		opts = append(opts, WithDatabasePath(cfg.Osquery.DatabasePath))
+	}
+
+	switch cfg.Osquery.Mode {
+	case "", "osqueryi":
+	case "socket":
+		opts = append(opts, WithExtensionSocket(cfg.OsquerySocket))
+		if cfg.Osquery.SocketTimeout > 0 {
+			opts = append(opts, WithSocketTimeout(cfg.Osquery.SocketTimeout))
+		}
+	case "managed":
+		opts = append(opts, WithManagedDaemon(cfg.Osquery.LoggerPath))
+	default:
+		return nil, fmt.Errorf("unknown osquery mode %q", cfg.Osquery.Mode)
+	}
+
+	return New(cfg.OsqueryConfig, opts...)
+}
+
+func newNativeBackend(cfg *config.Config, log *logger.Logger) (Monitor, error) {
+	return NewNative(watchesFromConfig(cfg), cfg.Native.HashFiles, log)
+}
+
+func newPollingBackend(cfg *config.Config, log *logger.Logger) (Monitor, error) {
+	return NewPolling(watchesFromConfig(cfg), cfg.CheckFrequency, cfg.Polling.HashFiles, log)
+}
+
+func watchesFromConfig(cfg *config.Config) []Watch {
+	var watches []Watch
+	for _, watch := range cfg.WatchList() {
+		watches = append(watches, Watch{
+			Path:      watch.Path,
+			Recursive: watch.Recursive,
+			Label:     watch.Label,
+			Severity:  watch.Severity,
+			Hash:      watch.Hash,
+		})
+	}
+	return watches
+}
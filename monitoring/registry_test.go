@@ -0,0 +1,97 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	cfg := &config.Config{
+		MonitoredDirectory: t.TempDir(),
+		CheckFrequency:     time.Minute,
+		OsqueryConfig:      "/tmp/test_config.json",
+		OsquerySocket:      "/tmp/osquery.em",
+	}
+
+	cfg.MonitorBackend = "polling"
+	monitor, err := NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	assert.IsType(t, &PollingMonitor{}, monitor)
+	assert.Equal(t, time.Minute, monitor.(*PollingMonitor).interval)
+
+	cfg.Ignore.Patterns = []string{"node_modules/"}
+	monitor, err = NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	assert.IsType(t, &PollingMonitor{}, monitor.(*FilteredMonitor).Unwrap())
+	cfg.Coalesce.Window = 2 * time.Second
+	monitor, err = NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	assert.IsType(t, &FilteredMonitor{}, monitor.(*CoalescingMonitor).Unwrap())
+	cfg.Coalesce.Window = 0
+
+	cfg.Store.Path = t.TempDir()
+	monitor, err = NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	stored := monitor.(*StoredMonitor)
+	assert.IsType(t, &FilteredMonitor{}, stored.Unwrap())
+	_, ok := CollectorOf(NewCoalescing(stored, time.Second))
+	assert.True(t, ok)
+	require.NoError(t, stored.Close())
+	cfg.Store.Path = ""
+	cfg.Ignore.Patterns = []string{"[z-a]"}
+	_, err = NewFromConfig(cfg, mockLogger)
+	assert.ErrorContains(t, err, "invalid ignore pattern")
+	cfg.Ignore.Patterns = nil
+
+	cfg.MonitorBackend = "osquery"
+	cfg.Osquery.Mode = "socket"
+	monitor, err = NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/osquery.em", monitor.(*OsQueryFIMClient).socketPath)
+	assert.Equal(t, "osqueryi", monitor.(*OsQueryFIMClient).osqueryBinary)
+
+	cfg.Watches = []config.Watch{{Path: "/var/www", Recursive: true, Label: "www", Severity: "high"}}
+	monitor, err = NewFromConfig(cfg, mockLogger)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"www": {"/var/www/%%"}}, monitor.(*OsQueryFIMClient).osqueryConfig().FilePaths)
+	cfg.Watches = nil
+
+	cfg.Osquery.Mode = "bogus"
+	_, err = NewFromConfig(cfg, mockLogger)
+	assert.Error(t, err)
+
+	cfg.MonitorBackend = "bogus"
+	_, err = NewFromConfig(cfg, mockLogger)
+	assert.ErrorContains(t, err, `unknown monitor backend "bogus"`)
+}
+
+func TestRegister(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	var built bool
+	Register("test", func(cfg *config.Config, log *logger.Logger) (Monitor, error) {
+		built = true
+		return NewPolling(DirWatches([]string{cfg.MonitoredDirectory}), time.Second, false, log)
+	})
+	defer func() {
+		registryMutex.Lock()
+		delete(registry, "test")
+		registryMutex.Unlock()
+	}()
+
+	assert.Contains(t, Backends(), "test")
+
+	_, err = NewFromConfig(&config.Config{MonitorBackend: "test"}, mockLogger)
+	assert.NoError(t, err)
+	assert.True(t, built)
+}
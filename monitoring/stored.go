@@ -0,0 +1,242 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/metrics"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+// maxEventsPerRead bounds how many stored events EventsSince returns at once.
+const maxEventsPerRead = 10000
+
+type (
+	// Collector is implemented by monitors that persist events and pull new
+	// ones from their backend when asked to.
+	Collector interface {
+		Collect(ctx context.Context) (int, error)
+	}
+
+	// StoredMonitor persists the events of another Monitor in an event store
+	// and serves every query from the store, so history outlives the
+	// backend's own retention and tracker restarts. Events reach the store
+	// when Collect is called, read from the wrapped EventSource with a
+	// cursor that is saved along with them. Served events carry the store's
+	// sequence number as their ID.
+	StoredMonitor struct {
+		Monitor
+		store *store.Store
+		mutex sync.Mutex
+
+		updatedMutex sync.Mutex
+		updated      chan struct{}
+	}
+)
+
+var (
+	_ Monitor      = (*StoredMonitor)(nil)
+	_ Collector    = (*StoredMonitor)(nil)
+	_ EventSource  = (*StoredMonitor)(nil)
+	_ Notifier     = (*StoredMonitor)(nil)
+	_ EventScanner = (*StoredMonitor)(nil)
+)
+
+func NewStored(monitor Monitor, s *store.Store) *StoredMonitor {
+	return &StoredMonitor{Monitor: monitor, store: s}
+}
+
+// CollectorOf returns the first monitor in the chain wrapped by m that
+// collects events.
+func CollectorOf(m Monitor) (Collector, bool) {
+	for m != nil {
+		if collector, ok := m.(Collector); ok {
+			return collector, true
+		}
+		wrapper, ok := m.(interface{ Unwrap() Monitor })
+		if !ok {
+			break
+		}
+		m = wrapper.Unwrap()
+	}
+	return nil, false
+}
+
+// Unwrap returns the monitor whose events are stored.
+func (s *StoredMonitor) Unwrap() Monitor {
+	return s.Monitor
+}
+
+// Collect stores the backend's events since the last collection and returns
+// how many were added.
+func (s *StoredMonitor) Collect(ctx context.Context) (int, error) {
+	source, ok := s.Monitor.(EventSource)
+	if !ok {
+		return 0, ErrCursorUnsupported
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var cursor Cursor
+	if saved := s.store.Cursor(); saved != nil {
+		if err := json.Unmarshal(saved, &cursor); err != nil {
+			return 0, fmt.Errorf("failed to decode event cursor: %w", err)
+		}
+	}
+
+	events, next, err := source.EventsSince(ctx, cursor)
+	if err != nil {
+		return 0, err
+	}
+	if next.equal(cursor) {
+		return 0, nil
+	}
+
+	records := make([]store.Record, 0, len(events))
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		records = append(records, store.Record{Time: event.Time, Key: event.TargetPath, Data: data})
+	}
+	data, err := json.Marshal(next)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.store.Commit(records, data); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for _, event := range events {
+		metrics.EventCollected(string(event.Action), event.Category, now.Sub(event.Time))
+	}
+	if len(records) > 0 {
+		s.notify()
+	}
+	return len(records), nil
+}
+
+// EventsSince returns stored events after cursor, which counts in store
+// sequence numbers like the IDs of served events.
+func (s *StoredMonitor) EventsSince(ctx context.Context, cursor Cursor) ([]FileEvent, Cursor, error) {
+	if cursor.Stream != storeStream {
+		cursor = Cursor{Stream: storeStream}
+	}
+	next := cursor
+	var events []FileEvent
+	err := s.store.Scan(store.Filter{AfterSeq: cursor.Seq}, func(r store.Record) bool {
+		if event, ok := decodeRecord(r); ok {
+			events = append(events, event)
+		}
+		next.Seq, next.Time = r.Seq, r.Time
+		return len(events) < maxEventsPerRead
+	})
+	if err != nil {
+		return nil, cursor, err
+	}
+	return events, next, nil
+}
+
+func (s *StoredMonitor) Head(ctx context.Context) (Cursor, error) {
+	stats := s.store.Stats()
+	return Cursor{Stream: storeStream, Seq: stats.LastSeq, Time: stats.Newest}, nil
+}
+
+// Updated returns a channel that is closed when Collect next stores events.
+func (s *StoredMonitor) Updated() <-chan struct{} {
+	s.updatedMutex.Lock()
+	defer s.updatedMutex.Unlock()
+
+	if s.updated == nil {
+		s.updated = make(chan struct{})
+	}
+	return s.updated
+}
+
+func (s *StoredMonitor) notify() {
+	s.updatedMutex.Lock()
+	defer s.updatedMutex.Unlock()
+
+	if s.updated != nil {
+		close(s.updated)
+		s.updated = nil
+	}
+}
+
+func (s *StoredMonitor) GetFileEvents(ctx context.Context) ([]FileEvent, error) {
+	return s.events(store.Filter{}, "")
+}
+
+func (s *StoredMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]FileEvent, error) {
+	return s.events(store.Filter{After: since, KeyPrefix: path}, path)
+}
+
+func (s *StoredMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]ActionSummary, error) {
+	events, err := s.events(store.Filter{After: since}, "")
+	if err != nil {
+		return nil, err
+	}
+	return summarize(events, since), nil
+}
+
+// ScanEvents reads the stored events within bounds, skipping those for other
+// paths before decoding them and segments outside the time bounds unread.
+func (s *StoredMonitor) ScanEvents(ctx context.Context, bounds *ScanBounds, fn func(FileEvent) bool) error {
+	filter := store.Filter{After: bounds.Since, Until: bounds.Until, KeyPrefix: bounds.Path, Reverse: bounds.Descending}
+	err := s.store.ScanNarrowing(&filter, func(r store.Record) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		event, ok := decodeRecord(r)
+		if !ok || !strings.HasPrefix(event.TargetPath, bounds.Path) {
+			return true
+		}
+		if !fn(event) {
+			return false
+		}
+		filter.After, filter.Until = bounds.Since, bounds.Until
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (s *StoredMonitor) events(filter store.Filter, path string) ([]FileEvent, error) {
+	var events []FileEvent
+	err := s.store.Scan(filter, func(r store.Record) bool {
+		if event, ok := decodeRecord(r); ok && strings.HasPrefix(event.TargetPath, path) {
+			events = append(events, event)
+		}
+		return true
+	})
+	return events, err
+}
+
+// decodeRecord reads a stored event, giving it the record's sequence number
+// as its ID.
+func decodeRecord(r store.Record) (FileEvent, bool) {
+	var event FileEvent
+	if json.Unmarshal(r.Data, &event) != nil {
+		return event, false
+	}
+	event.ID = strconv.FormatUint(r.Seq, 10)
+	return event, true
+}
+
+// Close closes the backend and then the store.
+func (s *StoredMonitor) Close() error {
+	err := s.Monitor.Close()
+	if storeErr := s.store.Close(); err == nil {
+		err = storeErr
+	}
+	return err
+}
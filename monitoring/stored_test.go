@@ -0,0 +1,192 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+func TestStoredMonitor(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	polling, err := NewPolling(DirWatches([]string{t.TempDir() + "/%%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	polling.events.add(rawEvent("", "/home/alice/a.txt", ActionCreated, 0, start.Unix()))
+	polling.events.add(rawEvent("", "/home/alice/b.txt", ActionUpdated, 0, start.Unix()+10))
+
+	s, err := store.Open(dir, store.WithCompactInterval(0))
+	require.NoError(t, err)
+	monitor := NewStored(polling, s)
+
+	added, err := monitor.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	// The backend still holds the same events; nothing is stored twice.
+	polling.events.add(rawEvent("", "/home/alice/c.txt", ActionDeleted, 0, start.Unix()+20))
+	added, err = monitor.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	events, err := monitor.GetFileEvents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, []string{"1", "2", "3"}, []string{events[0].ID, events[1].ID, events[2].ID})
+	assert.Equal(t, "/home/alice/c.txt", events[2].TargetPath)
+	assert.True(t, events[2].Time.Equal(time.Unix(start.Unix()+20, 0)))
+
+	events, err = monitor.GetFileEventsByPath(context.Background(), "/home/alice/b", start)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, ActionUpdated, events[0].Action)
+
+	summary, err := monitor.GetFileChangesSummary(context.Background(), start.Add(5*time.Second))
+	require.NoError(t, err)
+	assert.Len(t, summary, 2)
+	require.NoError(t, monitor.Close())
+
+	// After a restart the backend may report the same events again, for
+	// example osquery still holding them, and they are not stored twice.
+	s, err = store.Open(dir, store.WithCompactInterval(0))
+	require.NoError(t, err)
+	restarted := NewStored(polling, s)
+	defer restarted.Close()
+
+	added, err = restarted.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, added)
+
+	polling.events.add(rawEvent("", "/home/alice/d.txt", ActionCreated, 0, start.Unix()+30))
+	added, err = restarted.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	events, err = restarted.GetFileEvents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	assert.Equal(t, "4", events[3].ID)
+}
+
+func TestStoredMonitorEventsSince(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	polling, err := NewPolling(DirWatches([]string{t.TempDir() + "/%%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	polling.events.add(rawEvent("", "/home/alice/a.txt", ActionCreated, 0, 100))
+
+	s, err := store.Open(t.TempDir(), store.WithCompactInterval(0))
+	require.NoError(t, err)
+	monitor := NewStored(polling, s)
+	defer monitor.Close()
+
+	_, err = monitor.Collect(context.Background())
+	require.NoError(t, err)
+	head, err := monitor.Head(context.Background())
+	require.NoError(t, err)
+	assert.True(t, head.equal(Cursor{Stream: storeStream, Seq: 1, Time: time.Unix(100, 0)}))
+
+	// Collect wakes whoever waits for new events, but only when it stored some.
+	updated := monitor.Updated()
+	_, err = monitor.Collect(context.Background())
+	require.NoError(t, err)
+	select {
+	case <-updated:
+		t.Fatal("notified without new events")
+	default:
+	}
+	polling.events.add(rawEvent("", "/home/alice/b.txt", ActionUpdated, 0, 101))
+	_, err = monitor.Collect(context.Background())
+	require.NoError(t, err)
+	select {
+	case <-updated:
+	default:
+		t.Fatal("not notified of new events")
+	}
+
+	events, next, err := monitor.EventsSince(context.Background(), head)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, ids(events))
+	assert.True(t, head.Before(next))
+
+	// A cursor of another stream starts from the oldest stored event.
+	events, _, err = monitor.EventsSince(context.Background(), Cursor{Stream: "other", Seq: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids(events))
+}
+
+func TestStoredMonitorQuery(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	polling, err := NewPolling(DirWatches([]string{t.TempDir() + "/%%"}), time.Hour, false, mockLogger)
+	require.NoError(t, err)
+	// Pairs of events share a second, a few of them for the same path so they
+	// coalesce.
+	for i := 0; i < 60; i++ {
+		path := fmt.Sprintf("/srv/%d", i/2%4)
+		if i%3 == 0 {
+			path = fmt.Sprintf("/etc/%d", i%5)
+		}
+		polling.events.add(rawEvent("", path, ActionUpdated, 0, 1000+int64(i/2)*3))
+	}
+
+	dir := t.TempDir()
+	s, err := store.Open(dir, store.WithSegmentSize(1024), store.WithCompactInterval(0))
+	require.NoError(t, err)
+	stored := NewStored(polling, s)
+	defer stored.Close()
+	_, err = stored.Collect(context.Background())
+	require.NoError(t, err)
+	logical, err := NewCoalescing(stored, 2*time.Second).GetFileEvents(context.Background())
+	require.NoError(t, err)
+	require.Less(t, len(logical), 60)
+
+	// Scanned pages are the pages of the events read in full.
+	for _, monitor := range []Monitor{stored, NewCoalescing(stored, 2*time.Second)} {
+		for _, query := range []EventQuery{
+			{Limit: 7},
+			{Limit: 4, Order: OrderDescending},
+			{Path: "/srv/", Since: time.Unix(1020, 0), Limit: 3},
+			{Path: "/srv/[12]", Until: time.Unix(1070, 0), Order: OrderDescending, Limit: 2},
+		} {
+			for {
+				page, err := QueryEvents(context.Background(), monitor, query)
+				require.NoError(t, err)
+				events, err := fetchEvents(context.Background(), monitor, query)
+				require.NoError(t, err)
+				expected, err := query.Page(events)
+				require.NoError(t, err)
+				require.Equal(t, ids(expected.Events), ids(page.Events), "%+v", query)
+				require.Equal(t, expected.NextCursor, page.NextCursor)
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+		}
+	}
+
+	// A full first page is found without reading the newest segment.
+	segments, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	require.NoError(t, err)
+	require.Greater(t, len(segments), 2)
+	require.NoError(t, os.Remove(segments[len(segments)-1]))
+	page, err := QueryEvents(context.Background(), NewCoalescing(stored, 2*time.Second), EventQuery{Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, page.Events, 5)
+	_, err = QueryEvents(context.Background(), stored, EventQuery{Limit: 5, Order: OrderDescending})
+	assert.Error(t, err)
+}
@@ -0,0 +1,324 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+const (
+	StateStarting   SupervisorState = "starting"
+	StateRunning    SupervisorState = "running"
+	StateRestarting SupervisorState = "restarting"
+	StateFailed     SupervisorState = "failed"
+	StateStopped    SupervisorState = "stopped"
+)
+
+type (
+	SupervisorState string
+
+	// SupervisorStatus describes the health of a supervised osquery process.
+	SupervisorStatus struct {
+		State        SupervisorState `json:"state"`
+		Restarts     int             `json:"restarts"`
+		LastExitCode int             `json:"last_exit_code"`
+		LastExitTime time.Time       `json:"last_exit_time,omitempty"`
+		LastError    string          `json:"last_error,omitempty"`
+	}
+
+	// StatusReporter is implemented by monitors that run a subprocess, so the
+	// daemon can notice when file monitoring has stopped working.
+	StatusReporter interface {
+		Status() SupervisorStatus
+	}
+
+	// RestartPolicy controls how a crashed process is restarted. Backoff grows
+	// from InitialBackoff by Multiplier up to MaxBackoff, each delay randomised
+	// by up to Jitter (a fraction of the delay). After MaxCrashes crashes within
+	// CrashWindow the supervisor gives up and reports StateFailed.
+	RestartPolicy struct {
+		InitialBackoff time.Duration
+		MaxBackoff     time.Duration
+		Multiplier     float64
+		Jitter         float64
+		MaxCrashes     int
+		CrashWindow    time.Duration
+	}
+
+	// process is a started command. readers, when set, finishes once every
+	// goroutine reading the command's pipes has, as exec.Cmd.Wait must only
+	// be called after that.
+	process struct {
+		cmd     *exec.Cmd
+		readers *sync.WaitGroup
+	}
+
+	// supervisor keeps one process running: it waits on it, notices every
+	// exit and spawns a replacement according to its RestartPolicy.
+	supervisor struct {
+		name   string
+		policy RestartPolicy
+		spawn  func(ctx context.Context) (process, error)
+		log    *logger.Logger
+		jitter func() float64
+
+		mutex      sync.Mutex
+		status     SupervisorStatus
+		cmd        *exec.Cmd
+		startedAt  time.Time
+		expectExit bool
+		crashes    []time.Time
+		cancel     context.CancelFunc
+		done       chan struct{}
+	}
+)
+
+// StatusOf returns the status of m, looking through monitors that wrap
+// another one. It returns false if no monitor in the chain reports a status.
+func StatusOf(m Monitor) (SupervisorStatus, bool) {
+	for m != nil {
+		if reporter, ok := m.(StatusReporter); ok {
+			return reporter.Status(), true
+		}
+		wrapper, ok := m.(interface{ Unwrap() Monitor })
+		if !ok {
+			break
+		}
+		m = wrapper.Unwrap()
+	}
+	return SupervisorStatus{}, false
+}
+
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+		MaxCrashes:     5,
+		CrashWindow:    10 * time.Minute,
+	}
+}
+
+func newSupervisor(name string, policy RestartPolicy, spawn func(ctx context.Context) (process, error), log *logger.Logger) *supervisor {
+	return &supervisor{
+		name:   name,
+		policy: policy,
+		spawn:  spawn,
+		log:    log,
+		jitter: rand.Float64,
+		status: SupervisorStatus{State: StateStopped},
+	}
+}
+
+// start spawns the first process synchronously, so configuration errors are
+// reported to the caller, and supervises it in the background.
+func (s *supervisor) start(ctx context.Context) error {
+	s.setState(StateStarting)
+
+	proc, err := s.spawn(ctx)
+	if err != nil {
+		s.mutex.Lock()
+		s.status.State = StateFailed
+		s.status.LastError = err.Error()
+		s.mutex.Unlock()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.mutex.Lock()
+	s.cmd = proc.cmd
+	s.startedAt = time.Now()
+	s.status.State = StateRunning
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.mutex.Unlock()
+
+	go s.run(ctx, proc)
+	return nil
+}
+
+func (s *supervisor) run(ctx context.Context, proc process) {
+	defer close(s.done)
+
+	attempt := 0
+	for {
+		waitErr := proc.wait()
+
+		s.mutex.Lock()
+		expected := s.expectExit
+		s.expectExit = false
+		ranFor := time.Since(s.startedAt)
+		code := exitCode(proc.cmd, waitErr)
+		s.status.LastExitCode = code
+		s.status.LastExitTime = time.Now()
+		s.mutex.Unlock()
+
+		if ctx.Err() != nil {
+			s.setState(StateStopped)
+			return
+		}
+
+		if expected {
+			s.log.Info(fmt.Sprintf("%s exited for a requested restart", s.name))
+			attempt = 0
+		} else {
+			s.log.Error(fmt.Sprintf("%s exited unexpectedly", s.name), "exit_code", code, "error", waitErr)
+			if ranFor > s.policy.CrashWindow {
+				attempt = 0
+			}
+			if s.recordCrash(waitErr) {
+				return
+			}
+		}
+
+		for {
+			if !expected || attempt > 0 {
+				s.setState(StateRestarting)
+				backoff := s.backoff(attempt)
+				s.log.Warn(fmt.Sprintf("Restarting %s", s.name), "backoff", backoff, "attempt", attempt+1)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					s.setState(StateStopped)
+					return
+				}
+			}
+			attempt++
+
+			next, err := s.spawn(ctx)
+			if err == nil {
+				s.mutex.Lock()
+				s.cmd = next.cmd
+				s.startedAt = time.Now()
+				s.status.State = StateRunning
+				s.status.Restarts++
+				restarts := s.status.Restarts
+				s.mutex.Unlock()
+				s.log.Info(fmt.Sprintf("%s restarted", s.name), "restarts", restarts)
+				proc = next
+				break
+			}
+			if ctx.Err() != nil {
+				s.setState(StateStopped)
+				return
+			}
+			s.log.Error(fmt.Sprintf("Failed to restart %s", s.name), "error", err)
+			if s.recordCrash(err) {
+				return
+			}
+		}
+	}
+}
+
+// recordCrash counts a crash and opens the circuit breaker, returning true,
+// once too many crashes happened within the crash window.
+func (s *supervisor) recordCrash(err error) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	crashes := s.crashes[:0]
+	for _, crash := range s.crashes {
+		if now.Sub(crash) <= s.policy.CrashWindow {
+			crashes = append(crashes, crash)
+		}
+	}
+	s.crashes = append(crashes, now)
+
+	if s.policy.MaxCrashes > 0 && len(s.crashes) >= s.policy.MaxCrashes {
+		s.status.State = StateFailed
+		s.log.Error(fmt.Sprintf("%s crashed too often, giving up", s.name),
+			"crashes", len(s.crashes), "window", s.policy.CrashWindow)
+		return true
+	}
+	return false
+}
+
+func (s *supervisor) backoff(attempt int) time.Duration {
+	backoff := float64(s.policy.InitialBackoff) * math.Pow(s.policy.Multiplier, float64(attempt))
+	if s.policy.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(s.policy.MaxBackoff))
+	}
+	if s.policy.Jitter > 0 {
+		backoff += backoff * s.policy.Jitter * (2*s.jitter() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+// restart kills the current process and lets the supervisor replace it
+// without counting the exit as a crash.
+func (s *supervisor) restart() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.cmd == nil || s.cmd.Process == nil || s.status.State != StateRunning {
+		return fmt.Errorf("%s is not running", s.name)
+	}
+	s.expectExit = true
+	return s.cmd.Process.Kill()
+}
+
+// stop kills the process and waits for the supervisor to exit.
+func (s *supervisor) stop() error {
+	s.mutex.Lock()
+	cancel, done, cmd := s.cancel, s.done, s.cmd
+	s.mutex.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	var err error
+	if cmd != nil && cmd.Process != nil {
+		if killErr := cmd.Process.Kill(); killErr != nil && !errors.Is(killErr, os.ErrProcessDone) {
+			err = killErr
+		}
+	}
+	<-done
+	s.setState(StateStopped)
+	return err
+}
+
+func (s *supervisor) Status() SupervisorStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
+
+func (s *supervisor) setState(state SupervisorState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status.State = state
+}
+
+// wait waits for the pipe readers to finish and then for the command.
+func (p process) wait() error {
+	if p.readers != nil {
+		p.readers.Wait()
+	}
+	return p.cmd.Wait()
+}
+
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
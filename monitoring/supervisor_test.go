@@ -0,0 +1,131 @@
+//go:build linux || darwin
+
+package monitoring
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+func testRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+		MaxCrashes:     3,
+		CrashWindow:    time.Minute,
+	}
+}
+
+func spawnCommand(name string, args ...string) func(ctx context.Context) (process, error) {
+	return func(ctx context.Context) (process, error) {
+		cmd := exec.Command(name, args...)
+		return process{cmd: cmd}, cmd.Start()
+	}
+}
+
+func waitForState(t *testing.T, s *supervisor, state SupervisorState) SupervisorStatus {
+	t.Helper()
+	var status SupervisorStatus
+	assert.Eventually(t, func() bool {
+		status = s.Status()
+		return status.State == state
+	}, 5*time.Second, 5*time.Millisecond, "supervisor never reached %s", state)
+	return status
+}
+
+func TestSupervisorCrashLoop(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	s := newSupervisor("crasher", testRestartPolicy(), spawnCommand("sh", "-c", "exit 3"), mockLogger)
+	require.NoError(t, s.start(context.Background()))
+
+	status := waitForState(t, s, StateFailed)
+	assert.Equal(t, 3, status.LastExitCode)
+	assert.Equal(t, 2, status.Restarts)
+	assert.False(t, status.LastExitTime.IsZero())
+	assert.NoError(t, s.stop())
+}
+
+func TestSupervisorRestart(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	policy := testRestartPolicy()
+	policy.MaxCrashes = 1
+	s := newSupervisor("sleeper", policy, spawnCommand("sleep", "60"), mockLogger)
+	require.NoError(t, s.start(context.Background()))
+	assert.Equal(t, StateRunning, s.Status().State)
+
+	// Requested restarts are not crashes, so even a breaker that opens on
+	// the first crash stays closed.
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.restart())
+		assert.Eventually(t, func() bool {
+			status := s.Status()
+			return status.State == StateRunning && status.Restarts == i
+		}, 5*time.Second, 5*time.Millisecond)
+	}
+	assert.Equal(t, -1, s.Status().LastExitCode)
+
+	assert.NoError(t, s.stop())
+	assert.Equal(t, StateStopped, s.Status().State)
+	assert.Error(t, s.restart())
+}
+
+func TestSupervisorSpawnFailure(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	s := newSupervisor("missing", testRestartPolicy(), spawnCommand("/nonexistent/osqueryi"), mockLogger)
+	assert.Error(t, s.start(context.Background()))
+	assert.Equal(t, StateFailed, s.Status().State)
+	assert.NotEmpty(t, s.Status().LastError)
+	assert.NoError(t, s.stop())
+}
+
+func TestSupervisorBackoff(t *testing.T) {
+	s := &supervisor{
+		policy: RestartPolicy{
+			InitialBackoff: time.Second,
+			MaxBackoff:     5 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.5,
+		},
+		jitter: func() float64 { return 0.5 },
+	}
+
+	assert.Equal(t, time.Second, s.backoff(0))
+	assert.Equal(t, 2*time.Second, s.backoff(1))
+	assert.Equal(t, 4*time.Second, s.backoff(2))
+	assert.Equal(t, 5*time.Second, s.backoff(3))
+
+	s.jitter = func() float64 { return 0 }
+	assert.Equal(t, 500*time.Millisecond, s.backoff(0))
+	s.jitter = func() float64 { return 1 }
+	assert.Equal(t, 1500*time.Millisecond, s.backoff(0))
+}
+
+func TestStartOsqueryiExits(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	client, err := New(t.TempDir()+"/osquery.conf",
+		WithLogger(mockLogger),
+		WithOsqueryBinary("false"),
+		WithDatabasePath(t.TempDir()+"/osquery.db"),
+	)
+	require.NoError(t, err)
+
+	err = client.Start(context.Background())
+	assert.ErrorContains(t, err, "exited during startup")
+	assert.Equal(t, StateStopped, client.Status().State)
+}
@@ -0,0 +1,61 @@
+package monitoring
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// defaultSeverity is reported for events of watches that set none.
+const defaultSeverity = "info"
+
+// Watch is one monitored location. Path is a directory, optionally containing
+// shell ("*") or osquery ("%") wildcards. A Path that already ends in osquery's
+// "%%" or "%" keeps the recursion that suffix implies and ignores Recursive.
+type Watch struct {
+	Path      string
+	Recursive bool
+	// Label becomes the category of every event under Path and names the
+	// osquery file_paths category. Watches may share a label.
+	Label    string
+	Severity string
+	// Hash overrides the backend's hash_files setting for this watch. The
+	// osquery backend leaves hashing to osquery and ignores it.
+	Hash *bool
+}
+
+// DirWatches wraps osquery file_paths patterns, such as monitored_directory,
+// into watches with the default label and severity.
+func DirWatches(patterns []string) []Watch {
+	watches := make([]Watch, 0, len(patterns))
+	for _, pattern := range patterns {
+		watches = append(watches, Watch{Path: pattern})
+	}
+	return watches
+}
+
+func (w Watch) label() string {
+	if w.Label == "" {
+		return defaultCategory
+	}
+	return w.Label
+}
+
+func (w Watch) severity() string {
+	if w.Severity == "" {
+		return defaultSeverity
+	}
+	return w.Severity
+}
+
+// pattern returns the watch as an osquery file_paths pattern.
+func (w Watch) pattern() string {
+	pattern := strings.ReplaceAll(w.Path, "*", "%")
+	if strings.HasSuffix(w.Path, "%") {
+		return pattern
+	}
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if w.Recursive {
+		return pattern + "/%%"
+	}
+	return pattern + "/%"
+}
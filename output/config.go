@@ -0,0 +1,256 @@
+package output
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// deadLetterDir is where an output's dead letters are kept, next to its
+// cursor.
+const deadLetterDir = "dead_letter"
+
+// NewFromConfig builds a forwarder for every configured output, reading
+// events from monitor and, for outputs that forward alerts, alerts from
+// alerts, which is nil without alert rules.
+func NewFromConfig(cfg *config.Config, monitor monitoring.Monitor, alerts *alert.Store, log *logger.Logger) ([]*Forwarder, error) {
+	if len(cfg.Outputs) == 0 {
+		return nil, nil
+	}
+	source, ok := monitoring.EventSourceOf(monitor)
+	if !ok {
+		return nil, fmt.Errorf("outputs need a monitor that delivers events incrementally: %w", monitoring.ErrCursorUnsupported)
+	}
+
+	forwarders := make([]*Forwarder, 0, len(cfg.Outputs))
+	for _, out := range cfg.Outputs {
+		forwarder, err := newForwarder(cfg, out, source, alerts, log)
+		if err != nil {
+			for _, f := range forwarders {
+				_ = f.Close()
+			}
+			return nil, err
+		}
+		forwarders = append(forwarders, forwarder)
+	}
+	return forwarders, nil
+}
+
+func newForwarder(cfg *config.Config, out config.Output, source monitoring.EventSource, alerts *alert.Store, log *logger.Logger) (*Forwarder, error) {
+	opts := []ForwarderOption{
+		WithBatchSize(out.BatchSize),
+		WithFlushInterval(out.FlushInterval),
+	}
+	if out.Alerts {
+		if alerts == nil {
+			return nil, fmt.Errorf("output %s: forwarding alerts needs alert rules", out.Name)
+		}
+		if err := checkAlertFormat(cfg, out); err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithAlerts(alerts))
+	}
+	sink, err := newSink(cfg, out, log)
+	if err != nil {
+		return nil, err
+	}
+	return NewForwarder(out.Name, source, sink, StateDir(cfg, out.Name), log, opts...), nil
+}
+
+// checkAlertFormat fails if the format of out cannot encode alerts.
+func checkAlertFormat(cfg *config.Config, out config.Output) error {
+	if out.Format == "" {
+		return nil
+	}
+	formatter, err := newFormatter(cfg, out)
+	if err != nil {
+		return err
+	}
+	if _, err := alertFormatter(formatter); err != nil {
+		return fmt.Errorf("output %s: %w", out.Name, err)
+	}
+	return nil
+}
+
+// StateDir returns the directory holding the state of the output named name.
+func StateDir(cfg *config.Config, name string) string {
+	return filepath.Join(cfg.OutputDir, filepath.Base(name))
+}
+
+func newSink(cfg *config.Config, out config.Output, log *logger.Logger) (Sink, error) {
+	switch out.Type {
+	case "webhook":
+		return NewWebhookFromConfig(cfg, out, log)
+	case "syslog":
+		return NewSyslogFromConfig(cfg, out, log)
+	case "file":
+		return NewFileFromConfig(cfg, out)
+	default:
+		return nil, fmt.Errorf("output %s: unknown type %q", out.Name, out.Type)
+	}
+}
+
+// NewWebhookFromConfig builds the webhook of a "webhook" output, with its
+// dead-letter queue.
+func NewWebhookFromConfig(cfg *config.Config, out config.Output, log *logger.Logger) (*Webhook, error) {
+	if out.Type != "webhook" {
+		return nil, fmt.Errorf("output %s is not a webhook", out.Name)
+	}
+	u, err := url.Parse(out.Webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("output %s: webhook url %q must be an http or https URL", out.Name, out.Webhook.URL)
+	}
+	if out.Webhook.Secret == "" {
+		return nil, fmt.Errorf("output %s: webhook secret is required to sign requests", out.Name)
+	}
+
+	deadLetter, err := OpenDeadLetterQueue(filepath.Join(StateDir(cfg, out.Name), deadLetterDir))
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", out.Name, err)
+	}
+
+	policy := DefaultRetryPolicy()
+	if out.Webhook.MaxRetries != nil {
+		policy.MaxRetries = *out.Webhook.MaxRetries
+	}
+	if out.Webhook.InitialBackoff > 0 {
+		policy.InitialBackoff = out.Webhook.InitialBackoff
+	}
+	if out.Webhook.MaxBackoff > 0 {
+		policy.MaxBackoff = out.Webhook.MaxBackoff
+	}
+	opts := []WebhookOption{
+		WithHeaders(out.Webhook.Headers),
+		WithTimeout(out.Webhook.Timeout),
+		WithRetryPolicy(policy),
+	}
+	// JSON batches keep the {"events": [...]} envelope.
+	if out.Format != "" && out.Format != "json" {
+		formatter, err := newFormatter(cfg, out)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithWebhookFormatter(formatter))
+	}
+	return NewWebhook(out.Name, out.Webhook.URL, out.Webhook.Secret, deadLetter, log, opts...), nil
+}
+
+// NewSyslogFromConfig builds the sink of a "syslog" output.
+func NewSyslogFromConfig(cfg *config.Config, out config.Output, log *logger.Logger) (*Syslog, error) {
+	opts := []SyslogOption{
+		WithAppName(out.Syslog.AppName),
+		WithHostname(out.Syslog.Hostname),
+		WithDialTimeout(out.Syslog.Timeout),
+	}
+	if out.Syslog.Address == "" {
+		return nil, fmt.Errorf("output %s: syslog address is required", out.Name)
+	}
+	if out.Format != "" {
+		formatter, err := newFormatter(cfg, out)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithSyslogFormatter(formatter))
+	}
+	if out.Syslog.Facility != "" {
+		facility, err := Facility(out.Syslog.Facility)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		opts = append(opts, WithFacility(facility))
+	}
+
+	policy := DefaultRetryPolicy()
+	if out.Syslog.InitialBackoff > 0 {
+		policy.InitialBackoff = out.Syslog.InitialBackoff
+	}
+	if out.Syslog.MaxBackoff > 0 {
+		policy.MaxBackoff = out.Syslog.MaxBackoff
+	}
+	opts = append(opts, WithReconnectPolicy(policy))
+
+	network := out.Syslog.Network
+	switch network {
+	case "":
+		network = "udp"
+	case "tls":
+		network = "tcp"
+		tlsConfig, err := syslogTLSConfig(out.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", out.Name, err)
+		}
+		opts = append(opts, WithTLS(tlsConfig))
+	}
+
+	s, err := NewSyslog(network, out.Syslog.Address, log, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", out.Name, err)
+	}
+	return s, nil
+}
+
+// NewFileFromConfig builds the sink of a "file" output, which writes JSON
+// unless another format is set.
+func NewFileFromConfig(cfg *config.Config, out config.Output) (*File, error) {
+	if out.File.Path == "" {
+		return nil, fmt.Errorf("output %s: file path is required", out.Name)
+	}
+	if out.Format == "" {
+		out.Format = "json"
+	}
+	formatter, err := newFormatter(cfg, out)
+	if err != nil {
+		return nil, err
+	}
+	return NewFile(out.File.Path, formatter), nil
+}
+
+func newFormatter(cfg *config.Config, out config.Output) (format.Formatter, error) {
+	formatter, err := format.New(out.Format, format.OptionsFromConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", out.Name, err)
+	}
+	return formatter, nil
+}
+
+func syslogTLSConfig(cfg config.SyslogOutput) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address %q: %w", cfg.Address, err)
+		}
+		tlsConfig.ServerName = host
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read syslog CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in syslog CA file %s", cfg.CAFile)
+		}
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load syslog client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
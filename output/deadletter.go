@@ -0,0 +1,110 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const letterExt = ".json"
+
+var ErrLetterNotFound = errors.New("dead letter not found")
+
+type (
+	// Letter is a delivery that failed after every retry. Payload is the
+	// request body as it was sent, so a replay delivers the same batch.
+	// Events or Alerts counts what the batch holds.
+	Letter struct {
+		ID          string    `json:"id"`
+		Output      string    `json:"output"`
+		FailedAt    time.Time `json:"failed_at"`
+		Attempts    int       `json:"attempts"`
+		Error       string    `json:"error"`
+		Events      int       `json:"events"`
+		Alerts      int       `json:"alerts,omitempty"`
+		ContentType string    `json:"content_type"`
+		Payload     string    `json:"payload"`
+	}
+
+	// DeadLetterQueue keeps failed deliveries of one output on disk, one
+	// file per letter, until they are replayed or dropped.
+	DeadLetterQueue struct {
+		dir string
+	}
+)
+
+// OpenDeadLetterQueue opens the queue in dir, creating it if needed.
+func OpenDeadLetterQueue(dir string) (*DeadLetterQueue, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create dead-letter queue: %w", err)
+	}
+	return &DeadLetterQueue{dir: dir}, nil
+}
+
+// Put adds letter to the queue, replacing a letter with the same ID.
+func (q *DeadLetterQueue) Put(letter Letter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+	return writeFile(q.path(letter.ID), data)
+}
+
+// List returns the queued letters, oldest first.
+func (q *DeadLetterQueue) List() ([]Letter, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var letters []Letter
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), letterExt) {
+			continue
+		}
+		letter, err := q.Get(strings.TrimSuffix(entry.Name(), letterExt))
+		if err != nil {
+			if errors.Is(err, ErrLetterNotFound) {
+				// Replayed or dropped meanwhile.
+				continue
+			}
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+	return letters, nil
+}
+
+func (q *DeadLetterQueue) Get(id string) (Letter, error) {
+	var letter Letter
+	data, err := os.ReadFile(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return letter, fmt.Errorf("%w: %s", ErrLetterNotFound, id)
+	}
+	if err != nil {
+		return letter, err
+	}
+	if err := json.Unmarshal(data, &letter); err != nil {
+		return letter, fmt.Errorf("failed to decode dead letter %s: %w", id, err)
+	}
+	return letter, nil
+}
+
+func (q *DeadLetterQueue) Remove(id string) error {
+	err := os.Remove(q.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrLetterNotFound, id)
+	}
+	return err
+}
+
+func (q *DeadLetterQueue) path(id string) string {
+	return filepath.Join(q.dir, filepath.Base(id)+letterExt)
+}
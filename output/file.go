@@ -0,0 +1,77 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// File appends events and alerts to a file, one record per line. The file is opened
+// for every batch, so it can be rotated by renaming it without a restart.
+type File struct {
+	path      string
+	formatter format.Formatter
+}
+
+var _ AlertSink = (*File)(nil)
+
+// NewFile returns a sink that appends events encoded by formatter to path.
+func NewFile(path string, formatter format.Formatter) *File {
+	return &File{path: path, formatter: formatter}
+}
+
+func (f *File) Send(_ context.Context, events []monitoring.FileEvent) error {
+	var buf bytes.Buffer
+	for _, event := range events {
+		record, err := f.formatter.Format(event)
+		if err != nil {
+			return fmt.Errorf("failed to format event %s: %w", event.ID, err)
+		}
+		buf.Write(record)
+		buf.WriteByte('\n')
+	}
+	return f.append(buf.Bytes())
+}
+
+func (f *File) SendAlerts(_ context.Context, alerts []alert.Alert) error {
+	formatter, err := alertFormatter(f.formatter)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, a := range alerts {
+		record, err := formatter.FormatAlert(a)
+		if err != nil {
+			return fmt.Errorf("failed to format alert %s: %w", a.ID, err)
+		}
+		buf.Write(record)
+		buf.WriteByte('\n')
+	}
+	return f.append(buf.Bytes())
+}
+
+// append adds records to the end of the file, creating it if needed.
+func (f *File) append(records []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(records); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *File) Close() error {
+	return nil
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "fim.log")
+	cfg := &config.Config{}
+	f, err := NewFileFromConfig(cfg, config.Output{Name: "archive", Type: "file", File: config.FileOutput{Path: path}})
+	require.NoError(t, err)
+
+	require.NoError(t, f.Send(context.Background(), []monitoring.FileEvent{{ID: "1"}, {ID: "2"}}))
+	// A rotated file is recreated on the next batch.
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, f.Send(context.Background(), []monitoring.FileEvent{{ID: "3"}}))
+
+	rotated, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(rotated), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var event monitoring.FileEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, "2", event.ID)
+
+	require.NoError(t, f.SendAlerts(context.Background(), []alert.Alert{{ID: "4", RuleID: "etc-changed"}}))
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines = strings.Split(strings.TrimSuffix(string(current), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"id":"3"`)
+	var a alert.Alert
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &a))
+	assert.Equal(t, "etc-changed", a.RuleID)
+
+	_, err = NewFileFromConfig(cfg, config.Output{Name: "archive", Type: "file"})
+	assert.ErrorContains(t, err, "file path is required")
+}
@@ -0,0 +1,292 @@
+// Package output forwards collected file events, and the alerts raised on
+// them, to external systems. A Forwarder reads new events from a monitor and
+// hands them to a Sink in batches, saving how far it got so a restarted
+// daemon carries on where it stopped instead of sending everything again.
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+var errAlertFormat = errors.New("format cannot encode alerts, use json, cef or leef")
+
+const (
+	// cursorFile holds the cursor after the last event handed to the sink.
+	cursorFile = "cursor"
+	// alertCursorFile holds the ID of the last alert handed to the sink.
+	alertCursorFile = "alert_cursor"
+
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5 * time.Second
+)
+
+type (
+	// Sink delivers batches of events to one destination. Send returns nil
+	// once the batch is delivered or handed to a dead-letter queue; an error
+	// means the batch should be sent again later.
+	Sink interface {
+		Send(ctx context.Context, events []monitoring.FileEvent) error
+		Close() error
+	}
+
+	// AlertSink is a Sink that also delivers batches of alerts, with the
+	// same guarantees as Send.
+	AlertSink interface {
+		Sink
+		SendAlerts(ctx context.Context, alerts []alert.Alert) error
+	}
+
+	// AlertSource hands out stored alerts in the order they were raised,
+	// like alert.Store.
+	AlertSource interface {
+		Since(after uint64, limit int) ([]alert.Alert, uint64, error)
+		Last() uint64
+	}
+
+	// Forwarder sends the events of a monitor, and optionally the alerts
+	// raised on them, to a sink. On its first run it starts with the events
+	// collected and alerts raised after it started; older events can be
+	// exported through /events and older alerts listed on /alerts.
+	Forwarder struct {
+		name          string
+		source        monitoring.EventSource
+		sink          Sink
+		alerts        AlertSource
+		alertSink     AlertSink
+		dir           string
+		batchSize     int
+		flushInterval time.Duration
+		log           *logger.Logger
+	}
+
+	ForwarderOption func(*Forwarder)
+)
+
+// WithBatchSize sets the most events sent to the sink at once.
+func WithBatchSize(size int) ForwarderOption {
+	return func(f *Forwarder) {
+		if size > 0 {
+			f.batchSize = size
+		}
+	}
+}
+
+// WithFlushInterval sets how often new events are sent.
+func WithFlushInterval(interval time.Duration) ForwarderOption {
+	return func(f *Forwarder) {
+		if interval > 0 {
+			f.flushInterval = interval
+		}
+	}
+}
+
+// WithAlerts also sends the alerts of source. It needs a sink that
+// delivers alerts.
+func WithAlerts(source AlertSource) ForwarderOption {
+	return func(f *Forwarder) {
+		f.alerts = source
+	}
+}
+
+// NewForwarder returns a forwarder named name that keeps its state in dir.
+func NewForwarder(name string, source monitoring.EventSource, sink Sink, dir string, log *logger.Logger, opts ...ForwarderOption) *Forwarder {
+	f := &Forwarder{
+		name:          name,
+		source:        source,
+		sink:          sink,
+		dir:           dir,
+		batchSize:     defaultBatchSize,
+		flushInterval: defaultFlushInterval,
+		log:           log,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.alerts != nil {
+		f.alertSink, _ = sink.(AlertSink)
+	}
+	return f
+}
+
+func (f *Forwarder) Name() string {
+	return f.name
+}
+
+// Run sends new events, then new alerts, every flush interval until ctx is
+// done. A batch the sink fails to take is sent again on the next flush.
+func (f *Forwarder) Run(ctx context.Context) error {
+	if err := os.MkdirAll(f.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create state directory for output %s: %w", f.name, err)
+	}
+	if f.alerts != nil && f.alertSink == nil {
+		return fmt.Errorf("output %s cannot deliver alerts", f.name)
+	}
+	cursor, err := f.loadCursor(ctx)
+	if err != nil {
+		return err
+	}
+	var alertCursor uint64
+	if f.alerts != nil {
+		if alertCursor, err = f.loadAlertCursor(); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			cursor = f.Flush(ctx, cursor)
+			if f.alerts != nil {
+				alertCursor = f.FlushAlerts(ctx, alertCursor)
+			}
+		}
+	}
+}
+
+// Flush sends the events after cursor and returns the cursor after the last
+// one the sink took.
+func (f *Forwarder) Flush(ctx context.Context, cursor monitoring.Cursor) monitoring.Cursor {
+	for {
+		events, next, err := f.source.EventsSince(ctx, cursor)
+		if err != nil {
+			if ctx.Err() == nil {
+				f.log.Error("Failed to read events for output", "output", f.name, "error", err)
+			}
+			return cursor
+		}
+		if len(events) == 0 {
+			return next
+		}
+
+		for start := 0; start < len(events); start += f.batchSize {
+			batch := events[start:min(start+f.batchSize, len(events))]
+			if err := f.sink.Send(ctx, batch); err != nil {
+				if ctx.Err() == nil {
+					f.log.Error("Failed to send events", "output", f.name, "error", err)
+				}
+				return cursor
+			}
+			cursor = next.At(batch[len(batch)-1])
+			if err := f.saveCursor(cursor); err != nil {
+				f.log.Error("Failed to save output cursor", "output", f.name, "error", err)
+			}
+		}
+		cursor = next
+	}
+}
+
+// FlushAlerts sends the alerts after the one with ID cursor and returns the
+// ID of the last one the sink took.
+func (f *Forwarder) FlushAlerts(ctx context.Context, cursor uint64) uint64 {
+	for {
+		alerts, next, err := f.alerts.Since(cursor, f.batchSize)
+		if err != nil {
+			f.log.Error("Failed to read alerts for output", "output", f.name, "error", err)
+			return cursor
+		}
+		if len(alerts) == 0 {
+			return cursor
+		}
+		if err := f.alertSink.SendAlerts(ctx, alerts); err != nil {
+			if ctx.Err() == nil {
+				f.log.Error("Failed to send alerts", "output", f.name, "error", err)
+			}
+			return cursor
+		}
+		cursor = next
+		if err := writeFile(filepath.Join(f.dir, alertCursorFile), []byte(strconv.FormatUint(cursor, 10))); err != nil {
+			f.log.Error("Failed to save output alert cursor", "output", f.name, "error", err)
+		}
+		if len(alerts) < f.batchSize {
+			return cursor
+		}
+	}
+}
+
+// loadAlertCursor returns the ID of the last alert sent, or of the newest
+// alert when there is none yet.
+func (f *Forwarder) loadAlertCursor() (uint64, error) {
+	path := filepath.Join(f.dir, alertCursorFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		cursor := f.alerts.Last()
+		return cursor, writeFile(path, []byte(strconv.FormatUint(cursor, 10)))
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read alert cursor of output %s: %w", f.name, err)
+	}
+	cursor, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode alert cursor of output %s: %w", f.name, err)
+	}
+	return cursor, nil
+}
+
+// loadCursor returns the saved cursor, or the monitor's head when there is
+// none yet.
+func (f *Forwarder) loadCursor(ctx context.Context) (monitoring.Cursor, error) {
+	var cursor monitoring.Cursor
+	data, err := os.ReadFile(filepath.Join(f.dir, cursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		if cursor, err = f.source.Head(ctx); err != nil {
+			return cursor, fmt.Errorf("failed to find the newest event for output %s: %w", f.name, err)
+		}
+		return cursor, f.saveCursor(cursor)
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("failed to read cursor of output %s: %w", f.name, err)
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("failed to decode cursor of output %s: %w", f.name, err)
+	}
+	return cursor, nil
+}
+
+func (f *Forwarder) saveCursor(cursor monitoring.Cursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(f.dir, cursorFile), data)
+}
+
+// Close closes the forwarder's sink.
+func (f *Forwarder) Close() error {
+	return f.sink.Close()
+}
+
+// alertFormatter returns formatter as an AlertFormatter, or an error if its
+// format cannot encode alerts.
+func alertFormatter(formatter format.Formatter) (format.AlertFormatter, error) {
+	f, ok := formatter.(format.AlertFormatter)
+	if !ok {
+		return nil, errAlertFormat
+	}
+	return f, nil
+}
+
+// writeFile replaces the file at path with data in one step.
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path+".tmp", data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
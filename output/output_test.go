@@ -0,0 +1,165 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// sliceSource hands out events from a slice, numbered from 1.
+type sliceSource struct {
+	events []monitoring.FileEvent
+}
+
+func (s *sliceSource) add(n int) {
+	for i := 0; i < n; i++ {
+		id := len(s.events) + 1
+		s.events = append(s.events, monitoring.FileEvent{ID: strconv.Itoa(id), Time: time.Unix(int64(id), 0)})
+	}
+}
+
+func (s *sliceSource) EventsSince(ctx context.Context, cursor monitoring.Cursor) ([]monitoring.FileEvent, monitoring.Cursor, error) {
+	events := s.events[cursor.Seq:]
+	if len(events) > 0 {
+		cursor = cursor.At(events[len(events)-1])
+	}
+	return events, cursor, nil
+}
+
+func (s *sliceSource) Head(ctx context.Context) (monitoring.Cursor, error) {
+	if len(s.events) == 0 {
+		return monitoring.Cursor{}, nil
+	}
+	return monitoring.Cursor{}.At(s.events[len(s.events)-1]), nil
+}
+
+// sliceAlerts hands out alerts from a slice, numbered from 1.
+type sliceAlerts struct {
+	alerts []alert.Alert
+}
+
+func (s *sliceAlerts) add(n int) {
+	for i := 0; i < n; i++ {
+		s.alerts = append(s.alerts, alert.Alert{ID: strconv.Itoa(len(s.alerts) + 1), RuleID: "test"})
+	}
+}
+
+func (s *sliceAlerts) Since(after uint64, limit int) ([]alert.Alert, uint64, error) {
+	alerts := s.alerts[after:min(after+uint64(limit), uint64(len(s.alerts)))]
+	return alerts, after + uint64(len(alerts)), nil
+}
+
+func (s *sliceAlerts) Last() uint64 {
+	return uint64(len(s.alerts))
+}
+
+// recordingSink keeps the batches it is sent and fails while failing is set.
+type recordingSink struct {
+	batches      [][]string
+	alertBatches [][]string
+	failing      bool
+}
+
+func (s *recordingSink) Send(ctx context.Context, events []monitoring.FileEvent) error {
+	if s.failing {
+		return errors.New("unavailable")
+	}
+	var ids []string
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	s.batches = append(s.batches, ids)
+	return nil
+}
+
+func (s *recordingSink) SendAlerts(ctx context.Context, alerts []alert.Alert) error {
+	if s.failing {
+		return errors.New("unavailable")
+	}
+	var ids []string
+	for _, a := range alerts {
+		ids = append(ids, a.ID)
+	}
+	s.alertBatches = append(s.alertBatches, ids)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func TestForwarder(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	source := &sliceSource{}
+	source.add(2)
+	sink := &recordingSink{}
+
+	// Events from before the first run are not sent.
+	forwarder := NewForwarder("test", source, sink, dir, mockLogger, WithBatchSize(2))
+	cursor, err := forwarder.loadCursor(context.Background())
+	require.NoError(t, err)
+	source.add(3)
+	cursor = forwarder.Flush(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"3", "4"}, {"5"}}, sink.batches)
+
+	// A batch the sink refuses is sent again on the next flush.
+	source.add(1)
+	sink.failing = true
+	cursor = forwarder.Flush(context.Background(), cursor)
+	sink.failing = false
+	forwarder.Flush(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"3", "4"}, {"5"}, {"6"}}, sink.batches)
+
+	// A restarted forwarder carries on from the saved cursor.
+	source.add(1)
+	restarted := NewForwarder("test", source, sink, dir, mockLogger)
+	cursor, err = restarted.loadCursor(context.Background())
+	require.NoError(t, err)
+	restarted.Flush(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"3", "4"}, {"5"}, {"6"}, {"7"}}, sink.batches)
+}
+
+func TestForwarderAlerts(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	alerts := &sliceAlerts{}
+	alerts.add(1)
+	sink := &recordingSink{}
+
+	// Alerts raised before the first run are not sent.
+	forwarder := NewForwarder("test", &sliceSource{}, sink, dir, mockLogger, WithBatchSize(2), WithAlerts(alerts))
+	cursor, err := forwarder.loadAlertCursor()
+	require.NoError(t, err)
+	alerts.add(3)
+	cursor = forwarder.FlushAlerts(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"2", "3"}, {"4"}}, sink.alertBatches)
+
+	// A batch the sink refuses is sent again on the next flush.
+	alerts.add(1)
+	sink.failing = true
+	cursor = forwarder.FlushAlerts(context.Background(), cursor)
+	sink.failing = false
+	forwarder.FlushAlerts(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"2", "3"}, {"4"}, {"5"}}, sink.alertBatches)
+
+	// A restarted forwarder carries on from the saved cursor.
+	alerts.add(1)
+	restarted := NewForwarder("test", &sliceSource{}, sink, dir, mockLogger, WithAlerts(alerts))
+	cursor, err = restarted.loadAlertCursor()
+	require.NoError(t, err)
+	restarted.FlushAlerts(context.Background(), cursor)
+	assert.Equal(t, [][]string{{"2", "3"}, {"4"}, {"5"}, {"6"}}, sink.alertBatches)
+	assert.Empty(t, sink.batches)
+}
@@ -0,0 +1,434 @@
+package output
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	// sdID names the structured-data element carrying the event fields. 32473
+	// is the private enterprise number reserved for documentation (RFC 5612).
+	sdID = "fim@32473"
+	// alertSDID names the element carrying the fields of an alert.
+	alertSDID = "fimAlert@32473"
+	// alertMsgID is the MSGID of alerts; events use their action.
+	alertMsgID = "ALERT"
+
+	defaultSyslogTimeout = 10 * time.Second
+	// utf8BOM marks the message text as UTF-8, as RFC 5424 asks.
+	utf8BOM = "\xef\xbb\xbf"
+)
+
+var errSyslogBackoff = errors.New("waiting to reconnect to syslog server")
+
+// facilities maps syslog facility names to their codes.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// severities maps event severities to syslog severities.
+var severities = map[string]int{
+	"critical": 2,
+	"high":     3,
+	"medium":   4,
+	"low":      5,
+	"info":     6,
+}
+
+type (
+	// Syslog sends each event or alert as an RFC 5424 message over UDP, TCP
+	// or TLS. Their fields are carried in a structured-data element. Over TCP
+	// and TLS messages are framed by octet counting (RFC 6587, RFC 5425).
+	// A broken connection fails the batch, which the forwarder sends again
+	// after reconnecting. Failed connection attempts back off exponentially.
+	Syslog struct {
+		network   string
+		address   string
+		tlsConfig *tls.Config
+		facility  int
+		appName   string
+		hostname  string
+		procID    string
+		timeout   time.Duration
+		reconnect RetryPolicy
+		formatter format.Formatter
+		log       *logger.Logger
+		now       func() time.Time
+
+		mutex    sync.Mutex
+		conn     net.Conn
+		failures int
+		retryAt  time.Time
+	}
+
+	SyslogOption func(*Syslog)
+)
+
+var _ AlertSink = (*Syslog)(nil)
+
+// Facility returns the code of a syslog facility name such as "local0".
+func Facility(name string) (int, error) {
+	facility, ok := facilities[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	return facility, nil
+}
+
+func WithFacility(facility int) SyslogOption {
+	return func(s *Syslog) {
+		s.facility = facility
+	}
+}
+
+// WithAppName sets the APP-NAME of every message.
+func WithAppName(name string) SyslogOption {
+	return func(s *Syslog) {
+		if name != "" {
+			s.appName = name
+		}
+	}
+}
+
+// WithHostname sets the HOSTNAME of every message, which defaults to the
+// machine's host name.
+func WithHostname(hostname string) SyslogOption {
+	return func(s *Syslog) {
+		if hostname != "" {
+			s.hostname = hostname
+		}
+	}
+}
+
+// WithSyslogFormatter makes the message text a record of formatter, for
+// example CEF, instead of the action and path.
+func WithSyslogFormatter(formatter format.Formatter) SyslogOption {
+	return func(s *Syslog) {
+		s.formatter = formatter
+	}
+}
+
+// WithTLS sends messages over TLS with config. It only applies to the "tcp"
+// network.
+func WithTLS(config *tls.Config) SyslogOption {
+	return func(s *Syslog) {
+		s.tlsConfig = config
+	}
+}
+
+// WithDialTimeout limits how long connecting and writing a batch may take.
+func WithDialTimeout(timeout time.Duration) SyslogOption {
+	return func(s *Syslog) {
+		if timeout > 0 {
+			s.timeout = timeout
+		}
+	}
+}
+
+// WithReconnectPolicy sets the backoff between attempts to reconnect.
+// MaxRetries is not used: reconnecting goes on for as long as the output
+// runs.
+func WithReconnectPolicy(policy RetryPolicy) SyslogOption {
+	return func(s *Syslog) {
+		s.reconnect = policy
+	}
+}
+
+// NewSyslog returns a sink sending to address over network, "udp" or "tcp".
+func NewSyslog(network, address string, log *logger.Logger, opts ...SyslogOption) (*Syslog, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	s := &Syslog{
+		network:   network,
+		address:   address,
+		facility:  facilities["local0"],
+		appName:   "filemodtracker",
+		hostname:  hostname,
+		procID:    strconv.Itoa(os.Getpid()),
+		timeout:   defaultSyslogTimeout,
+		reconnect: DefaultRetryPolicy(),
+		log:       log,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.tlsConfig != nil && network != "tcp" {
+		return nil, errors.New("syslog over TLS needs the tcp network")
+	}
+	return s, nil
+}
+
+// Send writes one message per event. A failed write closes the connection
+// and fails the batch; the next Send reconnects.
+func (s *Syslog) Send(ctx context.Context, events []monitoring.FileEvent) error {
+	messages := make([]string, 0, len(events))
+	for _, event := range events {
+		message, err := s.Format(event)
+		if err != nil {
+			return fmt.Errorf("failed to format event %s: %w", event.ID, err)
+		}
+		messages = append(messages, message)
+	}
+	return s.write(ctx, messages)
+}
+
+// SendAlerts writes one message per alert, like Send.
+func (s *Syslog) SendAlerts(ctx context.Context, alerts []alert.Alert) error {
+	messages := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		message, err := s.FormatAlert(a)
+		if err != nil {
+			return fmt.Errorf("failed to format alert %s: %w", a.ID, err)
+		}
+		messages = append(messages, message)
+	}
+	return s.write(ctx, messages)
+}
+
+func (s *Syslog) write(ctx context.Context, messages []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.connect(ctx); err != nil {
+		return err
+	}
+	deadline := s.now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetWriteDeadline(deadline)
+
+	for _, message := range messages {
+		if s.network == "tcp" {
+			message = strconv.Itoa(len(message)) + " " + message
+		}
+		if _, err := s.conn.Write([]byte(message)); err != nil {
+			s.disconnect()
+			return fmt.Errorf("failed to write to syslog server %s: %w", s.address, err)
+		}
+	}
+	return nil
+}
+
+// Format returns the RFC 5424 message for event.
+func (s *Syslog) Format(event monitoring.FileEvent) (string, error) {
+	text := string(event.Action) + " " + event.TargetPath
+	if s.formatter != nil {
+		record, err := s.formatter.Format(event)
+		if err != nil {
+			return "", err
+		}
+		text = string(record)
+	}
+	return s.message(event.Time, event.Severity, string(event.Action), sdID, eventParams(event), text), nil
+}
+
+// FormatAlert returns the RFC 5424 message for an alert, whose MSGID is
+// ALERT.
+func (s *Syslog) FormatAlert(a alert.Alert) (string, error) {
+	text := alertMsgID + " " + a.RuleID
+	if a.Description != "" {
+		text += ": " + a.Description
+	}
+	if s.formatter != nil {
+		formatter, err := alertFormatter(s.formatter)
+		if err != nil {
+			return "", err
+		}
+		record, err := formatter.FormatAlert(a)
+		if err != nil {
+			return "", err
+		}
+		text = string(record)
+	}
+	return s.message(a.Time, a.Severity, alertMsgID, alertSDID, alertParams(a), text), nil
+}
+
+// message builds an RFC 5424 message with one structured-data element.
+func (s *Syslog) message(t time.Time, level, msgID, element string, params [][2]string, text string) string {
+	severity, ok := severities[level]
+	if !ok {
+		severity = severities["info"]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		s.facility*8+severity,
+		t.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(s.hostname, 255),
+		headerField(s.appName, 48),
+		headerField(s.procID, 128),
+		headerField(msgID, 32))
+
+	b.WriteString("[" + element)
+	for _, param := range params {
+		b.WriteString(" " + param[0] + `="` + escapeParam(param[1]) + `"`)
+	}
+	b.WriteString("] " + utf8BOM + text)
+	return b.String()
+}
+
+func (s *Syslog) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.disconnect()
+	return nil
+}
+
+// connect dials the server unless connected or backing off after a failed
+// attempt. The caller holds the mutex.
+func (s *Syslog) connect(ctx context.Context) error {
+	if s.conn != nil {
+		return nil
+	}
+	if s.now().Before(s.retryAt) {
+		return errSyslogBackoff
+	}
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, s.network, s.address)
+	} else {
+		conn, err = dialer.DialContext(ctx, s.network, s.address)
+	}
+	if err != nil {
+		s.failed()
+		return fmt.Errorf("failed to connect to syslog server %s: %w", s.address, err)
+	}
+	if s.failures > 0 {
+		s.log.Info("Reconnected to syslog server", "address", s.address, "failures", s.failures)
+	}
+	s.conn, s.failures, s.retryAt = conn, 0, time.Time{}
+	return nil
+}
+
+func (s *Syslog) disconnect() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// failed schedules the next connection attempt.
+func (s *Syslog) failed() {
+	backoff := float64(s.reconnect.InitialBackoff) * math.Pow(s.reconnect.Multiplier, float64(s.failures))
+	if s.reconnect.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(s.reconnect.MaxBackoff))
+	}
+	s.failures++
+	s.retryAt = s.now().Add(time.Duration(backoff))
+}
+
+// eventParams returns the structured-data parameters of event, leaving out
+// fields that are not set.
+func eventParams(event monitoring.FileEvent) [][2]string {
+	params := [][2]string{
+		{"id", event.ID},
+		{"action", string(event.Action)},
+		{"path", event.Path},
+		{"target_path", event.TargetPath},
+		{"category", event.Category},
+		{"severity", event.Severity},
+	}
+	add := func(name, value string) {
+		if value != "" {
+			params = append(params, [2]string{name, value})
+		}
+	}
+	if event.Size != nil {
+		add("size", strconv.FormatInt(*event.Size, 10))
+	}
+	add("mode", event.Mode)
+	if event.UID != nil {
+		add("uid", strconv.FormatUint(uint64(*event.UID), 10))
+	}
+	if event.GID != nil {
+		add("gid", strconv.FormatUint(uint64(*event.GID), 10))
+	}
+	if event.Inode != 0 {
+		add("inode", strconv.FormatUint(event.Inode, 10))
+	}
+	for _, t := range []struct {
+		name string
+		time *time.Time
+	}{{"atime", event.AccessTime}, {"mtime", event.ModTime}, {"ctime", event.ChangeTime}} {
+		if t.time != nil {
+			add(t.name, t.time.UTC().Format(time.RFC3339))
+		}
+	}
+	if event.Hashes != nil {
+		add("md5", event.Hashes.MD5)
+		add("sha1", event.Hashes.SHA1)
+		add("sha256", event.Hashes.SHA256)
+	}
+	add("raw_ids", strings.Join(event.RawIDs, ","))
+	return params
+}
+
+// alertParams returns the structured-data parameters of an alert. path is
+// the target path of the last event that raised it.
+func alertParams(a alert.Alert) [][2]string {
+	params := [][2]string{
+		{"id", a.ID},
+		{"rule_id", a.RuleID},
+		{"severity", a.Severity},
+		{"dedup_key", a.DedupKey},
+		{"count", strconv.Itoa(a.Count)},
+	}
+	if a.Description != "" {
+		params = append(params, [2]string{"description", a.Description})
+	}
+	if len(a.Events) > 0 {
+		params = append(params, [2]string{"path", a.Events[len(a.Events)-1].TargetPath})
+	}
+	return params
+}
+
+// escapeParam escapes the characters RFC 5424 reserves in parameter values.
+func escapeParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// headerField makes value a valid header field: printable US-ASCII without
+// spaces, at most limit characters, and "-" when empty.
+func headerField(value string, limit int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > limit {
+		field = field[:limit]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
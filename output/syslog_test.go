@@ -0,0 +1,263 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func syslogEvent(id, path string) monitoring.FileEvent {
+	uid := uint32(0)
+	return monitoring.FileEvent{
+		ID:         id,
+		Time:       time.Date(2024, 6, 1, 12, 0, 0, 123456789, time.UTC),
+		Action:     monitoring.ActionUpdated,
+		Path:       path,
+		TargetPath: path,
+		Category:   "etc",
+		Severity:   "high",
+		UID:        &uid,
+		Hashes:     &monitoring.Hashes{SHA256: "abc"},
+	}
+}
+
+// readFrame reads one octet-counted message.
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+	length, err := r.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	require.NoError(t, err)
+	message := make([]byte, n)
+	_, err = io.ReadFull(r, message)
+	require.NoError(t, err)
+	return string(message)
+}
+
+func TestSyslogFormat(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	s, err := NewSyslog("udp", "127.0.0.1:514", mockLogger, WithFacility(4), WithHostname("web 1"), WithAppName("fim"))
+	require.NoError(t, err)
+	s.procID = "42"
+
+	message, err := s.Format(syslogEvent("7", `/etc/a "b" [c]\d`))
+	require.NoError(t, err)
+	assert.Equal(t, `<35>1 2024-06-01T12:00:00.123456Z web_1 fim 42 UPDATED `+
+		`[fim@32473 id="7" action="UPDATED" path="/etc/a \"b\" [c\]\\d" target_path="/etc/a \"b\" [c\]\\d" category="etc" severity="high" uid="0" sha256="abc"] `+
+		"\xef\xbb\xbfUPDATED /etc/a \"b\" [c]\\d", message)
+
+	leef, err := format.New("leef", format.Options{Hostname: "web-1"})
+	require.NoError(t, err)
+	WithSyslogFormatter(leef)(s)
+	message, err = s.Format(syslogEvent("7", "/etc/hosts"))
+	require.NoError(t, err)
+	assert.Contains(t, message, "\xef\xbb\xbfLEEF:2.0|FileModTracker|FileModTracker|1.0|UPDATED|x09|")
+
+	_, err = Facility("local9")
+	assert.Error(t, err)
+}
+
+func TestSyslogFormatAlert(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	s, err := NewSyslog("udp", "127.0.0.1:514", mockLogger, WithHostname("web-1"), WithAppName("fim"))
+	require.NoError(t, err)
+	s.procID = "42"
+
+	a := alert.Alert{
+		ID:          "5",
+		Time:        time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		RuleID:      "mass-delete",
+		Severity:    "critical",
+		Description: `Deletes under "/srv"`,
+		DedupKey:    "mass-delete:/srv",
+		Count:       51,
+		Events:      []monitoring.FileEvent{syslogEvent("9", "/srv/a"), syslogEvent("10", "/srv/b")},
+	}
+	message, err := s.FormatAlert(a)
+	require.NoError(t, err)
+	assert.Equal(t, `<130>1 2024-06-01T12:00:00.000000Z web-1 fim 42 ALERT `+
+		`[fimAlert@32473 id="5" rule_id="mass-delete" severity="critical" dedup_key="mass-delete:/srv" count="51" description="Deletes under \"/srv\"" path="/srv/b"] `+
+		"\xef\xbb\xbfALERT mass-delete: Deletes under \"/srv\"", message)
+
+	cef, err := format.New("cef", format.Options{Hostname: "web-1"})
+	require.NoError(t, err)
+	WithSyslogFormatter(cef)(s)
+	message, err = s.FormatAlert(a)
+	require.NoError(t, err)
+	assert.Contains(t, message, "\xef\xbb\xbfCEF:0|FileModTracker|FileModTracker|1.0|mass-delete|")
+
+	// Formats without alert records cannot carry alerts.
+	ecs, err := format.New("ecs", format.Options{})
+	require.NoError(t, err)
+	WithSyslogFormatter(ecs)(s)
+	_, err = s.FormatAlert(a)
+	assert.ErrorContains(t, err, "cannot encode alerts")
+}
+
+func TestSyslogUDP(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	s, err := NewSyslog("udp", conn.LocalAddr().String(), mockLogger)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("1", "/etc/a"), syslogEvent("2", "/etc/b")}))
+
+	buf := make([]byte, 4096)
+	for _, path := range []string{"/etc/a", "/etc/b"} {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(buf[:n]), "<131>1 "), string(buf[:n]))
+		assert.True(t, strings.HasSuffix(string(buf[:n]), "UPDATED "+path))
+	}
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+
+	s, err := NewSyslog("tcp", address, mockLogger, WithReconnectPolicy(RetryPolicy{InitialBackoff: time.Millisecond, Multiplier: 2}))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("1", "/etc/a"), syslogEvent("2", "/etc/b")}))
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	assert.True(t, strings.HasSuffix(readFrame(t, r), "UPDATED /etc/a"))
+	assert.True(t, strings.HasSuffix(readFrame(t, r), "UPDATED /etc/b"))
+
+	// The server goes away; sending fails once the connection is noticed to
+	// be broken, and while nobody listens.
+	require.NoError(t, conn.Close())
+	require.NoError(t, listener.Close())
+	assert.Eventually(t, func() bool {
+		return s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("3", "/etc/c")}) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Error(t, s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("3", "/etc/c")}))
+
+	// Once it is back, the next batch reconnects.
+	listener, err = net.Listen("tcp", address)
+	require.NoError(t, err)
+	defer listener.Close()
+	assert.Eventually(t, func() bool {
+		return s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("3", "/etc/c")}) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	conn, err = listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.True(t, strings.HasSuffix(readFrame(t, bufio.NewReader(conn)), "UPDATED /etc/c"))
+}
+
+func TestSyslogTLS(t *testing.T) {
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	cert, caFile := selfSignedCert(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	out := config.Output{Name: "soc", Type: "syslog", Syslog: config.SyslogOutput{
+		Network:  "tls",
+		Address:  listener.Addr().String(),
+		Facility: "auth",
+		CAFile:   caFile,
+	}}
+	s, err := NewSyslogFromConfig(&config.Config{}, out, mockLogger)
+	require.NoError(t, err)
+	defer s.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				length, err := r.ReadString(' ')
+				if err != nil {
+					return
+				}
+				n, _ := strconv.Atoi(strings.TrimSuffix(length, " "))
+				message := make([]byte, n)
+				_, _ = io.ReadFull(r, message)
+				received <- string(message)
+			}()
+		}
+	}()
+
+	require.NoError(t, s.Send(context.Background(), []monitoring.FileEvent{syslogEvent("1", "/etc/a")}))
+	select {
+	case message := <-received:
+		assert.True(t, strings.HasPrefix(message, "<35>1 "), message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	// Without the CA the server is not trusted.
+	out.Syslog.CAFile = ""
+	untrusted, err := NewSyslogFromConfig(&config.Config{}, out, mockLogger)
+	require.NoError(t, err)
+	assert.Error(t, untrusted.Send(context.Background(), []monitoring.FileEvent{syslogEvent("1", "/etc/a")}))
+}
+
+// selfSignedCert returns a certificate for 127.0.0.1 and the path of a PEM
+// file holding it as a CA.
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
@@ -0,0 +1,378 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the request body, keyed with the webhook secret.
+	SignatureHeader = "X-Filemodtracker-Signature"
+	// TimestampHeader carries the Unix time the request was signed at, so
+	// receivers can reject old requests replayed by someone else.
+	TimestampHeader = "X-Filemodtracker-Timestamp"
+	// DeliveryHeader identifies a batch. Retries and replays of the batch
+	// carry the same ID, so receivers can drop duplicates.
+	DeliveryHeader = "X-Filemodtracker-Delivery"
+
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+type (
+	// RetryPolicy controls how often a failed delivery is retried before it
+	// goes to the dead-letter queue. Backoff grows from InitialBackoff by
+	// Multiplier up to MaxBackoff, each delay randomised by up to Jitter (a
+	// fraction of the delay).
+	RetryPolicy struct {
+		MaxRetries     int
+		InitialBackoff time.Duration
+		MaxBackoff     time.Duration
+		Multiplier     float64
+		Jitter         float64
+	}
+
+	// Webhook POSTs batches of events or alerts as JSON to a URL. Requests
+	// are signed with a shared secret; connection errors, timeouts, 408, 429
+	// and 5xx responses are retried and other failures, such as an invalid
+	// URL or certificate, go straight to the dead-letter queue.
+	Webhook struct {
+		name       string
+		url        string
+		secret     string
+		headers    map[string]string
+		client     *http.Client
+		retry      RetryPolicy
+		deadLetter *DeadLetterQueue
+		formatter  format.Formatter
+		log        *logger.Logger
+		now        func() time.Time
+		jitter     func() float64
+	}
+
+	WebhookOption func(*Webhook)
+
+	webhookPayload struct {
+		Events []monitoring.FileEvent `json:"events"`
+	}
+
+	alertPayload struct {
+		Alerts []alert.Alert `json:"alerts"`
+	}
+
+	// statusError is a response outside 2xx.
+	statusError struct {
+		code int
+	}
+
+	// requestError is a request that could not be built, which sending it
+	// again cannot fix.
+	requestError struct {
+		err error
+	}
+)
+
+var _ AlertSink = (*Webhook)(nil)
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// WithHeaders adds headers to every request, for example for an API key
+// the receiver expects.
+func WithHeaders(headers map[string]string) WebhookOption {
+	return func(w *Webhook) {
+		w.headers = headers
+	}
+}
+
+// WithTimeout limits how long one request may take.
+func WithTimeout(timeout time.Duration) WebhookOption {
+	return func(w *Webhook) {
+		if timeout > 0 {
+			w.client.Timeout = timeout
+		}
+	}
+}
+
+// WithWebhookFormatter sends the batch as one record of formatter per line
+// instead of the JSON object {"events": [...]} or {"alerts": [...]}.
+func WithWebhookFormatter(formatter format.Formatter) WebhookOption {
+	return func(w *Webhook) {
+		w.formatter = formatter
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) WebhookOption {
+	return func(w *Webhook) {
+		w.retry = policy
+	}
+}
+
+// NewWebhook returns a sink named name that delivers to url and puts batches
+// it cannot deliver in deadLetter.
+func NewWebhook(name, url, secret string, deadLetter *DeadLetterQueue, log *logger.Logger, opts ...WebhookOption) *Webhook {
+	w := &Webhook{
+		name:       name,
+		url:        url,
+		secret:     secret,
+		client:     &http.Client{Timeout: defaultWebhookTimeout},
+		retry:      DefaultRetryPolicy(),
+		deadLetter: deadLetter,
+		log:        log,
+		now:        time.Now,
+		jitter:     mathrand.Float64,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Sign returns the signature of a request body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body sent at
+// timestamp. Receivers should also reject timestamps too far from now.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Send delivers events as one request, retrying as the retry policy allows,
+// and queues them as a dead letter if that fails. It only returns an error
+// if ctx ended first or the dead letter could not be saved.
+func (w *Webhook) Send(ctx context.Context, events []monitoring.FileEvent) error {
+	body, contentType, err := w.encode(events)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+	return w.send(ctx, Letter{Events: len(events), ContentType: contentType, Payload: string(body)})
+}
+
+// SendAlerts delivers alerts like Send delivers events.
+func (w *Webhook) SendAlerts(ctx context.Context, alerts []alert.Alert) error {
+	body, contentType, err := w.encodeAlerts(alerts)
+	if err != nil {
+		return fmt.Errorf("failed to encode alerts: %w", err)
+	}
+	return w.send(ctx, Letter{Alerts: len(alerts), ContentType: contentType, Payload: string(body)})
+}
+
+// send delivers the payload of letter and queues letter if that fails.
+func (w *Webhook) send(ctx context.Context, letter Letter) error {
+	letter.ID, letter.Output = newDeliveryID(), w.name
+	contentType, body := letter.ContentType, []byte(letter.Payload)
+
+	attempts, err := w.deliver(ctx, letter.ID, contentType, body)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	letter.FailedAt, letter.Attempts, letter.Error = w.now(), attempts, err.Error()
+	w.log.Error("Webhook delivery failed, moving it to the dead-letter queue",
+		"output", w.name, "delivery", letter.ID, "attempts", attempts, "error", err)
+	return w.deadLetter.Put(letter)
+}
+
+// Replay sends a dead letter once more. A delivered letter is removed from
+// the queue; otherwise it stays with its attempts and error updated.
+func (w *Webhook) Replay(ctx context.Context, letter Letter) error {
+	if err := w.post(ctx, letter.ID, letter.ContentType, []byte(letter.Payload)); err != nil {
+		letter.FailedAt, letter.Error = w.now(), err.Error()
+		letter.Attempts++
+		if putErr := w.deadLetter.Put(letter); putErr != nil {
+			return errors.Join(err, putErr)
+		}
+		return err
+	}
+	return w.deadLetter.Remove(letter.ID)
+}
+
+// DeadLetters returns the queue of deliveries that failed.
+func (w *Webhook) DeadLetters() *DeadLetterQueue {
+	return w.deadLetter
+}
+
+func (w *Webhook) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// deliver posts body until it is accepted, the error is permanent or the
+// retries are used up, and returns the number of attempts made.
+func (w *Webhook) deliver(ctx context.Context, id, contentType string, body []byte) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := w.post(ctx, id, contentType, body)
+		if err == nil || !retryable(err) || attempt > w.retry.MaxRetries {
+			return attempt, err
+		}
+
+		backoff := w.backoff(attempt - 1)
+		w.log.Warn("Retrying webhook delivery", "output", w.name, "delivery", id, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// encode returns the request body for events and its content type.
+func (w *Webhook) encode(events []monitoring.FileEvent) ([]byte, string, error) {
+	if w.formatter == nil {
+		body, err := json.Marshal(webhookPayload{Events: events})
+		return body, "application/json", err
+	}
+	var body bytes.Buffer
+	for _, event := range events {
+		record, err := w.formatter.Format(event)
+		if err != nil {
+			return nil, "", err
+		}
+		body.Write(record)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), w.formatter.ContentType(), nil
+}
+
+// encodeAlerts returns the request body for alerts and its content type.
+func (w *Webhook) encodeAlerts(alerts []alert.Alert) ([]byte, string, error) {
+	if w.formatter == nil {
+		body, err := json.Marshal(alertPayload{Alerts: alerts})
+		return body, "application/json", err
+	}
+	formatter, err := alertFormatter(w.formatter)
+	if err != nil {
+		return nil, "", err
+	}
+	var body bytes.Buffer
+	for _, a := range alerts {
+		record, err := formatter.FormatAlert(a)
+		if err != nil {
+			return nil, "", err
+		}
+		body.Write(record)
+		body.WriteByte('\n')
+	}
+	return body.Bytes(), w.formatter.ContentType(), nil
+}
+
+func (w *Webhook) post(ctx context.Context, id, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return &requestError{err: err}
+	}
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+	timestamp := w.now().Unix()
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(DeliveryHeader, id)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(w.secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return nil
+}
+
+func (w *Webhook) backoff(attempt int) time.Duration {
+	backoff := float64(w.retry.InitialBackoff) * math.Pow(w.retry.Multiplier, float64(attempt))
+	if w.retry.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(w.retry.MaxBackoff))
+	}
+	if w.retry.Jitter > 0 {
+		backoff += backoff * w.retry.Jitter * (2*w.jitter() - 1)
+	}
+	return time.Duration(backoff)
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d %s", e.code, http.StatusText(e.code))
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("failed to build request: %v", e.err)
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether a failed request may succeed when sent again.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusRequestTimeout ||
+			status.code == http.StatusTooManyRequests ||
+			status.code >= 500
+	}
+	var request *requestError
+	if errors.As(err, &request) {
+		return false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalid x509.CertificateInvalidError
+		var parse *url.Error
+		return !errors.As(urlErr.Err, &unknownAuthority) &&
+			!errors.As(urlErr.Err, &hostname) &&
+			!errors.As(urlErr.Err, &invalid) &&
+			!errors.As(urlErr.Err, &parse)
+	}
+	return true
+}
+
+func newDeliveryID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
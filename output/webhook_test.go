@@ -0,0 +1,216 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func newTestWebhook(t *testing.T, url string) *Webhook {
+	t.Helper()
+	mockLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+	deadLetter, err := OpenDeadLetterQueue(t.TempDir())
+	require.NoError(t, err)
+	return NewWebhook("soc", url, "s3cret", deadLetter, mockLogger,
+		WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond, Multiplier: 2}))
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"events":[]}`)
+	signature := Sign("s3cret", 1717243200, body)
+	assert.Equal(t, "sha256=", signature[:7])
+	assert.True(t, Verify("s3cret", 1717243200, body, signature))
+	assert.False(t, Verify("s3cret", 1717243201, body, signature))
+	assert.False(t, Verify("other", 1717243200, body, signature))
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var requests atomic.Int32
+	var deliveries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.True(t, Verify("s3cret", timestamp, body, r.Header.Get(SignatureHeader)))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		deliveries = append(deliveries, r.Header.Get(DeliveryHeader))
+
+		var payload struct {
+			Events []monitoring.FileEvent `json:"events"`
+		}
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Len(t, payload.Events, 2)
+
+		// Fail the first two attempts.
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	webhook := newTestWebhook(t, srv.URL)
+	events := []monitoring.FileEvent{{ID: "1", TargetPath: "/etc/hosts"}, {ID: "2", TargetPath: "/etc/passwd"}}
+	require.NoError(t, webhook.Send(context.Background(), events))
+	assert.EqualValues(t, 3, requests.Load())
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, deliveries[0], deliveries[2], "retries keep the delivery ID")
+
+	letters, err := webhook.DeadLetters().List()
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusBadRequest)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	webhook := newTestWebhook(t, srv.URL)
+	require.NoError(t, webhook.Send(context.Background(), []monitoring.FileEvent{{ID: "1"}}))
+	// A client error is not retried.
+	assert.EqualValues(t, 1, requests.Load())
+
+	status.Store(http.StatusInternalServerError)
+	require.NoError(t, webhook.Send(context.Background(), []monitoring.FileEvent{{ID: "2"}, {ID: "3"}}))
+	assert.EqualValues(t, 4, requests.Load())
+
+	letters, err := webhook.DeadLetters().List()
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "soc", letters[0].Output)
+	assert.Equal(t, 1, letters[0].Attempts)
+	assert.Equal(t, 3, letters[1].Attempts)
+	assert.Equal(t, 2, letters[1].Events)
+	assert.Contains(t, letters[1].Error, "500")
+
+	// A replay that fails again keeps the letter.
+	require.Error(t, webhook.Replay(context.Background(), letters[1]))
+	letter, err := webhook.DeadLetters().Get(letters[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, letter.Attempts)
+
+	status.Store(http.StatusOK)
+	require.NoError(t, webhook.Replay(context.Background(), letter))
+	_, err = webhook.DeadLetters().Get(letter.ID)
+	assert.ErrorIs(t, err, ErrLetterNotFound)
+
+	letters, err = webhook.DeadLetters().List()
+	require.NoError(t, err)
+	assert.Len(t, letters, 1)
+}
+
+func TestWebhookPermanentErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// The test server's certificate is not trusted, so no attempt succeeds.
+	webhook := newTestWebhook(t, srv.URL)
+	attempts, err := webhook.deliver(context.Background(), "1", "", []byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+	assert.Zero(t, requests.Load())
+
+	webhook = newTestWebhook(t, "http://[::1")
+	attempts, err = webhook.deliver(context.Background(), "2", "", []byte(`{}`))
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+
+	assert.True(t, retryable(&url.Error{Op: "Post", URL: srv.URL, Err: errors.New("connection refused")}))
+}
+
+func TestWebhookFormatter(t *testing.T) {
+	var body []byte
+	var contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cef, err := format.New("cef", format.Options{Hostname: "web-1"})
+	require.NoError(t, err)
+	webhook := newTestWebhook(t, srv.URL)
+	WithWebhookFormatter(cef)(webhook)
+
+	events := []monitoring.FileEvent{
+		{ID: "1", Action: monitoring.ActionCreated, Path: "/etc/a", TargetPath: "/etc/a"},
+		{ID: "2", Action: monitoring.ActionDeleted, Path: "/etc/b", TargetPath: "/etc/b"},
+	}
+	require.NoError(t, webhook.Send(context.Background(), events))
+	assert.Equal(t, "text/plain; charset=utf-8", contentType)
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "CEF:0|FileModTracker|FileModTracker|1.0|CREATED|File created|"))
+	assert.Contains(t, lines[1], "externalId=2")
+}
+
+func TestWebhookAlerts(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusNoContent)
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.True(t, Verify("s3cret", timestamp, body, r.Header.Get(SignatureHeader)))
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	webhook := newTestWebhook(t, srv.URL)
+	alerts := []alert.Alert{{ID: "1", RuleID: "etc-changed", Severity: "high", DedupKey: "etc-changed", Count: 1}}
+	require.NoError(t, webhook.SendAlerts(context.Background(), alerts))
+	var payload struct {
+		Alerts []alert.Alert `json:"alerts"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, alerts, payload.Alerts)
+
+	// Undeliverable alerts go to the dead-letter queue like events.
+	status.Store(http.StatusBadRequest)
+	require.NoError(t, webhook.SendAlerts(context.Background(), alerts))
+	letters, err := webhook.DeadLetters().List()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 1, letters[0].Alerts)
+	assert.Zero(t, letters[0].Events)
+	assert.JSONEq(t, string(body), letters[0].Payload)
+
+	leef, err := format.New("leef", format.Options{Hostname: "web-1"})
+	require.NoError(t, err)
+	WithWebhookFormatter(leef)(webhook)
+	status.Store(http.StatusOK)
+	require.NoError(t, webhook.SendAlerts(context.Background(), alerts))
+	assert.True(t, strings.HasPrefix(string(body), "LEEF:2.0|FileModTracker|FileModTracker|1.0|etc-changed|x09|"), string(body))
+}
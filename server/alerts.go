@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+)
+
+// listAlerts returns the alerts raised by alert rules, newest first,
+// selected by ?rule, ?severity, ?dedup_key, ?since, ?until and ?limit.
+func (h *Handler) listAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.alerts == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "no alert rules are configured"})
+			return
+		}
+
+		query, err := alertQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		alerts, err := h.alerts.List(query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if alerts == nil {
+			alerts = []alert.Alert{}
+		}
+		c.JSON(http.StatusOK, alerts)
+	}
+}
+
+// alertQuery reads the /alerts query parameters.
+func alertQuery(c *gin.Context) (alert.Query, error) {
+	query := alert.Query{
+		RuleIDs:    listParam(c, "rule"),
+		Severities: listParam(c, "severity"),
+		DedupKey:   c.Query("dedup_key"),
+	}
+	var err error
+	if query.Since, err = timeParam(c, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = timeParam(c, "until"); err != nil {
+		return query, err
+	}
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 || query.Limit > alert.MaxListLimit {
+			return query, fmt.Errorf("invalid limit %q: want 1 to %d", value, alert.MaxListLimit)
+		}
+	}
+	return query, nil
+}
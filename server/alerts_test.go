@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/alert"
+	"github.com/tejiriaustin/savannah-assessment/config"
+	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/store"
+)
+
+func TestHandler_ListAlerts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+
+	get := func(router http.Handler, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without rules there is nothing to list.
+	router := NewHandler(newLogger).SetupHandler(new(MockMonitor), make(chan daemon.Command))
+	assert.Equal(t, http.StatusNotImplemented, get(router, "/alerts").Code)
+
+	s, err := store.Open(t.TempDir())
+	require.NoError(t, err)
+	alerts := alert.NewStore(s)
+	defer alerts.Close()
+
+	var rules []*alert.Rule
+	for _, cfg := range []config.Rule{
+		{ID: "etc-changed", Severity: "high", Paths: []string{"/etc/"}},
+		{ID: "deleted", Severity: "low", Actions: []string{"deleted"}},
+	} {
+		rule, err := alert.NewRule(cfg)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+
+	monitor := newStreamMonitor()
+	monitor.publish(monitoring.ActionCreated, "/etc/a")
+	monitor.publish(monitoring.ActionDeleted, "/var/log/b")
+	monitor.publish(monitoring.ActionDeleted, "/etc/c")
+	engine := alert.NewEngine(rules, monitor, alerts, newLogger)
+	_, err = engine.Evaluate(context.Background(), monitoring.Cursor{Stream: "test"})
+	require.NoError(t, err)
+
+	router = NewHandler(newLogger, WithAlerts(alerts)).SetupHandler(monitor, make(chan daemon.Command))
+	list := func(url string) []string {
+		w := get(router, url)
+		require.Equal(t, http.StatusOK, w.Code, url)
+		var response []alert.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		var result []string
+		for _, a := range response {
+			result = append(result, a.RuleID+" "+a.Events[0].TargetPath)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"deleted /etc/c", "etc-changed /etc/c", "deleted /var/log/b", "etc-changed /etc/a"}, list("/alerts"))
+	assert.Equal(t, []string{"etc-changed /etc/c", "etc-changed /etc/a"}, list("/alerts?rule=etc-changed"))
+	assert.Equal(t, []string{"deleted /etc/c"}, list("/alerts?severity=low&since=102"))
+	assert.Equal(t, []string{"etc-changed /etc/a"}, list("/alerts?until=101&limit=1"))
+	assert.Nil(t, list("/alerts?dedup_key=nothing"))
+	assert.Equal(t, "[]", get(router, "/alerts?dedup_key=nothing").Body.String())
+
+	for _, url := range []string{"/alerts?limit=0", "/alerts?since=yesterday"} {
+		assert.Equal(t, http.StatusBadRequest, get(router, url).Code, url)
+	}
+}
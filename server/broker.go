@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+type (
+	// streamItem is an event on its way to stream clients, with the cursor
+	// a client resumes from after it.
+	streamItem struct {
+		event  monitoring.FileEvent
+		cursor monitoring.Cursor
+	}
+
+	// broker reads new events from an event source once for all stream
+	// clients and hands each client a copy through its own bounded buffer.
+	// It only runs while clients are subscribed.
+	broker struct {
+		source       monitoring.EventSource
+		bufferSize   int
+		dropSlow     bool
+		pollInterval time.Duration
+		log          *logger.Logger
+
+		mutex   sync.Mutex
+		clients map[*subscriber]struct{}
+		cursor  monitoring.Cursor
+		stop    chan struct{}
+	}
+
+	// subscriber is one client's view of the broker. A client that falls a
+	// whole buffer behind either loses events, counted in dropped, or is
+	// disconnected by closing overflow.
+	subscriber struct {
+		items    chan streamItem
+		overflow chan struct{}
+		dropped  atomic.Int64
+	}
+)
+
+func newBroker(source monitoring.EventSource, bufferSize int, dropSlow bool, pollInterval time.Duration, log *logger.Logger) *broker {
+	return &broker{
+		source:       source,
+		bufferSize:   bufferSize,
+		dropSlow:     dropSlow,
+		pollInterval: pollInterval,
+		log:          log,
+		clients:      make(map[*subscriber]struct{}),
+	}
+}
+
+// subscribe registers a client. Events after the returned cursor reach it
+// through its buffer; anything before has to be read from the source.
+func (b *broker) subscribe(ctx context.Context) (*subscriber, monitoring.Cursor, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.stop == nil {
+		head, err := b.source.Head(ctx)
+		if err != nil {
+			return nil, monitoring.Cursor{}, err
+		}
+		b.cursor = head
+		b.stop = make(chan struct{})
+		go b.run(b.stop)
+	}
+
+	sub := &subscriber{
+		items:    make(chan streamItem, b.bufferSize),
+		overflow: make(chan struct{}),
+	}
+	b.clients[sub] = struct{}{}
+	return sub, b.cursor, nil
+}
+
+func (b *broker) unsubscribe(sub *subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.remove(sub)
+}
+
+// remove drops sub and stops the broker with its last client. The caller
+// holds the mutex.
+func (b *broker) remove(sub *subscriber) {
+	if _, ok := b.clients[sub]; !ok {
+		return
+	}
+	delete(b.clients, sub)
+	if len(b.clients) == 0 && b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+// run reads new events whenever the source reports some, or every
+// pollInterval if it cannot.
+func (b *broker) run(stop <-chan struct{}) {
+	notifier, _ := b.source.(monitoring.Notifier)
+	var tick <-chan time.Time
+	if notifier == nil {
+		ticker := time.NewTicker(b.pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		var updated <-chan struct{}
+		if notifier != nil {
+			updated = notifier.Updated()
+		}
+		b.poll(ctx, stop)
+
+		select {
+		case <-stop:
+			return
+		case <-updated:
+		case <-tick:
+		}
+	}
+}
+
+// poll hands the events after the broker's cursor to every client.
+func (b *broker) poll(ctx context.Context, stop <-chan struct{}) {
+	b.mutex.Lock()
+	cursor := b.cursor
+	b.mutex.Unlock()
+
+	for {
+		events, next, err := b.source.EventsSince(ctx, cursor)
+		if err != nil {
+			if ctx.Err() == nil {
+				b.log.Error("Failed to read events for streaming", "error", err)
+			}
+			return
+		}
+
+		b.mutex.Lock()
+		if b.stop != stop {
+			// Every client left, and maybe new ones arrived with a new run.
+			b.mutex.Unlock()
+			return
+		}
+		for _, event := range events {
+			item := streamItem{event: event, cursor: next.At(event)}
+			for sub := range b.clients {
+				b.offer(sub, item)
+			}
+		}
+		b.cursor = next
+		b.mutex.Unlock()
+
+		if len(events) == 0 {
+			return
+		}
+		cursor = next
+	}
+}
+
+// offer queues item for sub without waiting. The caller holds the mutex.
+func (b *broker) offer(sub *subscriber, item streamItem) {
+	select {
+	case sub.items <- item:
+	default:
+		if b.dropSlow {
+			sub.dropped.Add(1)
+			return
+		}
+		b.remove(sub)
+		close(sub.overflow)
+	}
+}
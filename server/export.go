@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/savannah-assessment/format"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	// ExportCountTrailer is a trailer of /events/export holding the number
+	// of events written. It is missing if the export broke off.
+	ExportCountTrailer = "X-Export-Events"
+	// ExportErrorTrailer is a trailer of /events/export describing the
+	// error an export stopped at.
+	ExportErrorTrailer = "X-Export-Error"
+
+	defaultExportFormat = "json"
+)
+
+// exportEvents writes every event selected by the /events filters, oldest
+// first, one record per line in ?format (json by default, or any name
+// format.New knows). Records are written as they are read, so exports do
+// not have to fit in memory. Once records are sent errors can only be
+// reported in the trailers, so clients should check them.
+func (h *Handler) exportEvents(monitor monitoring.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := eventFilters(c)
+		if err == nil {
+			err = query.Validate()
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		formatter, err := format.New(c.DefaultQuery("format", defaultExportFormat), h.formatOptions)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Trailer", ExportCountTrailer+", "+ExportErrorTrailer)
+		c.Header("Content-Type", formatter.ContentType())
+		c.Status(http.StatusOK)
+
+		w := bufio.NewWriterSize(c.Writer, 64<<10)
+		if header, ok := formatter.(format.HeaderFormatter); ok {
+			w.Write(header.Header())
+			w.WriteByte('\n')
+		}
+		count := 0
+		err = monitoring.EachEvent(c.Request.Context(), monitor, query, func(event monitoring.FileEvent) error {
+			record, err := formatter.Format(event)
+			if err != nil {
+				return err
+			}
+			w.Write(record)
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			h.logger.Error("Event export failed", "events", count, "error", err)
+			if !c.Writer.Written() {
+				// Nothing was sent yet, so the status can still tell.
+				c.Writer.Header().Del("Trailer")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Writer.Header().Set(ExportErrorTrailer, err.Error())
+			return
+		}
+		c.Writer.Header().Set(ExportCountTrailer, strconv.Itoa(count))
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+func TestHandler_ExportEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+
+	monitor := newStreamMonitor()
+	monitor.publish(monitoring.ActionCreated, "/etc/a")
+	monitor.publish(monitoring.ActionUpdated, "/var/log/b")
+	monitor.publish(monitoring.ActionUpdated, "/etc/c")
+	monitor.publish(monitoring.ActionDeleted, "/etc/a")
+	router := NewHandler(newLogger).SetupHandler(monitor, make(chan daemon.Command))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	export := func(query string) (*http.Response, string) {
+		resp, err := http.Get(srv.URL + "/events/export" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	// JSON lines, oldest first, filtered like /events. The count arrives
+	// in a trailer once the body is read.
+	resp, body := export("?path=/etc/&until=103")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	var paths []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		paths = append(paths, eventPath(t, scanner.Text()))
+	}
+	assert.Equal(t, []string{"/etc/a", "/etc/c"}, paths)
+	assert.Equal(t, "2", resp.Trailer.Get(ExportCountTrailer))
+	assert.Empty(t, resp.Trailer.Get(ExportErrorTrailer))
+
+	resp, body = export("?format=csv&action=UPDATED")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+	rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "id", rows[0][0])
+	assert.Equal(t, "/var/log/b", rows[1][4])
+	assert.Equal(t, "2", resp.Trailer.Get(ExportCountTrailer))
+
+	// Nothing matches: an empty body that still says it is complete.
+	resp, body = export("?path=/nowhere")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, body)
+	assert.Equal(t, "0", resp.Trailer.Get(ExportCountTrailer))
+
+	for _, query := range []string{"?format=xml", "?since=yesterday", "?uid=-1"} {
+		resp, _ := export(query)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+	}
+}
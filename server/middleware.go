@@ -9,8 +9,14 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/savannah-assessment/metrics"
 )
 
+// unmatchedRoute labels the metrics of requests no route matched, so
+// scanners cannot create a series per path they try.
+const unmatchedRoute = "unmatched"
+
 func (h *Handler) loggerMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
@@ -63,3 +69,18 @@ func (h *Handler) loggerMiddleware() gin.HandlerFunc {
 		}
 	}
 }
+
+// metricsMiddleware counts requests and their durations per route pattern.
+func (h *Handler) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.HTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// nextCursorHeader carries the cursor of the next page of /events, which
+// keeps the response body a plain array of events.
+const nextCursorHeader = "X-Next-Cursor"
+
+// defaultHistogramBucket is the bucket width of /events/histogram.
+const defaultHistogramBucket = "5m"
+
+// eventQuery reads the /events query parameters.
+func eventQuery(c *gin.Context) (monitoring.EventQuery, error) {
+	query, err := eventFilters(c)
+	if err != nil {
+		return query, err
+	}
+	query.Order = monitoring.Order(strings.ToLower(c.Query("order")))
+	query.Cursor = c.Query("cursor")
+	if value := c.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+	return query, query.Validate()
+}
+
+// eventFilters reads the query parameters that select events, leaving out
+// those for paging. Parameters that can be given several times also accept
+// comma separated values.
+func eventFilters(c *gin.Context) (monitoring.EventQuery, error) {
+	query := monitoring.EventQuery{
+		Path:       c.Query("path"),
+		Categories: listParam(c, "category"),
+		Hash:       c.Query("hash"),
+	}
+	for _, action := range listParam(c, "action") {
+		query.Actions = append(query.Actions, monitoring.Action(strings.ToUpper(action)))
+	}
+
+	var err error
+	if query.Since, err = timeParam(c, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = timeParam(c, "until"); err != nil {
+		return query, err
+	}
+	if value := c.Query("uid"); value != "" {
+		uid, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return query, fmt.Errorf("invalid uid %q", value)
+		}
+		query.UID = new(uint32)
+		*query.UID = uint32(uid)
+	}
+	return query, nil
+}
+
+func listParam(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range c.QueryArray(name) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// timeParam reads a time given in RFC 3339 or as Unix seconds.
+func timeParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: want RFC 3339 or Unix seconds", name, value)
+	}
+	return t, nil
+}
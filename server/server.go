@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -13,11 +14,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/tejiriaustin/savannah-assessment/alert"
 	"github.com/tejiriaustin/savannah-assessment/config"
 	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/format"
 	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/metrics"
 	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/tracing"
 )
 
 type Server struct {
@@ -35,10 +44,15 @@ func New(cfg *config.Config, logger *logger.Logger) *Server {
 }
 
 func (s *Server) Start(handler http.Handler) error {
+	// Requests, including open event streams, end when the server shuts down.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
 	srv := &http.Server{
-		Addr:    s.cfg.Port,
-		Handler: handler,
+		Addr:        s.cfg.Port,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 	}
+	srv.RegisterOnShutdown(cancelRequests)
 
 	errChan := make(chan error, 1)
 	quit := make(chan os.Signal, 1)
@@ -75,23 +89,99 @@ func (s *Server) Start(handler http.Handler) error {
 // Handler struct responsible for HTTP routing and handling
 type Handler struct {
 	logger *logger.Logger
+
+	streamBuffer       int
+	dropSlow           bool
+	heartbeat          time.Duration
+	streamPollInterval time.Duration
+	formatOptions      format.Options
+	traceService       string
+	alerts             *alert.Store
 }
 
-func NewHandler(logger *logger.Logger) *Handler {
-	return &Handler{
-		logger: logger,
+type HandlerOption func(*Handler)
+
+// WithStreamBuffer sets how many events /events/stream queues per client.
+// A client that falls further behind is disconnected, or with dropSlow has
+// the events that do not fit dropped.
+func WithStreamBuffer(size int, dropSlow bool) HandlerOption {
+	return func(h *Handler) {
+		h.streamBuffer = size
+		h.dropSlow = dropSlow
+	}
+}
+
+// WithHeartbeat sets how often /events/stream tells idle clients it is
+// still there.
+func WithHeartbeat(interval time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.heartbeat = interval
+	}
+}
+
+// WithStreamPollInterval sets how often /events/stream looks for new events
+// when the monitor cannot report them itself.
+func WithStreamPollInterval(interval time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.streamPollInterval = interval
+	}
+}
+
+// WithFormatOptions sets the vendor, product and signature IDs of CEF,
+// LEEF, ECS and OCSF records written by /events/export.
+func WithFormatOptions(opts format.Options) HandlerOption {
+	return func(h *Handler) {
+		h.formatOptions = opts
+	}
+}
+
+// WithTracing traces requests as spans of service, continuing the trace of
+// requests that carry a W3C traceparent header.
+func WithTracing(service string) HandlerOption {
+	return func(h *Handler) {
+		h.traceService = service
+	}
+}
+
+// WithAlerts serves the alerts in alerts at /alerts.
+func WithAlerts(alerts *alert.Store) HandlerOption {
+	return func(h *Handler) {
+		h.alerts = alerts
+	}
+}
+
+func NewHandler(logger *logger.Logger, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		logger:             logger,
+		streamBuffer:       defaultStreamBuffer,
+		heartbeat:          defaultHeartbeat,
+		streamPollInterval: defaultStreamPollInterval,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 func (h *Handler) SetupHandler(monitor monitoring.Monitor, cmdChan chan<- daemon.Command) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 
+	if h.traceService != "" {
+		r.Use(otelgin.Middleware(h.traceService))
+	}
 	r.Use(h.loggerMiddleware())
+	r.Use(h.metricsMiddleware())
 	r.Use(gin.Recovery())
 
 	r.GET("/health", h.healthCheck())
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
 	r.GET("/events", h.retrieveEvents(monitor))
+	r.GET("/events/summary", h.summarizeEvents(monitor))
+	r.GET("/events/histogram", h.eventHistogram(monitor))
+	r.GET("/events/stream", h.streamEvents(monitor))
+	r.GET("/events/export", h.exportEvents(monitor))
+	r.GET("/alerts", h.listAlerts())
 	r.POST("/command", h.receiveCommand(cmdChan))
 	r.POST("/execute", h.executeCommand())
 
@@ -114,12 +204,74 @@ func (h *Handler) healthCheck() gin.HandlerFunc {
 
 func (h *Handler) retrieveEvents(monitor monitoring.Monitor) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		query, err := monitor.GetFileEvents()
+		query, err := eventQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var page monitoring.EventPage
+		// ?raw=true returns every row behind the logical events.
+		if raw, ok := monitor.(monitoring.RawEventSource); ok && c.Query("raw") == "true" {
+			page, err = monitoring.QueryRawEvents(c.Request.Context(), raw, query)
+		} else {
+			page, err = monitoring.QueryEvents(c.Request.Context(), monitor, query)
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, query)
+		if page.NextCursor != "" {
+			c.Header(nextCursorHeader, page.NextCursor)
+		}
+		c.JSON(http.StatusOK, page.Events)
+	}
+}
+
+// summarizeEvents counts the events selected by the /events filters per
+// action.
+func (h *Handler) summarizeEvents(monitor monitoring.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := eventQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		summary, err := monitoring.SummarizeEvents(c.Request.Context(), monitor, query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// eventHistogram counts the events selected by the /events filters per
+// ?bucket of time, one series per ?group_by value.
+func (h *Handler) eventHistogram(monitor monitoring.Monitor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query, err := eventQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bucket, err := time.ParseDuration(c.DefaultQuery("bucket", defaultHistogramBucket))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid bucket: %v", err)})
+			return
+		}
+		groupBy := monitoring.GroupBy(c.Query("group_by"))
+
+		series, err := monitoring.EventHistogram(c.Request.Context(), monitor, query, bucket, groupBy)
+		if errors.Is(err, monitoring.ErrInvalidHistogram) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, series)
 	}
 }
 
@@ -140,10 +292,17 @@ func (h *Handler) receiveCommand(cmdChan chan<- daemon.Command) gin.HandlerFunc
 			return
 		}
 
+		// The send blocks while the queue is full, which the span shows.
+		_, span := tracing.Tracer().Start(c.Request.Context(), "command.enqueue",
+			trace.WithAttributes(attribute.String("process.command", sanitizedCmd[0])))
 		cmdChan <- daemon.Command{
-			Command: sanitizedCmd[0],
-			Args:    sanitizedCmd[1:],
+			Command:     sanitizedCmd[0],
+			Args:        sanitizedCmd[1:],
+			SpanContext: trace.SpanContextFromContext(c.Request.Context()),
+			Queued:      time.Now(),
 		}
+		span.End()
+		metrics.CommandReceived()
 
 		c.JSON(http.StatusOK, gin.H{"status": "command received"})
 	}
@@ -165,11 +324,20 @@ func (h *Handler) executeCommand() gin.HandlerFunc {
 			return
 		}
 
+		metrics.CommandReceived()
+		_, span := tracing.Tracer().Start(c.Request.Context(), "command.execute",
+			trace.WithAttributes(attribute.String("process.command", sanitizedCmd[0])))
 		command := exec.Command(sanitizedCmd[0], sanitizedCmd...)
 		var out bytes.Buffer
 		command.Stdout = &out
 		command.Stderr = &out
 		err = command.Run()
+		metrics.CommandExecuted(err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "command execution failed")
+		}
+		span.End()
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("command execution failed: %v, output: %s", err, out.String())})
 			return
@@ -13,9 +13,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/tejiriaustin/savannah-assessment/config"
 	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+	"github.com/tejiriaustin/savannah-assessment/tracing"
 )
 
 // MockMonitor is a mock implementation of the monitoring.Monitor interface
@@ -38,19 +43,19 @@ func (m *MockMonitor) Wait() error {
 	return args.Error(0)
 }
 
-func (m *MockMonitor) GetFileEvents() ([]map[string]interface{}, error) {
+func (m *MockMonitor) GetFileEvents(ctx context.Context) ([]monitoring.FileEvent, error) {
 	args := m.Called()
-	return args.Get(0).([]map[string]interface{}), args.Error(1)
+	return args.Get(0).([]monitoring.FileEvent), args.Error(1)
 }
 
-func (m *MockMonitor) GetFileEventsByPath(path string, since time.Time) ([]map[string]interface{}, error) {
+func (m *MockMonitor) GetFileEventsByPath(ctx context.Context, path string, since time.Time) ([]monitoring.FileEvent, error) {
 	args := m.Called(path, since)
-	return args.Get(0).([]map[string]interface{}), args.Error(1)
+	return args.Get(0).([]monitoring.FileEvent), args.Error(1)
 }
 
-func (m *MockMonitor) GetFileChangesSummary(since time.Time) ([]map[string]interface{}, error) {
+func (m *MockMonitor) GetFileChangesSummary(ctx context.Context, since time.Time) ([]monitoring.ActionSummary, error) {
 	args := m.Called(since)
-	return args.Get(0).([]map[string]interface{}), args.Error(1)
+	return args.Get(0).([]monitoring.ActionSummary), args.Error(1)
 }
 
 func TestNew(t *testing.T) {
@@ -66,7 +71,15 @@ func TestHandler_Endpoints(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockMonitor := new(MockMonitor)
-	mockEvents := []map[string]interface{}{{"dummy_event": "some_event"}}
+	mockEvents := []monitoring.FileEvent{{
+		ID:         "1",
+		Time:       time.Unix(1700000000, 0).UTC(),
+		Action:     monitoring.ActionCreated,
+		Path:       "/tmp/file",
+		TargetPath: "/tmp/file",
+		Category:   "tmp",
+		Severity:   "info",
+	}}
 	mockMonitor.On("GetFileEvents").Return(mockEvents, nil)
 
 	cmdChan := make(chan daemon.Command, 1)
@@ -127,7 +140,16 @@ func TestHandler_Endpoints(t *testing.T) {
 			method:         "GET",
 			url:            "/events",
 			expectedStatus: http.StatusOK,
-			expectedBody:   []interface{}{map[string]interface{}{"dummy_event": "some_event"}},
+			expectedBody: []interface{}{map[string]interface{}{
+				"version":     float64(monitoring.EventVersion),
+				"id":          "1",
+				"time":        "2023-11-14T22:13:20Z",
+				"action":      "CREATED",
+				"path":        "/tmp/file",
+				"target_path": "/tmp/file",
+				"category":    "tmp",
+				"severity":    "info",
+			}},
 		},
 	}
 
@@ -164,6 +186,137 @@ func TestHandler_Endpoints(t *testing.T) {
 	mockMonitor.AssertExpectations(t)
 }
 
+func TestHandler_RawEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockMonitor := new(MockMonitor)
+	mockEvents := []monitoring.FileEvent{
+		{ID: "1", Time: time.Unix(100, 0), Action: monitoring.ActionUpdated, Path: "/etc/app.yaml", TargetPath: "/etc/app.yaml"},
+		{ID: "2", Time: time.Unix(101, 0), Action: monitoring.ActionUpdated, Path: "/etc/app.yaml", TargetPath: "/etc/app.yaml"},
+	}
+	mockMonitor.On("GetFileEvents").Return(mockEvents, nil)
+
+	newLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	router := NewHandler(newLogger).SetupHandler(monitoring.NewCoalescing(mockMonitor, 2*time.Second), make(chan daemon.Command))
+
+	for url, expected := range map[string]int{"/events": 1, "/events?raw=true": 2} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response, expected, url)
+	}
+}
+
+func TestHandler_QueryEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockMonitor := new(MockMonitor)
+	mockEvents := []monitoring.FileEvent{
+		{ID: "1", Time: time.Unix(101, 0), Action: monitoring.ActionUpdated, TargetPath: "/etc/hosts"},
+		{ID: "2", Time: time.Unix(102, 0), Action: monitoring.ActionDeleted, TargetPath: "/etc/passwd"},
+		{ID: "3", Time: time.Unix(103, 0), Action: monitoring.ActionUpdated, TargetPath: "/etc/group"},
+	}
+	mockMonitor.On("GetFileEventsByPath", "/etc/", time.Unix(100, 0).UTC()).Return(mockEvents, nil)
+
+	newLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+	router := NewHandler(newLogger).SetupHandler(mockMonitor, make(chan daemon.Command))
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	ids := func(w *httptest.ResponseRecorder) []string {
+		var response []monitoring.FileEvent
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		var result []string
+		for _, event := range response {
+			result = append(result, event.ID)
+		}
+		return result
+	}
+
+	w := get("/events?path=/etc/&since=100&action=updated&order=desc&limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"3"}, ids(w))
+	cursor := w.Header().Get(nextCursorHeader)
+	assert.NotEmpty(t, cursor)
+
+	w = get("/events?path=/etc/&since=100&action=updated&order=desc&limit=1&cursor=" + cursor)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"1"}, ids(w))
+	assert.Empty(t, w.Header().Get(nextCursorHeader))
+
+	w = get("/events?path=/etc/*&since=1970-01-01T00:01:40Z&until=102&action=UPDATED,DELETED")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"1", "2"}, ids(w))
+
+	for _, url := range []string{
+		"/events?limit=0",
+		"/events?limit=100000",
+		"/events?order=random",
+		"/events?since=yesterday",
+		"/events?uid=-1",
+		"/events?cursor=bogus",
+	} {
+		assert.Equal(t, http.StatusBadRequest, get(url).Code, url)
+	}
+	mockMonitor.AssertExpectations(t)
+}
+
+func TestHandler_SummaryAndHistogram(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	since := time.Unix(0, 0).UTC()
+	mockMonitor := new(MockMonitor)
+	mockMonitor.On("GetFileChangesSummary", since).Return([]monitoring.ActionSummary{{
+		Action:          monitoring.ActionCreated,
+		Count:           2,
+		FirstOccurrence: time.Unix(100, 0).UTC(),
+		LastOccurrence:  time.Unix(200, 0).UTC(),
+	}}, nil)
+	mockMonitor.On("GetFileEventsByPath", "", since).Return([]monitoring.FileEvent{
+		{ID: "1", Time: time.Unix(100, 0), Action: monitoring.ActionCreated, TargetPath: "/etc/hosts"},
+		{ID: "2", Time: time.Unix(200, 0), Action: monitoring.ActionCreated, TargetPath: "/etc/passwd"},
+	}, nil)
+
+	newLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+	router := NewHandler(newLogger).SetupHandler(mockMonitor, make(chan daemon.Command))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/events/summary?since=0", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"action":"CREATED","count":2,"first_occurrence":"1970-01-01T00:01:40Z","last_occurrence":"1970-01-01T00:03:20Z"}]`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/events/histogram?since=0&until=299&bucket=100s&group_by=action", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"group":"CREATED","buckets":[
+		{"time":"1970-01-01T00:00:00Z","count":0},
+		{"time":"1970-01-01T00:01:40Z","count":1},
+		{"time":"1970-01-01T00:03:20Z","count":1}
+	]}]`, w.Body.String())
+
+	for _, url := range []string{"/events/histogram?bucket=soon", "/events/histogram?group_by=owner"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+	mockMonitor.AssertExpectations(t)
+}
+
 func TestServer_setupRouter(t *testing.T) {
 	mockLogger, err := logger.NewLogger(logger.Config{})
 	assert.NoError(t, err)
@@ -177,7 +330,7 @@ func TestServer_setupRouter(t *testing.T) {
 	assert.NotNil(t, router)
 
 	// Check if all expected routes are set up
-	expectedRoutes := []string{"/health", "/events", "/command", "/execute"}
+	expectedRoutes := []string{"/health", "/metrics", "/events", "/events/summary", "/events/histogram", "/events/stream", "/events/export", "/alerts", "/command", "/execute"}
 	routes := router.Routes()
 
 	assert.Len(t, routes, len(expectedRoutes))
@@ -186,3 +339,64 @@ func TestServer_setupRouter(t *testing.T) {
 		assert.Contains(t, expectedRoutes, route.Path)
 	}
 }
+
+func TestHandler_Metrics(t *testing.T) {
+	newLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+
+	cmdChan := make(chan daemon.Command, 1)
+	router := NewHandler(newLogger).SetupHandler(new(MockMonitor), cmdChan)
+
+	for _, url := range []string{"/health", "/no/such/page"} {
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("POST", "/command", bytes.NewBufferString(`{"command":"ls -l"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	<-cmdChan
+
+	w := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	body := w.Body.String()
+	assert.Contains(t, body, `filemodtracker_http_requests_total{code="200",method="GET",route="/health"}`)
+	assert.Contains(t, body, `filemodtracker_http_requests_total{code="404",method="GET",route="unmatched"}`)
+	assert.Contains(t, body, `filemodtracker_http_request_duration_seconds_bucket{method="POST",route="/command"`)
+	assert.Contains(t, body, "filemodtracker_commands_received_total")
+	assert.NotContains(t, body, "/no/such/page")
+}
+
+func TestHandler_CommandTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	_, err := tracing.Setup(context.Background(), config.Tracing{})
+	assert.NoError(t, err)
+
+	newLogger, err := logger.NewLogger(logger.Config{})
+	assert.NoError(t, err)
+	cmdChan := make(chan daemon.Command, 1)
+	router := NewHandler(newLogger, WithTracing("test")).SetupHandler(new(MockMonitor), cmdChan)
+
+	req, _ := http.NewRequest("POST", "/command", bytes.NewBufferString(`{"command":"ls -l"}`))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cmd := <-cmdChan
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", cmd.SpanContext.TraceID().String())
+	assert.False(t, cmd.Queued.IsZero())
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, cmd.SpanContext.TraceID(), span.SpanContext().TraceID())
+		names = append(names, span.Name())
+	}
+	assert.ElementsMatch(t, []string{"command.enqueue", "/command"}, names)
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+const (
+	defaultStreamBuffer       = 1024
+	defaultHeartbeat          = 15 * time.Second
+	defaultStreamPollInterval = time.Second
+)
+
+type (
+	// streamWriter sends stream messages in the format of one transport.
+	streamWriter interface {
+		event(item streamItem) error
+		dropped(count int64) error
+		overflow() error
+		heartbeat() error
+	}
+
+	// sseWriter writes Server-Sent Events. Each event's id is the cursor to
+	// resume after it, which browsers send back as Last-Event-ID.
+	sseWriter struct {
+		w http.ResponseWriter
+	}
+
+	// wsWriter sends one JSON message per WebSocket frame.
+	wsWriter struct {
+		conn *websocket.Conn
+	}
+
+	// streamMessage is a WebSocket message. Type is "event", "dropped",
+	// "overflow" or "heartbeat".
+	streamMessage struct {
+		Type    string                `json:"type"`
+		Cursor  string                `json:"cursor,omitempty"`
+		Event   *monitoring.FileEvent `json:"event,omitempty"`
+		Dropped int64                 `json:"dropped,omitempty"`
+	}
+)
+
+// streamEvents pushes events to the client as they are collected, over
+// WebSocket if the request asks to upgrade and as Server-Sent Events
+// otherwise. It takes the /events filters, and a cursor from a previous
+// stream in ?cursor or the Last-Event-ID header to resume after.
+func (h *Handler) streamEvents(monitor monitoring.Monitor) gin.HandlerFunc {
+	source, ok := monitoring.EventSourceOf(monitor)
+	var b *broker
+	if ok {
+		b = newBroker(source, h.streamBuffer, h.dropSlow, h.streamPollInterval, h.logger)
+	}
+
+	return func(c *gin.Context) {
+		if b == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": monitoring.ErrCursorUnsupported.Error()})
+			return
+		}
+
+		query, err := eventFilters(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		match, err := query.Matcher()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var resume *monitoring.Cursor
+		if value := c.DefaultQuery("cursor", c.GetHeader("Last-Event-ID")); value != "" {
+			cursor, err := monitoring.ParseCursor(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			resume = &cursor
+		}
+
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			server := websocket.Server{Handler: func(conn *websocket.Conn) {
+				defer conn.Close()
+				ctx, cancel := context.WithCancel(c.Request.Context())
+				defer cancel()
+				// The client only talks to close the connection.
+				go func() {
+					_, _ = io.Copy(io.Discard, conn)
+					cancel()
+				}()
+				h.stream(ctx, b, source, match, resume, wsWriter{conn: conn})
+			}}
+			server.ServeHTTP(c.Writer, c.Request)
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+		h.stream(c.Request.Context(), b, source, match, resume, sseWriter{w: c.Writer})
+	}
+}
+
+// stream sends the events after resume, then live events, until the client
+// goes away, falls too far behind or a write fails.
+func (h *Handler) stream(ctx context.Context, b *broker, source monitoring.EventSource, match func(monitoring.FileEvent) bool,
+	resume *monitoring.Cursor, w streamWriter) {
+	sub, start, err := b.subscribe(ctx)
+	if err != nil {
+		h.logger.Error("Failed to start event stream", "error", err)
+		return
+	}
+	defer b.unsubscribe(sub)
+
+	var last monitoring.Cursor
+	if resume != nil {
+		if last, err = h.replay(ctx, source, *resume, start, match, w); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.overflow:
+			_ = w.overflow()
+			return
+		case <-heartbeat.C:
+			if err := w.heartbeat(); err != nil {
+				return
+			}
+		case item := <-sub.items:
+			if dropped := sub.dropped.Swap(0); dropped > 0 {
+				if err := w.dropped(dropped); err != nil {
+					return
+				}
+			}
+			// Skip what the replay already sent.
+			if resume != nil && last.Stream == item.cursor.Stream && !last.Before(item.cursor) {
+				continue
+			}
+			if !match(item.event) {
+				continue
+			}
+			if err := w.event(item); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// replay sends the events after resume up to start, where the client's
+// live events begin, and returns the cursor of the last one read.
+func (h *Handler) replay(ctx context.Context, source monitoring.EventSource, resume, start monitoring.Cursor,
+	match func(monitoring.FileEvent) bool, w streamWriter) (monitoring.Cursor, error) {
+	cursor := resume
+	for {
+		events, next, err := source.EventsSince(ctx, cursor)
+		if err != nil {
+			return cursor, err
+		}
+		for _, event := range events {
+			item := streamItem{event: event, cursor: next.At(event)}
+			if start.Before(item.cursor) {
+				return cursor, nil
+			}
+			if match(event) {
+				if err := w.event(item); err != nil {
+					return cursor, err
+				}
+			}
+			cursor = item.cursor
+		}
+		if len(events) == 0 {
+			return cursor, nil
+		}
+		cursor = next
+	}
+}
+
+func (s sseWriter) event(item streamItem) error {
+	data, err := json.Marshal(item.event)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("id: %s\nevent: file_event\ndata: %s\n\n", item.cursor, data))
+}
+
+func (s sseWriter) dropped(count int64) error {
+	return s.write(fmt.Sprintf("event: dropped\ndata: {\"dropped\":%d}\n\n", count))
+}
+
+func (s sseWriter) overflow() error {
+	return s.write("event: overflow\ndata: {}\n\n")
+}
+
+func (s sseWriter) heartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+func (s sseWriter) write(message string) error {
+	if _, err := io.WriteString(s.w, message); err != nil {
+		return err
+	}
+	s.w.(http.Flusher).Flush()
+	return nil
+}
+
+func (s wsWriter) event(item streamItem) error {
+	return websocket.JSON.Send(s.conn, streamMessage{Type: "event", Cursor: item.cursor.String(), Event: &item.event})
+}
+
+func (s wsWriter) dropped(count int64) error {
+	return websocket.JSON.Send(s.conn, streamMessage{Type: "dropped", Dropped: count})
+}
+
+func (s wsWriter) overflow() error {
+	return websocket.JSON.Send(s.conn, streamMessage{Type: "overflow"})
+}
+
+func (s wsWriter) heartbeat() error {
+	return websocket.JSON.Send(s.conn, streamMessage{Type: "heartbeat"})
+}
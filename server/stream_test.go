@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+
+	"github.com/tejiriaustin/savannah-assessment/daemon"
+	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
+)
+
+// streamMonitor is a monitor whose events are published by the test.
+type streamMonitor struct {
+	*MockMonitor
+
+	mutex   sync.Mutex
+	events  []monitoring.FileEvent
+	updated chan struct{}
+}
+
+func newStreamMonitor() *streamMonitor {
+	return &streamMonitor{MockMonitor: new(MockMonitor), updated: make(chan struct{})}
+}
+
+func (m *streamMonitor) publish(action monitoring.Action, path string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := len(m.events) + 1
+	m.events = append(m.events, monitoring.FileEvent{
+		ID:         strconv.Itoa(id),
+		Time:       time.Unix(int64(100+id), 0).UTC(),
+		Action:     action,
+		Path:       path,
+		TargetPath: path,
+	})
+	close(m.updated)
+	m.updated = make(chan struct{})
+}
+
+func (m *streamMonitor) EventsSince(ctx context.Context, cursor monitoring.Cursor) ([]monitoring.FileEvent, monitoring.Cursor, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if cursor.Stream != "test" {
+		cursor = monitoring.Cursor{Stream: "test"}
+	}
+	events := append([]monitoring.FileEvent(nil), m.events[cursor.Seq:]...)
+	if len(events) > 0 {
+		cursor = cursor.At(events[len(events)-1])
+	}
+	return events, cursor, nil
+}
+
+func (m *streamMonitor) Head(ctx context.Context) (monitoring.Cursor, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cursor := monitoring.Cursor{Stream: "test"}
+	if len(m.events) > 0 {
+		cursor = cursor.At(m.events[len(m.events)-1])
+	}
+	return cursor, nil
+}
+
+func (m *streamMonitor) Updated() <-chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.updated
+}
+
+type sseMessage struct {
+	id, event, data string
+}
+
+// readSSE returns the next message of an SSE stream, skipping heartbeats
+// unless heartbeats is set.
+func readSSE(t *testing.T, r *bufio.Reader, heartbeats bool) sseMessage {
+	t.Helper()
+	var msg sseMessage
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if msg != (sseMessage{}) {
+				return msg
+			}
+		case line == ": heartbeat":
+			if heartbeats {
+				return sseMessage{event: "heartbeat"}
+			}
+		case strings.HasPrefix(line, "id: "):
+			msg.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			msg.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			msg.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// openSSE connects to the stream. The client is subscribed once the first
+// heartbeat arrives.
+func openSSE(t *testing.T, ctx context.Context, url, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+func eventPath(t *testing.T, data string) string {
+	t.Helper()
+	var event monitoring.FileEvent
+	require.NoError(t, json.Unmarshal([]byte(data), &event))
+	return event.TargetPath
+}
+
+func TestHandler_StreamEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+
+	monitor := newStreamMonitor()
+	monitor.publish(monitoring.ActionUpdated, "/etc/old")
+	router := NewHandler(newLogger, WithHeartbeat(10*time.Millisecond)).SetupHandler(monitor, make(chan daemon.Command))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	// Only events collected after connecting are sent, filtered like /events.
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openSSE(t, ctx, srv.URL+"/events/stream?action=UPDATED", "")
+	assert.Equal(t, "heartbeat", readSSE(t, stream, true).event)
+	monitor.publish(monitoring.ActionCreated, "/etc/a")
+	monitor.publish(monitoring.ActionUpdated, "/etc/b")
+
+	msg := readSSE(t, stream, false)
+	assert.Equal(t, "file_event", msg.event)
+	assert.Equal(t, "/etc/b", eventPath(t, msg.data))
+	cancel()
+
+	// Reconnecting with the last id first replays what was missed.
+	monitor.publish(monitoring.ActionUpdated, "/etc/c")
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stream = openSSE(t, ctx, srv.URL+"/events/stream?action=UPDATED", msg.id)
+	assert.Equal(t, "/etc/c", eventPath(t, readSSE(t, stream, false).data))
+	assert.Equal(t, "heartbeat", readSSE(t, stream, true).event)
+	monitor.publish(monitoring.ActionUpdated, "/etc/d")
+	assert.Equal(t, "/etc/d", eventPath(t, readSSE(t, stream, false).data))
+
+	// WebSocket clients get the same events as JSON messages.
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/events/stream?path=/etc/e", "", srv.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+	var message streamMessage
+	require.NoError(t, websocket.JSON.Receive(conn, &message))
+	assert.Equal(t, "heartbeat", message.Type)
+	monitor.publish(monitoring.ActionDeleted, "/etc/d")
+	monitor.publish(monitoring.ActionDeleted, "/etc/e")
+	for message.Type == "heartbeat" {
+		require.NoError(t, websocket.JSON.Receive(conn, &message))
+	}
+	assert.Equal(t, "event", message.Type)
+	require.NotNil(t, message.Event)
+	assert.Equal(t, "/etc/e", message.Event.TargetPath)
+	assert.NotEmpty(t, message.Cursor)
+
+	for _, url := range []string{"/events/stream?cursor=nonsense", "/events/stream?uid=-1"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", url, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestBroker_SlowConsumers(t *testing.T) {
+	newLogger, err := logger.NewLogger(logger.Config{})
+	require.NoError(t, err)
+
+	for _, dropSlow := range []bool{false, true} {
+		monitor := newStreamMonitor()
+		b := newBroker(monitor, 1, dropSlow, time.Hour, newLogger)
+		sub, _, err := b.subscribe(context.Background())
+		require.NoError(t, err)
+
+		// Nobody reads, so only the first event fits the buffer.
+		monitor.publish(monitoring.ActionCreated, "/a")
+		monitor.publish(monitoring.ActionCreated, "/b")
+		monitor.publish(monitoring.ActionCreated, "/c")
+
+		if dropSlow {
+			assert.Eventually(t, func() bool { return sub.dropped.Load() == 2 }, time.Second, time.Millisecond)
+			assert.Equal(t, "/a", (<-sub.items).event.TargetPath)
+			b.unsubscribe(sub)
+			continue
+		}
+		select {
+		case <-sub.overflow:
+		case <-time.After(time.Second):
+			t.Fatal("slow consumer was not disconnected")
+		}
+		b.unsubscribe(sub)
+	}
+}
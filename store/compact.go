@@ -0,0 +1,233 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Compact enforces the retention limits and merges segments left small by
+// earlier compactions. Whole segments past a limit are deleted without being
+// read; only the segments that straddle a limit are rewritten.
+func (s *Store) Compact() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	var cutoff time.Time
+	if s.maxAge > 0 {
+		cutoff = s.now().Add(-s.maxAge)
+	}
+
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		count, size := s.totals()
+		expired := seg.newest.Before(cutoff)
+		overCount := s.maxRecords > 0 && count-seg.count >= s.maxRecords
+		overSize := s.maxSize > 0 && size-seg.size >= s.maxSize
+		if !expired && !overCount && !overSize {
+			break
+		}
+		if err := s.removeSegment(0); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(s.segments); i++ {
+		var dropCount int
+		var dropSize int64
+		if i == 0 {
+			count, size := s.totals()
+			if s.maxRecords > 0 && count > s.maxRecords {
+				dropCount = count - s.maxRecords
+			}
+			if s.maxSize > 0 && size > s.maxSize {
+				dropSize = size - s.maxSize
+			}
+		}
+		if dropCount == 0 && dropSize == 0 && !s.segments[i].oldest.Before(cutoff) {
+			continue
+		}
+
+		removed, err := s.rewrite(i, dropCount, dropSize, cutoff)
+		if err != nil {
+			return err
+		}
+		if removed {
+			i--
+		}
+	}
+
+	return s.mergeSmall()
+}
+
+func (s *Store) totals() (int, int64) {
+	var count int
+	var size int64
+	for _, seg := range s.segments {
+		count += seg.count
+		size += seg.size
+	}
+	return count, size
+}
+
+// rewrite drops the first dropCount records of segment i, then more until
+// at least dropSize bytes are gone, and any record older than cutoff. It
+// reports whether the segment ended up empty and was removed.
+func (s *Store) rewrite(i int, dropCount int, dropSize int64, cutoff time.Time) (bool, error) {
+	seg := s.segments[i]
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return false, err
+	}
+
+	var kept bytes.Buffer
+	rewritten := &segment{path: seg.path}
+	var dropped int
+	var droppedSize, offset int64
+	err = eachRecord(bytes.NewReader(data), func(r Record, end int64) bool {
+		line := data[offset:end]
+		offset = end
+		if dropped < dropCount || droppedSize < dropSize || r.Time.Before(cutoff) {
+			dropped++
+			droppedSize += int64(len(line))
+			return true
+		}
+		kept.Write(line)
+		rewritten.add(r, int64(len(line)))
+		return true
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if rewritten.count == 0 {
+		return true, s.removeSegment(i)
+	}
+	if err := s.replaceSegment(i, kept.Bytes()); err != nil {
+		return false, err
+	}
+	s.segments[i] = rewritten
+	return false, nil
+}
+
+// mergeSmall joins neighbouring segments whose combined size still fits in
+// one segment. The active segment is left alone.
+func (s *Store) mergeSmall() error {
+	for i := 0; i+2 < len(s.segments); {
+		a, b := s.segments[i], s.segments[i+1]
+		if a.size+b.size > s.segmentSize {
+			i++
+			continue
+		}
+
+		first, err := os.ReadFile(a.path)
+		if err != nil {
+			return err
+		}
+		second, err := os.ReadFile(b.path)
+		if err != nil {
+			return err
+		}
+		if err := s.replaceSegment(i, append(first, second...)); err != nil {
+			return err
+		}
+
+		merged := *a
+		merged.last = b.last
+		merged.count += b.count
+		merged.size += b.size
+		if b.oldest.Before(merged.oldest) {
+			merged.oldest = b.oldest
+		}
+		if b.newest.After(merged.newest) {
+			merged.newest = b.newest
+		}
+		s.segments[i] = &merged
+
+		if err := os.Remove(b.path); err != nil {
+			return err
+		}
+		s.segments = append(s.segments[:i+1], s.segments[i+2:]...)
+	}
+	return nil
+}
+
+// replaceSegment atomically replaces the content of segment i.
+func (s *Store) replaceSegment(i int, data []byte) error {
+	if err := s.releaseActive(i); err != nil {
+		return err
+	}
+
+	path := s.segments[i].path
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace segment: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) removeSegment(i int) error {
+	if err := s.releaseActive(i); err != nil {
+		return err
+	}
+	if i == len(s.segments)-1 {
+		if err := s.saveSeq(); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(s.segments[i].path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.segments = append(s.segments[:i], s.segments[i+1:]...)
+	return nil
+}
+
+// releaseActive closes the append handle if segment i is the one it points
+// to; the next Append reopens whatever segment is newest by then.
+func (s *Store) releaseActive(i int) error {
+	if s.active == nil || i != len(s.segments)-1 {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
+
+func (s *Store) compactLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Compact(); err != nil && !errors.Is(err, ErrClosed) && s.log != nil {
+				s.log.Error("Failed to compact event store", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,745 @@
+// Package store is an embedded, append-only log of timestamped records kept
+// in segment files on disk. Records get increasing sequence numbers that
+// survive restarts, and retention by age, total size and record count is
+// enforced by compacting the segments in the background.
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tejiriaustin/savannah-assessment/logger"
+)
+
+const (
+	segmentExt = ".jsonl"
+	// seqFile remembers the last sequence number, so numbering carries on
+	// after retention removed every segment.
+	seqFile = "last_seq"
+	// cursorFile holds the cursor saved by Commit and the sequence number of
+	// the last record it covers.
+	cursorFile = "cursor"
+
+	defaultSegmentSize     = 16 << 20
+	defaultCompactInterval = time.Hour
+)
+
+var ErrClosed = errors.New("store is closed")
+
+type (
+	// Record is one stored entry. Seq is assigned by Append; Time drives
+	// age based retention and time filtered scans. Key is an optional value
+	// scans can select records by without decoding Data.
+	Record struct {
+		Seq  uint64          `json:"seq"`
+		Time time.Time       `json:"time"`
+		Key  string          `json:"key,omitempty"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	// Filter selects records for Scan. Zero fields match every record.
+	Filter struct {
+		// AfterSeq skips records with a sequence number up to and including
+		// AfterSeq.
+		AfterSeq uint64
+		// After skips records whose Time is not after After.
+		After time.Time
+		// Until skips records whose Time is after Until.
+		Until time.Time
+		// KeyPrefix skips records whose Key does not start with it. Records
+		// stored without a Key are not skipped.
+		KeyPrefix string
+		// Reverse scans from the newest record back.
+		Reverse bool
+	}
+
+	// Stats describes the store's current contents.
+	Stats struct {
+		Records  int       `json:"records"`
+		Size     int64     `json:"size"`
+		Segments int       `json:"segments"`
+		Oldest   time.Time `json:"oldest"`
+		Newest   time.Time `json:"newest"`
+		LastSeq  uint64    `json:"last_seq"`
+	}
+
+	Store struct {
+		dir             string
+		maxAge          time.Duration
+		maxSize         int64
+		maxRecords      int
+		segmentSize     int64
+		compactInterval time.Duration
+		now             func() time.Time
+		log             *logger.Logger
+
+		mutex     sync.RWMutex
+		segments  []*segment
+		active    *os.File
+		seq       uint64
+		committed *committed
+		closed    bool
+
+		stop chan struct{}
+		done chan struct{}
+	}
+
+	// segment is one file of records in sequence order.
+	segment struct {
+		path           string
+		first, last    uint64
+		count          int
+		size           int64
+		oldest, newest time.Time
+	}
+
+	// writeMark is the state of the store before a write, for rolling it
+	// back.
+	writeMark struct {
+		seq      uint64
+		segments int
+		tail     segment
+	}
+
+	// committed is the content of the cursor file.
+	committed struct {
+		Seq    uint64          `json:"seq"`
+		Cursor json.RawMessage `json:"cursor"`
+	}
+
+	Option func(*Store)
+)
+
+// WithMaxAge drops records older than maxAge. Zero keeps records forever.
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(s *Store) {
+		s.maxAge = maxAge
+	}
+}
+
+// WithMaxSize drops the oldest records once the segments take more than
+// maxSize bytes. Zero disables the limit.
+func WithMaxSize(maxSize int64) Option {
+	return func(s *Store) {
+		s.maxSize = maxSize
+	}
+}
+
+// WithMaxRecords drops the oldest records beyond the newest maxRecords. Zero
+// disables the limit.
+func WithMaxRecords(maxRecords int) Option {
+	return func(s *Store) {
+		s.maxRecords = maxRecords
+	}
+}
+
+// WithSegmentSize sets the size at which a new segment file is started.
+func WithSegmentSize(size int64) Option {
+	return func(s *Store) {
+		s.segmentSize = size
+	}
+}
+
+// WithCompactInterval sets how often retention is enforced in the
+// background. Zero leaves compaction to explicit Compact calls.
+func WithCompactInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.compactInterval = interval
+	}
+}
+
+// WithLogger reports errors from background compaction.
+func WithLogger(log *logger.Logger) Option {
+	return func(s *Store) {
+		s.log = log
+	}
+}
+
+// Open opens the store in dir, creating it if needed, and compacts it once.
+// A record cut short by a crash at the end of the newest segment is dropped.
+func Open(dir string, opts ...Option) (*Store, error) {
+	s := &Store{
+		dir:             dir,
+		segmentSize:     defaultSegmentSize,
+		compactInterval: defaultCompactInterval,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.maxAge < 0 || s.maxSize < 0 || s.maxRecords < 0 {
+		return nil, fmt.Errorf("store retention limits must not be negative")
+	}
+	if s.segmentSize <= 0 {
+		return nil, fmt.Errorf("segment size must be positive, got %d", s.segmentSize)
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.loadCursor(); err != nil {
+		return nil, err
+	}
+	if err := s.Compact(); err != nil {
+		return nil, err
+	}
+
+	if s.compactInterval > 0 {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.compactLoop()
+	}
+	return s, nil
+}
+
+// load reads the metadata of every segment in the directory.
+func (s *Store) load() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*"+segmentExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	if data, err := os.ReadFile(filepath.Join(s.dir, seqFile)); err == nil {
+		if seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			s.seq = seq
+		}
+	}
+
+	for i, path := range paths {
+		seg, valid, err := readSegment(path)
+		if err != nil {
+			return err
+		}
+		if valid < seg.size {
+			if i != len(paths)-1 {
+				return fmt.Errorf("segment %s is corrupt at offset %d", path, valid)
+			}
+			if err := os.Truncate(path, valid); err != nil {
+				return fmt.Errorf("failed to repair segment %s: %w", path, err)
+			}
+			seg.size = valid
+		}
+		if seg.count == 0 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		s.segments = append(s.segments, seg)
+		s.seq = max(s.seq, seg.last)
+	}
+	return nil
+}
+
+// loadCursor reads the cursor saved by the last Commit and drops any records
+// appended after it, which belong to a Commit that never finished. Their
+// sequence numbers are not handed out again.
+func (s *Store) loadCursor() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state committed
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to read store cursor: %w", err)
+	}
+	s.committed = &state
+
+	for len(s.segments) > 0 {
+		seg := s.segments[len(s.segments)-1]
+		if seg.last <= state.Seq {
+			break
+		}
+		if seg.first > state.Seq {
+			if err := os.Remove(seg.path); err != nil {
+				return err
+			}
+			s.segments = s.segments[:len(s.segments)-1]
+			continue
+		}
+		if err := s.truncateSegment(len(s.segments)-1, state.Seq); err != nil {
+			return err
+		}
+	}
+	return s.saveSeq()
+}
+
+// truncateSegment cuts segment i after the record with sequence number seq.
+func (s *Store) truncateSegment(i int, seq uint64) error {
+	seg := s.segments[i]
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	kept := &segment{path: seg.path}
+	var end int64
+	err = eachRecord(f, func(r Record, offset int64) bool {
+		if r.Seq > seq {
+			return false
+		}
+		kept.add(r, offset-end)
+		end = offset
+		return true
+	})
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(seg.path, end); err != nil {
+		return fmt.Errorf("failed to roll back segment %s: %w", seg.path, err)
+	}
+	s.segments[i] = kept
+	return nil
+}
+
+func (s *Store) saveSeq() error {
+	return writeFile(filepath.Join(s.dir, seqFile), []byte(strconv.FormatUint(s.seq, 10)))
+}
+
+func (s *Store) saveCursor(cursor json.RawMessage) error {
+	state := committed{Seq: s.seq, Cursor: cursor}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	if err := writeFile(filepath.Join(s.dir, cursorFile), data); err != nil {
+		return err
+	}
+	s.committed = &state
+	return nil
+}
+
+// writeFile replaces the file at path with data in one step.
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path+".tmp", data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// readSegment scans a segment file. It returns the offset just past the last
+// complete record, which is less than the file size if the file ends in a
+// partial or corrupt record.
+func readSegment(path string) (*segment, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seg := &segment{path: path, size: info.Size()}
+	var valid int64
+	err = eachRecord(f, func(r Record, end int64) bool {
+		seg.add(r, 0)
+		valid = end
+		return true
+	})
+	seg.size = info.Size()
+	return seg, valid, err
+}
+
+// eachRecord decodes records from r, passing each with the offset just past
+// it. It stops quietly at the first line that is not a complete record.
+func eachRecord(r io.Reader, fn func(Record, int64) bool) error {
+	reader := bufio.NewReaderSize(r, 64<<10)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+
+		var record Record
+		if json.Unmarshal(line, &record) != nil {
+			return nil
+		}
+		if !fn(record, offset) {
+			return nil
+		}
+	}
+}
+
+func (seg *segment) add(r Record, size int64) {
+	if seg.count == 0 {
+		seg.first = r.Seq
+		seg.oldest, seg.newest = r.Time, r.Time
+	}
+	seg.last = r.Seq
+	seg.count++
+	seg.size += size
+	if r.Time.Before(seg.oldest) {
+		seg.oldest = r.Time
+	}
+	if r.Time.After(seg.newest) {
+		seg.newest = r.Time
+	}
+}
+
+// Append assigns each record the next sequence number and writes the batch,
+// syncing it to disk before returning.
+func (s *Store) Append(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if err := s.write(records); err != nil {
+		return err
+	}
+	if s.committed != nil {
+		// Keep the records from being taken for an unfinished Commit.
+		return s.saveCursor(s.committed.Cursor)
+	}
+	return nil
+}
+
+// Commit appends records like Append and then saves cursor, the position
+// following them in whatever they were read from. If the process dies before
+// the cursor is saved, Open drops the records again, so a reader that resumes
+// from Cursor stores every record exactly once.
+func (s *Store) Commit(records []Record, cursor json.RawMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if err := s.write(records); err != nil {
+		return err
+	}
+	return s.saveCursor(cursor)
+}
+
+// Cursor returns the cursor saved by the last Commit, or nil.
+func (s *Store) Cursor() json.RawMessage {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.committed == nil {
+		return nil
+	}
+	return s.committed.Cursor
+}
+
+// write appends records and syncs them. A batch that fails part way is
+// rolled back, so none of it is stored and Commit stays all or nothing.
+func (s *Store) write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	before := s.mark()
+	if err := s.writeBatch(records); err != nil {
+		for i := range records {
+			records[i].Seq = 0
+		}
+		if rollbackErr := s.rollback(before); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back write: %w", rollbackErr))
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) writeBatch(records []Record) error {
+	for i := range records {
+		if err := s.ensureActive(); err != nil {
+			return err
+		}
+
+		s.seq++
+		records[i].Seq = s.seq
+		line, err := json.Marshal(records[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		line = append(line, '\n')
+
+		if _, err := s.active.Write(line); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+		s.segments[len(s.segments)-1].add(records[i], int64(len(line)))
+	}
+	return s.active.Sync()
+}
+
+// mark returns the state a write starts from.
+func (s *Store) mark() writeMark {
+	m := writeMark{seq: s.seq, segments: len(s.segments)}
+	if m.segments > 0 {
+		m.tail = *s.segments[m.segments-1]
+	}
+	return m
+}
+
+// rollback returns the store to m, removing the segments started since and
+// cutting the one that was newest back to its size at the time.
+func (s *Store) rollback(m writeMark) error {
+	var err error
+	if s.active != nil {
+		err = s.active.Close()
+		s.active = nil
+	}
+	for _, seg := range s.segments[m.segments:] {
+		if removeErr := os.Remove(seg.path); removeErr != nil && !os.IsNotExist(removeErr) {
+			err = errors.Join(err, removeErr)
+		}
+	}
+	s.segments = s.segments[:m.segments]
+	if m.segments > 0 {
+		if truncateErr := os.Truncate(m.tail.path, m.tail.size); truncateErr != nil {
+			err = errors.Join(err, truncateErr)
+		}
+		*s.segments[m.segments-1] = m.tail
+	}
+	s.seq = m.seq
+	return err
+}
+
+// ensureActive opens the newest segment for appending, starting a new one
+// when there is none or it is full.
+func (s *Store) ensureActive() error {
+	var tail *segment
+	if len(s.segments) > 0 {
+		tail = s.segments[len(s.segments)-1]
+	}
+	if s.active != nil && tail != nil && tail.size < s.segmentSize {
+		return nil
+	}
+
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			return err
+		}
+		s.active = nil
+	}
+
+	if tail == nil || tail.size >= s.segmentSize {
+		tail = &segment{path: filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.seq+1, segmentExt))}
+		s.segments = append(s.segments, tail)
+	}
+
+	f, err := os.OpenFile(tail.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	s.active = f
+	return nil
+}
+
+// Scan calls fn for every record matching filter in sequence order, or the
+// reverse if filter.Reverse is set, until fn returns false.
+func (s *Store) Scan(filter Filter, fn func(Record) bool) error {
+	return s.ScanNarrowing(&filter, fn)
+}
+
+// ScanNarrowing is Scan for callers that learn while scanning which records
+// they still need. filter is checked again before every segment and record,
+// so fn can narrow it, and segments that no longer match are not read.
+//
+// The store is not locked while segments are read and fn runs, so slow
+// readers never hold up Append or Compact. Records appended after the scan
+// started are not returned. A segment that compaction removed or merged into
+// another in the meantime is looked up again.
+func (s *Store) ScanNarrowing(filter *Filter, fn func(Record) bool) error {
+	segments, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+
+	// done is the sequence number records have been handed out up to, or
+	// down to when reversed.
+	var done uint64
+	if filter.Reverse {
+		done = math.MaxUint64
+	}
+	pending := func(seq uint64) bool {
+		if filter.Reverse {
+			return seq < done
+		}
+		return seq > done
+	}
+
+	for len(segments) > 0 {
+		var seg segment
+		if filter.Reverse {
+			seg, segments = segments[len(segments)-1], segments[:len(segments)-1]
+			if !pending(seg.first) {
+				continue
+			}
+		} else {
+			seg, segments = segments[0], segments[1:]
+			if !pending(seg.last) {
+				continue
+			}
+		}
+		if !filter.overlaps(&seg) {
+			continue
+		}
+
+		data, err := os.ReadFile(seg.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			current, snapshotErr := s.snapshot()
+			if snapshotErr != nil {
+				return snapshotErr
+			}
+			if slices.ContainsFunc(current, func(other segment) bool { return other.path == seg.path }) {
+				return err
+			}
+			segments = current
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// Reversed segments are read whole and handed out backwards.
+		var records []Record
+		stopped := false
+		err = eachRecord(bytes.NewReader(data), func(r Record, _ int64) bool {
+			if r.Seq > seg.last {
+				return false
+			}
+			if !pending(r.Seq) {
+				return true
+			}
+			if filter.Reverse {
+				records = append(records, r)
+				return true
+			}
+			done = r.Seq
+			if filter.match(r) && !fn(r) {
+				stopped = true
+				return false
+			}
+			return true
+		})
+		if err != nil || stopped {
+			return err
+		}
+		for j := len(records) - 1; j >= 0; j-- {
+			done = records[j].Seq
+			if filter.match(records[j]) && !fn(records[j]) {
+				return nil
+			}
+		}
+		if filter.Reverse {
+			done = seg.first
+		} else {
+			done = seg.last
+		}
+	}
+	return nil
+}
+
+// snapshot copies the segment list. Segment files are only appended to or
+// replaced whole, so they can be read from the copy without the lock.
+func (s *Store) snapshot() ([]segment, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	segments := make([]segment, len(s.segments))
+	for i, seg := range s.segments {
+		segments[i] = *seg
+	}
+	return segments, nil
+}
+
+// overlaps reports whether seg may hold records matching the filter.
+func (f *Filter) overlaps(seg *segment) bool {
+	return seg.count > 0 && seg.last > f.AfterSeq && seg.newest.After(f.After) &&
+		(f.Until.IsZero() || !seg.oldest.After(f.Until))
+}
+
+func (f *Filter) match(r Record) bool {
+	return r.Seq > f.AfterSeq && r.Time.After(f.After) &&
+		(f.Until.IsZero() || !r.Time.After(f.Until)) &&
+		(r.Key == "" || strings.HasPrefix(r.Key, f.KeyPrefix))
+}
+
+func (s *Store) Stats() Stats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stats := Stats{LastSeq: s.seq}
+	for _, seg := range s.segments {
+		if seg.count == 0 {
+			continue
+		}
+		if stats.Records == 0 || seg.oldest.Before(stats.Oldest) {
+			stats.Oldest = seg.oldest
+		}
+		if seg.newest.After(stats.Newest) {
+			stats.Newest = seg.newest
+		}
+		stats.Records += seg.count
+		stats.Size += seg.size
+		stats.Segments++
+	}
+	return stats
+}
+
+// Close stops background compaction and closes the active segment.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mutex.Unlock()
+
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.saveSeq(); err != nil {
+		return err
+	}
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
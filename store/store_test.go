@@ -0,0 +1,306 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func records(start time.Time, n int) []Record {
+	batch := make([]Record, n)
+	for i := range batch {
+		batch[i] = Record{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i)),
+		}
+	}
+	return batch
+}
+
+func seqs(t *testing.T, s *Store, filter Filter) []uint64 {
+	t.Helper()
+	var result []uint64
+	require.NoError(t, s.Scan(filter, func(r Record) bool {
+		result = append(result, r.Seq)
+		return true
+	}))
+	return result
+}
+
+func TestAppendAndScan(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+
+	batch := records(start, 5)
+	require.NoError(t, s.Append(batch))
+	assert.Equal(t, uint64(5), batch[4].Seq)
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, seqs(t, s, Filter{}))
+	assert.Equal(t, []uint64{4, 5}, seqs(t, s, Filter{AfterSeq: 3}))
+	assert.Equal(t, []uint64{3, 4, 5}, seqs(t, s, Filter{After: start.Add(time.Minute)}))
+
+	var first Record
+	require.NoError(t, s.Scan(Filter{}, func(r Record) bool {
+		first = r
+		return false
+	}))
+	assert.JSONEq(t, `{"n":0}`, string(first.Data))
+	assert.True(t, start.Equal(first.Time))
+
+	stats := s.Stats()
+	assert.Equal(t, 5, stats.Records)
+	assert.Greater(t, stats.Segments, 1)
+	require.NoError(t, s.Close())
+
+	// Sequence numbers and records survive a restart.
+	s, err = Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Append(records(start, 1)))
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5, 6}, seqs(t, s, Filter{}))
+
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, s.Append(records(start, 1)), ErrClosed)
+}
+
+func TestScanBounds(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := Open(t.TempDir(), WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+
+	batch := records(start, 6)
+	for i, key := range []string{"/etc/a", "/var/b", "/etc/c", "", "/etc/e", "/var/f"} {
+		batch[i].Key = key
+	}
+	require.NoError(t, s.Append(batch))
+
+	assert.Equal(t, []uint64{1, 2, 3}, seqs(t, s, Filter{Until: start.Add(2 * time.Minute)}))
+	assert.Equal(t, []uint64{2, 3}, seqs(t, s, Filter{After: start, Until: start.Add(2 * time.Minute)}))
+	// Records without a key are left for the caller to check.
+	assert.Equal(t, []uint64{1, 3, 4, 5}, seqs(t, s, Filter{KeyPrefix: "/etc/"}))
+	assert.Equal(t, []uint64{6, 5, 4, 3, 2, 1}, seqs(t, s, Filter{Reverse: true}))
+	assert.Equal(t, []uint64{5, 4, 3}, seqs(t, s, Filter{AfterSeq: 2, Until: start.Add(4 * time.Minute), Reverse: true}))
+
+	// Segments outside a filter narrowed during the scan are not read.
+	require.Greater(t, len(s.segments), 2)
+	require.NoError(t, os.Remove(s.segments[len(s.segments)-1].path))
+	filter := Filter{}
+	var scanned []uint64
+	require.NoError(t, s.ScanNarrowing(&filter, func(r Record) bool {
+		scanned = append(scanned, r.Seq)
+		filter.Until = start
+		return true
+	}))
+	assert.Equal(t, []uint64{1}, scanned)
+	assert.Error(t, s.Scan(Filter{}, func(Record) bool { return true }))
+}
+
+func TestScanUnlocked(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := Open(t.TempDir(), WithSegmentSize(64), WithMaxRecords(4), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Append(records(start, 6)))
+
+	// Writers and compaction carry on while fn runs. Compaction removes the
+	// oldest segment and trims the next before the reversed scan reaches
+	// them, and records appended meanwhile are not returned.
+	var scanned []uint64
+	require.NoError(t, s.Scan(Filter{Reverse: true}, func(r Record) bool {
+		if len(scanned) == 0 {
+			done := make(chan error)
+			go func() {
+				if err := s.Append(records(start, 1)); err != nil {
+					done <- err
+					return
+				}
+				done <- s.Compact()
+			}()
+			select {
+			case err := <-done:
+				require.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("writes waited for the scan")
+			}
+		}
+		scanned = append(scanned, r.Seq)
+		return true
+	}))
+	assert.Equal(t, []uint64{6, 5, 4}, scanned)
+	assert.Equal(t, []uint64{4, 5, 6, 7}, seqs(t, s, Filter{}))
+}
+
+func TestOpenRepairsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, WithCompactInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Append(records(time.Now(), 2)))
+	require.NoError(t, s.Close())
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	f, err := os.OpenFile(paths[0], os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":3,"time":`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = Open(dir, WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Append(records(time.Now(), 1)))
+	assert.Equal(t, []uint64{1, 2, 3}, seqs(t, s, Filter{}))
+}
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	assert.Nil(t, s.Cursor())
+	require.NoError(t, s.Commit(records(start, 2), json.RawMessage(`"a"`)))
+	require.NoError(t, s.Close())
+
+	// A crash after the records of a Commit were written but before its
+	// cursor was saved leaves records the cursor does not cover.
+	s, err = Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, s.write(records(start, 3)))
+	require.NoError(t, s.active.Close())
+	s.active = nil
+
+	s, err = Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	assert.JSONEq(t, `"a"`, string(s.Cursor()))
+	assert.Equal(t, []uint64{1, 2}, seqs(t, s, Filter{}))
+
+	// The dropped records' sequence numbers are not reused.
+	require.NoError(t, s.Commit(records(start, 1), json.RawMessage(`"b"`)))
+	require.NoError(t, s.Append(records(start, 1)))
+	assert.Equal(t, []uint64{1, 2, 6, 7}, seqs(t, s, Filter{}))
+	assert.JSONEq(t, `"b"`, string(s.Cursor()))
+}
+
+func TestCommitRollsBackFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Commit(records(start, 1), json.RawMessage(`"a"`)))
+	segments := len(s.segments)
+
+	// The last record cannot be encoded after the first ones of the batch,
+	// spanning new segments, were written.
+	batch := records(start, 4)
+	batch[3].Data = json.RawMessage(`{`)
+	require.Error(t, s.Commit(batch, json.RawMessage(`"b"`)))
+	assert.Equal(t, []uint64{1}, seqs(t, s, Filter{}))
+	assert.Len(t, s.segments, segments)
+	assert.Equal(t, 1, s.Stats().Records)
+	assert.JSONEq(t, `"a"`, string(s.Cursor()))
+
+	// Retrying stores the batch once, numbered on from before the failure.
+	require.NoError(t, s.Commit(records(start, 3), json.RawMessage(`"b"`)))
+	assert.Equal(t, []uint64{1, 2, 3, 4}, seqs(t, s, Filter{}))
+	require.NoError(t, s.Close())
+
+	s, err = Open(dir, WithSegmentSize(64), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, []uint64{1, 2, 3, 4}, seqs(t, s, Filter{}))
+}
+
+func TestRetention(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("age", func(t *testing.T) {
+		s, err := Open(t.TempDir(), WithMaxAge(time.Hour), WithSegmentSize(200), WithCompactInterval(0))
+		require.NoError(t, err)
+		defer s.Close()
+		s.now = func() time.Time { return start.Add(90 * time.Minute) }
+
+		// One record per minute from start; those before 00:30 expire.
+		require.NoError(t, s.Append(records(start, 60)))
+		require.NoError(t, s.Compact())
+
+		kept := seqs(t, s, Filter{})
+		require.Len(t, kept, 30)
+		assert.Equal(t, uint64(31), kept[0])
+	})
+
+	t.Run("count", func(t *testing.T) {
+		s, err := Open(t.TempDir(), WithMaxRecords(10), WithSegmentSize(200), WithCompactInterval(0))
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.NoError(t, s.Append(records(start, 25)))
+		require.NoError(t, s.Compact())
+		kept := seqs(t, s, Filter{})
+		require.Len(t, kept, 10)
+		assert.Equal(t, uint64(16), kept[0])
+	})
+
+	t.Run("size", func(t *testing.T) {
+		s, err := Open(t.TempDir(), WithMaxSize(1000), WithSegmentSize(300), WithCompactInterval(0))
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.NoError(t, s.Append(records(start, 100)))
+		require.NoError(t, s.Compact())
+		stats := s.Stats()
+		assert.LessOrEqual(t, stats.Size, int64(1000))
+		assert.Greater(t, stats.Size, int64(800))
+		kept := seqs(t, s, Filter{})
+		assert.Equal(t, uint64(100), kept[len(kept)-1])
+
+		// Appending after compaction continues the sequence.
+		require.NoError(t, s.Append(records(start, 1)))
+		kept = seqs(t, s, Filter{})
+		assert.Equal(t, uint64(101), kept[len(kept)-1])
+	})
+
+	t.Run("everything expired", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := Open(dir, WithMaxAge(time.Hour), WithCompactInterval(0))
+		require.NoError(t, err)
+		require.NoError(t, s.Append(records(start, 3)))
+		require.NoError(t, s.Close())
+
+		s, err = Open(dir, WithMaxAge(time.Hour), WithCompactInterval(0))
+		require.NoError(t, err)
+		defer s.Close()
+		assert.Zero(t, s.Stats().Records)
+		require.NoError(t, s.Append(records(time.Now(), 1)))
+		assert.Equal(t, []uint64{4}, seqs(t, s, Filter{}))
+	})
+}
+
+func TestCompactMergesSegments(t *testing.T) {
+	s, err := Open(t.TempDir(), WithMaxAge(time.Hour), WithSegmentSize(400), WithCompactInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+
+	start := time.Now().Add(-90*time.Minute + 30*time.Second)
+	require.NoError(t, s.Append(records(start, 80)))
+	before := s.Stats().Segments
+	require.NoError(t, s.Compact())
+
+	stats := s.Stats()
+	assert.Less(t, stats.Segments, before)
+	assert.Equal(t, 50, stats.Records)
+	assert.Len(t, seqs(t, s, Filter{}), 50)
+}
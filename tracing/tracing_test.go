@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+
+	"github.com/tejiriaustin/savannah-assessment/config"
+)
+
+func TestSetup(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	shutdown, err := Setup(context.Background(), config.Tracing{})
+	assert.NoError(t, err)
+	assert.Equal(t, previous, otel.GetTracerProvider())
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Contains(t, otel.GetTextMapPropagator().Fields(), "traceparent")
+
+	shutdown, err = Setup(context.Background(), config.Tracing{Exporter: ExporterOTLP, Protocol: ProtocolHTTP, Endpoint: "localhost:4318", Insecure: true, ServiceName: "test", SampleRatio: 1})
+	assert.NoError(t, err)
+	assert.NotEqual(t, previous, otel.GetTracerProvider())
+	_, span := Tracer().Start(context.Background(), "test")
+	assert.True(t, span.SpanContext().IsSampled())
+	span.End()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Nothing listens, so the flush fails but must not hang.
+	_ = shutdown(ctx)
+
+	_, err = Setup(context.Background(), config.Tracing{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
@@ -21,8 +21,12 @@ import (
 
 	"github.com/tejiriaustin/savannah-assessment/config"
 	"github.com/tejiriaustin/savannah-assessment/logger"
+	"github.com/tejiriaustin/savannah-assessment/monitoring"
 )
 
+// eventsShown is how many of the newest events the events table shows.
+const eventsShown = 500
+
 var (
 	startButton       *widget.Button
 	stopButton        *widget.Button
@@ -53,7 +57,11 @@ func Start(cfg *config.Config, logger *logger.Logger) {
 	}
 
 	status := widget.NewLabel(fmt.Sprintf(checkServiceStatus()))
-	monitorDirLabel := widget.NewLabel(fmt.Sprintf("Monitoring Directory: %s", cfg.MonitoredDirectory))
+	var watchPaths []string
+	for _, watch := range cfg.WatchList() {
+		watchPaths = append(watchPaths, watch.Path)
+	}
+	monitorDirLabel := widget.NewLabel(fmt.Sprintf("Monitoring Directory: %s", strings.Join(watchPaths, ", ")))
 	checkFreqLabel := widget.NewLabel(fmt.Sprintf("Check Frequency: %s", cfg.CheckFrequency))
 
 	startButton = widget.NewButtonWithIcon("Start Monitoring", theme.MediaPlayIcon(), func() {
@@ -180,7 +188,7 @@ func refreshLogs(table *widget.Table, port string) {
 		Timeout: 10 * time.Second,
 	}
 
-	url := fmt.Sprintf("http://localhost%s/events", port)
+	url := fmt.Sprintf("http://localhost%s/events?order=desc&limit=%d", port, eventsShown)
 	resp, err := client.Get(url)
 	if err != nil {
 		updateTableWithError(table, fmt.Sprintf("Failed to Fetch: Service not running"))
@@ -199,7 +207,7 @@ func refreshLogs(table *widget.Table, port string) {
 		return
 	}
 
-	var events []map[string]interface{}
+	var events []monitoring.FileEvent
 	if err := json.Unmarshal(body, &events); err != nil {
 		updateTableWithError(table, fmt.Sprintf("Error parsing JSON: %v", err))
 		return
@@ -222,7 +230,7 @@ func updateTableWithError(table *widget.Table, errorMsg string) {
 	table.Refresh()
 }
 
-func updateTableWithEvents(table *widget.Table, events []map[string]interface{}) {
+func updateTableWithEvents(table *widget.Table, events []monitoring.FileEvent) {
 	headers := []string{"action", "category", "target_path", "time", "size", "md5", "sha1", "sha256"}
 
 	table.Length = func() (int, int) { return len(events) + 1, len(headers) }
@@ -236,20 +244,47 @@ func updateTableWithEvents(table *widget.Table, events []map[string]interface{})
 			label.SetText(headers[id.Col])
 			label.TextStyle.Bold = true
 		} else {
-			event := events[id.Row-1]
-			value := fmt.Sprintf("%v", event[headers[id.Col]])
-			if headers[id.Col] == "time" {
-				timestamp, err := strconv.ParseInt(value, 10, 64)
-				if err == nil {
-					value = time.Unix(timestamp, 0).Format("2006-01-02 15:04:05")
-				}
-			}
-			label.SetText(value)
+			label.SetText(eventColumn(events[id.Row-1], headers[id.Col]))
 		}
 	}
 	table.Refresh()
 }
 
+// eventColumn formats one column of the events table.
+func eventColumn(event monitoring.FileEvent, column string) string {
+	switch column {
+	case "action":
+		if event.Action == monitoring.ActionMoved {
+			return fmt.Sprintf("%s from %s", event.Action, event.Path)
+		}
+		return string(event.Action)
+	case "category":
+		return event.Category
+	case "target_path":
+		return event.TargetPath
+	case "time":
+		return event.Time.Local().Format("2006-01-02 15:04:05")
+	case "size":
+		if event.Size == nil {
+			return ""
+		}
+		return strconv.FormatInt(*event.Size, 10)
+	}
+
+	if event.Hashes == nil {
+		return ""
+	}
+	switch column {
+	case "md5":
+		return event.Hashes.MD5
+	case "sha1":
+		return event.Hashes.SHA1
+	case "sha256":
+		return event.Hashes.SHA256
+	}
+	return ""
+}
+
 func periodicLogRefresh(table *widget.Table, port string) {
 	ticker := time.NewTicker(10 * time.Second)
 	for range ticker.C {